@@ -5,6 +5,13 @@ import "strings"
 //Filterable allows to sort the data
 type Filterable struct {
 	Filter string
+	//Ack is set if the data has to be confirmed after a target sent or dumped it
+	Ack Acknowledger
+}
+
+//Acknowledger confirms that the data has been handled by a target.
+type Acknowledger interface {
+	Acknowledge()
 }
 
 //AllFilterable will be used by everybody
@@ -40,6 +47,20 @@ func (f Filterable) TestTargetFilter(toTest string) bool {
 	return false
 }
 
+//Acknowledge confirms the data if it came from a write-ahead log
+func (f Filterable) Acknowledge() {
+	if f.Ack != nil {
+		f.Ack.Acknowledge()
+	}
+}
+
+//AcknowledgeAll confirms all given printables, should be called after a target sent or dumped them
+func AcknowledgeAll(printables []Printable) {
+	for _, p := range printables {
+		p.Acknowledge()
+	}
+}
+
 //TestTargetFilterObj like TestTargetFilter just with two objects
 func (f Filterable) TestTargetFilterObj(filter Filterable) bool {
 	return filter.TestTargetFilter(f.Filter)
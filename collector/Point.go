@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/griesbacher/nagflux/helper"
+	"strconv"
+	"time"
+)
+
+//Point is the structured representation of a single datapoint. Every target serializes it in its own format.
+type Point struct {
+	Filterable
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Timestamp   int64
+	Precision   time.Duration
+}
+
+//Points returns the point itself, so a single Point can be pushed as Printable.
+func (p Point) Points() []Point {
+	return []Point{p}
+}
+
+//TimestampMs returns the timestamp in milliseconds, a missing precision is treated as milliseconds.
+func (p Point) TimestampMs() int64 {
+	switch {
+	case p.Precision > time.Millisecond:
+		return p.Timestamp * int64(p.Precision/time.Millisecond)
+	case p.Precision > 0 && p.Precision < time.Millisecond:
+		return p.Timestamp / int64(time.Millisecond/p.Precision)
+	}
+	return p.Timestamp
+}
+
+//NumericFields returns all fields which can be represented as float64.
+func (p Point) NumericFields() map[string]float64 {
+	result := map[string]float64{}
+	for k, v := range p.Fields {
+		switch value := v.(type) {
+		case float64:
+			result[k] = value
+		case int64:
+			result[k] = float64(value)
+		}
+	}
+	return result
+}
+
+//jsonPoint is the JSON representation of a point.
+type jsonPoint struct {
+	Measurement string                 `json:"measurement"`
+	Timestamp   int64                  `json:"timestamp"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+}
+
+//MarshalJSON prints the point as flat JSON object with a millisecond timestamp.
+func (p Point) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonPoint{p.Measurement, p.TimestampMs(), p.Tags, p.Fields})
+}
+
+//UnmarshalJSON reads a point written by MarshalJSON, numbers become float64.
+func (p *Point) UnmarshalJSON(raw []byte) error {
+	var parsed jsonPoint
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return err
+	}
+	p.Measurement = parsed.Measurement
+	p.Tags = parsed.Tags
+	p.Fields = parsed.Fields
+	p.Timestamp = parsed.Timestamp
+	p.Precision = time.Millisecond
+	return nil
+}
+
+//ParseFieldValue converts a raw value from a collector to float64, int64, bool or string.
+func ParseFieldValue(raw string) interface{} {
+	return helper.InfluxFieldValue(raw)
+}
+
+//NewPointFromInfluxLine parses a line of the InfluxDB line protocol, the timestamp is expected in milliseconds.
+func NewPointFromInfluxLine(filter Filterable, line string) (Point, error) {
+	parsed, err := helper.ParseInfluxLine(line)
+	if err != nil {
+		return Point{}, err
+	}
+	point := Point{
+		Filterable:  filter,
+		Measurement: parsed.Measurement,
+		Tags:        parsed.Tags,
+		Fields:      map[string]interface{}{},
+		Precision:   time.Millisecond,
+	}
+	for k, v := range parsed.Fields {
+		point.Fields[k] = ParseFieldValue(v)
+	}
+	if parsed.Timestamp == "" {
+		point.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	} else if point.Timestamp, err = strconv.ParseInt(parsed.Timestamp, 10, 64); err != nil {
+		return Point{}, fmt.Errorf("Timestamp is not valid: %s", parsed.Timestamp)
+	}
+	return point, nil
+}
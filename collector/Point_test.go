@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPoint_TimestampMs(t *testing.T) {
+	t.Parallel()
+	for _, data := range []struct {
+		timestamp int64
+		precision time.Duration
+		expected  int64
+	}{
+		{12, time.Hour, 43200000},
+		{12, time.Minute, 720000},
+		{12, time.Second, 12000},
+		{12, time.Millisecond, 12},
+		{12345, time.Microsecond, 12},
+		{12345678, time.Nanosecond, 12},
+		//Missing precision is milliseconds
+		{12, 0, 12},
+	} {
+		if ms := (Point{Timestamp: data.timestamp, Precision: data.precision}).TimestampMs(); ms != data.expected {
+			t.Errorf("%d with precision %s, expected: %d, actual: %d", data.timestamp, data.precision, data.expected, ms)
+		}
+	}
+}
+
+func TestNewPointFromInfluxLine(t *testing.T) {
+	t.Parallel()
+	point, err := NewPointFromInfluxLine(AllFilterable, `metrics,host=h\ 1 value=1.5,count=3i,ok=true,text="a b" 1000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Point{
+		Filterable:  AllFilterable,
+		Measurement: "metrics",
+		Tags:        map[string]string{"host": "h 1"},
+		Fields:      map[string]interface{}{"value": 1.5, "count": int64(3), "ok": true, "text": "a b"},
+		Timestamp:   1000,
+		Precision:   time.Millisecond,
+	}
+	if !reflect.DeepEqual(point, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, point)
+	}
+	if _, err := NewPointFromInfluxLine(AllFilterable, "metrics"); err == nil {
+		t.Error("A line without fields should return an error")
+	}
+}
+
+func TestPoint_MarshalJSON(t *testing.T) {
+	t.Parallel()
+	point := Point{
+		Filterable:  AllFilterable,
+		Measurement: "messages",
+		Tags:        map[string]string{"host": "h1"},
+		Fields:      map[string]interface{}{"message": "hi"},
+		Timestamp:   2,
+		Precision:   time.Second,
+	}
+	out, err := json.Marshal(point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"measurement":"messages","timestamp":2000,"tags":{"host":"h1"},"fields":{"message":"hi"}}`
+	if string(out) != expected {
+		t.Errorf("expected: %s, actual: %s", expected, out)
+	}
+}
@@ -1,5 +1,106 @@
 package collector
 
-import "github.com/griesbacher/nagflux/data"
+import (
+	"github.com/griesbacher/nagflux/data"
+	"sync"
+)
 
-type ResultQueues map[data.Target]chan Printable
+//ResultQueues holds a queue for every target. Targets can be added and removed while the collectors are running.
+//Copies share the queues, filters and the rewriter.
+type ResultQueues struct {
+	*resultQueues
+}
+
+type resultQueues struct {
+	mutex   *sync.RWMutex
+	queues  map[data.Target]chan Printable
+	filters map[data.Target]*TargetFilter
+	//rewriter is applied to every printable, nil disables it.
+	rewriter Rewriter
+}
+
+//Rewriter changes, splits or drops the printables of the collectors before they are added to the queues.
+type Rewriter interface {
+	Rewrite(Printable) []Printable
+}
+
+//NewResultQueues creates an empty set of queues.
+func NewResultQueues() ResultQueues {
+	return ResultQueues{&resultQueues{
+		mutex:   &sync.RWMutex{},
+		queues:  map[data.Target]chan Printable{},
+		filters: map[data.Target]*TargetFilter{},
+	}}
+}
+
+//SetRewriter replaces the rewriter, nil disables it.
+func (r ResultQueues) SetRewriter(rewriter Rewriter) {
+	r.mutex.Lock()
+	r.rewriter = rewriter
+	r.mutex.Unlock()
+}
+
+//Rewrite passes the printable to the rewriter, the collectors have to add the result to the queues instead of the printable.
+func (r ResultQueues) Rewrite(p Printable) []Printable {
+	r.mutex.RLock()
+	rewriter := r.rewriter
+	r.mutex.RUnlock()
+	if rewriter == nil {
+		return []Printable{p}
+	}
+	return rewriter.Rewrite(p)
+}
+
+//Set adds or replaces the queue of the target.
+func (r ResultQueues) Set(target data.Target, queue chan Printable) {
+	r.mutex.Lock()
+	r.queues[target] = queue
+	r.mutex.Unlock()
+}
+
+//Get returns the queue of the target.
+func (r ResultQueues) Get(target data.Target) (chan Printable, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	queue, found := r.queues[target]
+	return queue, found
+}
+
+//SetFilter sets the filter of the target, nil accepts everything.
+func (r ResultQueues) SetFilter(target data.Target, filter *TargetFilter) {
+	r.mutex.Lock()
+	r.filters[target] = filter
+	r.mutex.Unlock()
+}
+
+//Delete removes the queue and the filter of the target, the collectors will not write into it anymore.
+func (r ResultQueues) Delete(target data.Target) {
+	r.mutex.Lock()
+	delete(r.queues, target)
+	delete(r.filters, target)
+	r.mutex.Unlock()
+}
+
+//For returns the queues of the targets whose filter accepts the printable, the collectors should add it to those.
+func (r ResultQueues) For(p Printable) map[data.Target]chan Printable {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	result := make(map[data.Target]chan Printable, len(r.queues))
+	for k, v := range r.queues {
+		if r.filters[k].Test(p) {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+//All returns a copy of the current queues, which can be used to iterate over.
+func (r ResultQueues) All() map[data.Target]chan Printable {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	result := make(map[data.Target]chan Printable, len(r.queues))
+	for k, v := range r.queues {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,27 @@
+package collector
+
+import "testing"
+
+type dropRewriter struct{}
+
+func (dropRewriter) Rewrite(Printable) []Printable {
+	return nil
+}
+
+func TestResultQueues_SetRewriter(t *testing.T) {
+	t.Parallel()
+	queues := NewResultQueues()
+	copied := queues
+	p := SimplePrintable{Text: "a"}
+	if result := copied.Rewrite(p); len(result) != 1 {
+		t.Errorf("Without a rewriter the printable should be passed, actual: %v", result)
+	}
+	queues.SetRewriter(dropRewriter{})
+	if result := copied.Rewrite(p); len(result) != 0 {
+		t.Errorf("The rewriter should be shared by the copies, actual: %v", result)
+	}
+	queues.SetRewriter(nil)
+	if result := copied.Rewrite(p); len(result) != 1 {
+		t.Errorf("nil should disable the rewriter, actual: %v", result)
+	}
+}
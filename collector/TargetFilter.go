@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+)
+
+//TargetFilter selects the data of a target by the tags of its points, like host, service, command or performanceLabel.
+//The regexes only apply to points which have the tag, e.g. messages have no command.
+type TargetFilter struct {
+	include map[string]*regexp.Regexp
+	exclude map[string]*regexp.Regexp
+}
+
+//NewTargetFilter compiles the given tag regexes, empty ones are ignored. If there is no regex at all nil is returned,
+//which accepts everything.
+func NewTargetFilter(include, exclude map[string]string) (*TargetFilter, error) {
+	filter := &TargetFilter{include: map[string]*regexp.Regexp{}, exclude: map[string]*regexp.Regexp{}}
+	for _, regexes := range []struct {
+		raw      map[string]string
+		compiled map[string]*regexp.Regexp
+	}{{include, filter.include}, {exclude, filter.exclude}} {
+		for tag, raw := range regexes.raw {
+			if raw == "" {
+				continue
+			}
+			regex, err := regexp.Compile(raw)
+			if err != nil {
+				return nil, fmt.Errorf("Filter of the tag %s is not valid: %s", tag, err)
+			}
+			regexes.compiled[tag] = regex
+		}
+	}
+	if len(filter.include) == 0 && len(filter.exclude) == 0 {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+//Test returns true if any point of the printable matches all include and no exclude regexes.
+//Preformatted printables can not be tested and are always accepted.
+func (f *TargetFilter) Test(p Printable) bool {
+	if f == nil {
+		return true
+	}
+	if _, ok := p.(SimplePrintable); ok {
+		return true
+	}
+	for _, point := range p.Points() {
+		if f.testPoint(point) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *TargetFilter) testPoint(point Point) bool {
+	for tag, regex := range f.include {
+		if value, ok := point.Tags[tag]; ok && !regex.MatchString(value) {
+			return false
+		}
+	}
+	for tag, regex := range f.exclude {
+		if value, ok := point.Tags[tag]; ok && regex.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"github.com/griesbacher/nagflux/data"
+	"testing"
+)
+
+func testPoint(tags map[string]string) Point {
+	return Point{Filterable: AllFilterable, Measurement: "metrics", Tags: tags}
+}
+
+var testFilterData = []struct {
+	p        Printable
+	expected bool
+}{
+	{testPoint(map[string]string{"host": "web1", "service": "http", "command": "check_http"}), true},
+	{testPoint(map[string]string{"host": "web1", "service": "http", "command": "check_tcp"}), false},
+	{testPoint(map[string]string{"host": "db1", "service": "http", "command": "check_http"}), false},
+	{testPoint(map[string]string{"host": "web-test", "service": "http", "command": "check_http"}), false},
+	//messages have no command
+	{testPoint(map[string]string{"host": "web1", "service": "http"}), true},
+	{SimplePrintable{Filterable: AllFilterable, Text: "db1"}, true},
+}
+
+func TestTargetFilter_Test(t *testing.T) {
+	t.Parallel()
+	filter, err := NewTargetFilter(
+		map[string]string{"host": "^web", "command": "^check_http$", "service": ""},
+		map[string]string{"host": "-test$"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range testFilterData {
+		if actual := filter.Test(data.p); actual != data.expected {
+			t.Errorf("%v: expected: %t, actual: %t", data.p, data.expected, actual)
+		}
+	}
+}
+
+func TestNewTargetFilter(t *testing.T) {
+	t.Parallel()
+	if filter, err := NewTargetFilter(map[string]string{"host": ""}, nil); filter != nil || err != nil {
+		t.Errorf("A filter without regexes should be nil: %v %v", filter, err)
+	}
+	if _, err := NewTargetFilter(nil, map[string]string{"host": "("}); err == nil {
+		t.Error("An invalid regex should return an error")
+	}
+}
+
+func TestResultQueues_For(t *testing.T) {
+	t.Parallel()
+	all := data.Target{Name: "all", Datatype: data.InfluxDB}
+	web := data.Target{Name: "web", Datatype: data.InfluxDB}
+	queues := NewResultQueues()
+	queues.Set(all, make(chan Printable))
+	queues.Set(web, make(chan Printable))
+	filter, _ := NewTargetFilter(map[string]string{"host": "^web"}, nil)
+	queues.SetFilter(web, filter)
+
+	if result := queues.For(testPoint(map[string]string{"host": "web1"})); len(result) != 2 {
+		t.Errorf("Expected both queues: %v", result)
+	}
+	if _, found := queues.For(testPoint(map[string]string{"host": "db1"}))[web]; found {
+		t.Error("The filtered target should not get the data")
+	}
+	queues.Delete(web)
+	queues.Set(web, make(chan Printable))
+	if result := queues.For(testPoint(map[string]string{"host": "db1"})); len(result) != 2 {
+		t.Errorf("The filter should be removed with the queue: %v", result)
+	}
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/collector/livestatus"
+	"github.com/griesbacher/nagflux/collector/nagflux"
+	"github.com/griesbacher/nagflux/collector/spoolfile"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/helper"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/kdar/factorlog"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Collector accepts perfdata, InfluxDB lines and nagflux CSV via HTTP and pushes them into the queues.
+type Collector struct {
+	results        collector.ResultQueues
+	nagiosWorker   *spoolfile.NagiosSpoolfileWorker
+	fieldSeparator rune
+	defaultTarget  collector.Filterable
+	server         *http.Server
+	log            *factorlog.FactorLog
+	//pushMutex serializes the requests while they are queued, so the space checked by one is not taken by another.
+	pushMutex *sync.Mutex
+}
+
+const (
+	perfdataPath = "/v1/perfdata"
+	influxPath   = "/v1/influx"
+	nagfluxPath  = "/v1/nagflux"
+)
+
+//maxBodySize limits a single request to 32MB.
+const maxBodySize = 32 << 20
+
+//queueTimeout is the time a request waits for a full queue before it is rejected.
+const queueTimeout = time.Duration(1) * time.Second
+
+//result is the response to an accepted or rejected request, the first Accepted items have been queued.
+type result struct {
+	Accepted int    `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+//NewAPICollector constructor, which also starts the HTTP server on the given address.
+//Data without a target is sent to the target given by the query parameter target or defaultTarget.
+func NewAPICollector(results collector.ResultQueues, address string, livestatusCacheBuilder livestatus.CacheBuilders,
+	unitNormalizer *spoolfile.UnitNormalizer, rateCalculator *spoolfile.RateCalculator, fileBufferSize int, fieldSeparator rune, defaultTarget collector.Filterable) (*Collector, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s := &Collector{
+		results:        results,
+		nagiosWorker:   spoolfile.NewNagiosSpoolfileWorker(-1, nil, results, livestatusCacheBuilder, unitNormalizer, rateCalculator, fileBufferSize, defaultTarget),
+		fieldSeparator: fieldSeparator,
+		defaultTarget:  defaultTarget,
+		log:            logging.GetLogger(),
+		pushMutex:      &sync.Mutex{},
+	}
+	s.server = &http.Server{Handler: s.Handler()}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.log.Critical("APICollector: ", err)
+		}
+	}()
+	s.log.Infof("APICollector listening on %s", listener.Addr())
+	return s, nil
+}
+
+//Stop stops the Collector, running requests are finished.
+func (c *Collector) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(5)*time.Second)
+	defer cancel()
+	c.server.Shutdown(ctx)
+	c.log.Debug("APICollector stopped")
+}
+
+//Handler returns the routes of the API.
+func (c *Collector) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(perfdataPath, c.handle(c.parsePerfdata))
+	mux.HandleFunc(influxPath, c.handle(c.parseInfluxLines))
+	mux.HandleFunc(nagfluxPath, c.handle(c.parseNagfluxCSV))
+	return mux
+}
+
+//handle parses the whole body before anything is queued, so a bad request does not queue partial data.
+func (c *Collector) handle(parse func(body io.Reader, target collector.Filterable) ([]collector.Printable, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		target := c.defaultTarget
+		if target.Filter == "" {
+			target = collector.AllFilterable
+		}
+		if name := r.URL.Query().Get("target"); name != "" {
+			target = collector.Filterable{Filter: name}
+		}
+		parsed, err := parse(http.MaxBytesReader(w, r.Body, maxBodySize), target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		printables := []collector.Printable{}
+		for _, p := range parsed {
+			printables = append(printables, c.results.Rewrite(p)...)
+		}
+		if accepted, err := c.push(printables); err != nil {
+			c.log.Warnf("APICollector: %s, rejected %d of %d items", err, len(printables)-accepted, len(printables))
+			w.Header().Set("Retry-After", "5")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(result{Accepted: accepted, Error: err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(result{Accepted: len(printables)})
+	}
+}
+
+//push adds the printables to the queues of their targets. If a queue has not enough space left for the printables
+//of its target, nothing is added. Should another collector fill up a queue in the meantime, the amount of the
+//printables which have been completely queued, which are the first ones, is returned with an error.
+func (c *Collector) push(printables []collector.Printable) (int, error) {
+	c.pushMutex.Lock()
+	defer c.pushMutex.Unlock()
+	needed := map[data.Target]int{}
+	queues := map[data.Target]chan collector.Printable{}
+	for _, p := range printables {
+		for target, queue := range c.results.For(p) {
+			needed[target]++
+			queues[target] = queue
+		}
+	}
+	for target, queue := range queues {
+		if cap(queue)-len(queue) < needed[target] {
+			return 0, fmt.Errorf("Queue of %s is full", target.Name)
+		}
+	}
+	for i, p := range printables {
+		for target, queue := range c.results.For(p) {
+			select {
+			case queue <- p:
+			case <-time.After(queueTimeout):
+				return i, fmt.Errorf("Queue of %s is full", target.Name)
+			}
+		}
+	}
+	return len(printables), nil
+}
+
+//Parses lines in the Nagios perfdata format like the spoolfiles. NAGFLUX:TARGET overrides the target of the request.
+func (c *Collector) parsePerfdata(body io.Reader, target collector.Filterable) ([]collector.Printable, error) {
+	var result []collector.Printable
+	err := scanLines(body, func(line string) error {
+		for perfdata := range c.nagiosWorker.PerformanceDataIterator(helper.StringToMap(line, "\t", "::")) {
+			if !strings.Contains(line, "NAGFLUX:TARGET::") {
+				perfdata.Filterable = target
+			}
+			result = append(result, perfdata)
+		}
+		return nil
+	})
+	return result, err
+}
+
+//Parses InfluxDB lines like the dumpfiles, the timestamp has to be in ms.
+func (c *Collector) parseInfluxLines(body io.Reader, target collector.Filterable) ([]collector.Printable, error) {
+	var result []collector.Printable
+	lineNumber := 0
+	err := scanLines(body, func(line string) error {
+		lineNumber++
+		point, err := collector.NewPointFromInfluxLine(target, line)
+		if err != nil {
+			return fmt.Errorf("Line %d: %s", lineNumber, err)
+		}
+		result = append(result, point)
+		return nil
+	})
+	return result, err
+}
+
+//Parses the nagflux CSV format like the NagfluxSpoolfileFolder, rows without a specific target get the target of the request.
+func (c *Collector) parseNagfluxCSV(body io.Reader, target collector.Filterable) ([]collector.Printable, error) {
+	printables, err := nagflux.ParseCSV(body, c.fieldSeparator)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]collector.Printable, len(printables))
+	for i, p := range printables {
+		if p.Filterable == collector.AllFilterable {
+			p.Filterable = target
+		}
+		result[i] = p
+	}
+	return result, nil
+}
+
+//scanLines calls parse for every line which is not empty.
+func scanLines(body io.Reader, parse func(line string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxBodySize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
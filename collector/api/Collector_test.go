@@ -0,0 +1,114 @@
+package api
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/collector/spoolfile"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/logging"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var target = data.Target{Name: "a", Datatype: data.InfluxDB}
+
+func newTestCollector(bufferSize int) (*Collector, chan collector.Printable) {
+	queue := make(chan collector.Printable, bufferSize)
+	results := collector.NewResultQueues()
+	results.Set(target, queue)
+	return &Collector{
+		results:        results,
+		nagiosWorker:   spoolfile.NewNagiosSpoolfileWorker(-1, nil, results, nil, nil, nil, 4096, collector.AllFilterable),
+		fieldSeparator: '&',
+		log:            logging.GetLogger(),
+		pushMutex:      &sync.Mutex{},
+	}, queue
+}
+
+func post(c *Collector, url, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	c.Handler().ServeHTTP(recorder, httptest.NewRequest("POST", url, strings.NewReader(body)))
+	return recorder
+}
+
+func TestCollector_Influx(t *testing.T) {
+	t.Parallel()
+	c, queue := newTestCollector(10)
+	response := post(c, influxPath+"?target=a", "metrics,host=h value=1 1000\n\nmetrics,host=h value=2 2000\n")
+	if response.Code != http.StatusAccepted || strings.TrimSpace(response.Body.String()) != `{"accepted":2}` {
+		t.Fatalf("Unexpected response: %d %s", response.Code, response.Body.String())
+	}
+	if len(queue) != 2 {
+		t.Fatalf("Expected 2 items in the queue, actual: %d", len(queue))
+	}
+	point := (<-queue).Points()[0]
+	if point.Filter != "a" || point.Tags["host"] != "h" || point.Fields["value"] != 1.0 || point.TimestampMs() != 1000 {
+		t.Errorf("Unexpected point: %+v", point)
+	}
+}
+
+func TestCollector_Perfdata(t *testing.T) {
+	t.Parallel()
+	c, queue := newTestCollector(10)
+	body := "DATATYPE::SERVICEPERFDATA\tTIMET::1441791000\tHOSTNAME::xxx\tSERVICEDESC::range\tSERVICEPERFDATA::a=1 b=2\tSERVICECHECKCOMMAND::check_ranges\n" +
+		"DATATYPE::SERVICEPERFDATA\tTIMET::1441791000\tHOSTNAME::xxx\tSERVICEDESC::range\tSERVICEPERFDATA::c=3\tSERVICECHECKCOMMAND::check_ranges\tNAGFLUX:TARGET::b\n"
+	response := post(c, perfdataPath, body)
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("Unexpected response: %d %s", response.Code, response.Body.String())
+	}
+	if len(queue) != 3 {
+		t.Fatalf("Expected 3 items in the queue, actual: %d", len(queue))
+	}
+	for _, expected := range []struct{ label, filter string }{{"a", "all"}, {"b", "all"}, {"c", "b"}} {
+		perf := (<-queue).(spoolfile.PerformanceData)
+		if perf.PerformanceLabel != expected.label || perf.Filter != expected.filter || perf.Hostname != "xxx" {
+			t.Errorf("Unexpected perfdata, expected: %+v, actual: %+v", expected, perf)
+		}
+	}
+}
+
+func TestCollector_NagfluxCSV(t *testing.T) {
+	t.Parallel()
+	c, queue := newTestCollector(10)
+	response := post(c, nagfluxPath+"?target=a", "table&time&f_value&t_foo&target\ntest&1000&1.0&bar&\ntest&2000&2.0&bar&b\n")
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("Unexpected response: %d %s", response.Code, response.Body.String())
+	}
+	for _, filter := range []string{"a", "b"} {
+		point := (<-queue).Points()[0]
+		if point.Measurement != "test" || point.Tags["foo"] != "bar" || point.Filter != filter {
+			t.Errorf("Unexpected point with filter %s: %+v", filter, point)
+		}
+	}
+}
+
+func TestCollector_Errors(t *testing.T) {
+	t.Parallel()
+	c, queue := newTestCollector(1)
+	if response := post(c, influxPath, "metrics value=1 1000\nnot valid"); response.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid line, actual: %d", response.Code)
+	}
+	if response := post(c, nagfluxPath, "foo&bar\n1&2\n"); response.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a missing header, actual: %d", response.Code)
+	}
+	if len(queue) != 0 {
+		t.Errorf("Invalid requests should not queue anything, actual: %d", len(queue))
+	}
+	response := post(c, influxPath, "metrics value=1 1000\nmetrics value=2 2000")
+	if response.Code != http.StatusTooManyRequests || response.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After if the queue is too small, actual: %d", response.Code)
+	}
+	if body := strings.TrimSpace(response.Body.String()); body != `{"accepted":0,"error":"Queue of a is full"}` {
+		t.Errorf("The response should contain the amount of queued items, actual: %s", body)
+	}
+	if len(queue) != 0 {
+		t.Errorf("A rejected request should not queue anything, actual: %d", len(queue))
+	}
+	recorder := httptest.NewRecorder()
+	c.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", influxPath, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, actual: %d", recorder.Code)
+	}
+}
@@ -0,0 +1,234 @@
+package icinga2
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/collector/livestatus"
+	"github.com/griesbacher/nagflux/collector/spoolfile"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/kdar/factorlog"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//Collector subscribes to the event stream of the Icinga2 API.
+//Perfdata of check results is parsed like the spoolfiles, the other events become messages like the ones from livestatus.
+type Collector struct {
+	quit         chan bool
+	cancel       context.CancelFunc
+	ctx          context.Context
+	results      collector.ResultQueues
+	eventsURL    string
+	user         string
+	password     string
+	filter       string
+	httpClient   *http.Client
+	nagiosWorker *spoolfile.NagiosSpoolfileWorker
+	minDelay     time.Duration
+	log          *factorlog.FactorLog
+}
+
+const (
+	eventsPath = "/v1/events"
+	//maxReconnectDelay limits the backoff between two connection attempts.
+	maxReconnectDelay = time.Duration(1) * time.Minute
+	//maxEventSize is the largest line which is accepted from the stream.
+	maxEventSize = 16 << 20
+)
+
+//eventTypes are the subscribed event types.
+var eventTypes = []string{"CheckResult", "Notification", "DowntimeStarted", "DowntimeRemoved", "CommentAdded"}
+
+var errorStreamClosed = errors.New("Event stream has been closed")
+
+//NewIcinga2Collector constructor, which also starts the collector.
+//queue is the name of the event queue within Icinga2, filter an optional Icinga2 filter expression for the events.
+func NewIcinga2Collector(results collector.ResultQueues, address, user, password, queue, filter string, insecureSkipVerify bool,
+	livestatusCacheBuilder livestatus.CacheBuilders, unitNormalizer *spoolfile.UnitNormalizer, rateCalculator *spoolfile.RateCalculator,
+	fileBufferSize int, defaultTarget collector.Filterable) *Collector {
+	s := newCollector(results, address, user, password, queue, filter, insecureSkipVerify, time.Duration(1)*time.Second)
+	s.nagiosWorker = spoolfile.NewNagiosSpoolfileWorker(-1, nil, results, livestatusCacheBuilder, unitNormalizer, rateCalculator, fileBufferSize, defaultTarget)
+	go s.run()
+	return s
+}
+
+func newCollector(results collector.ResultQueues, address, user, password, queue, filter string, insecureSkipVerify bool, minDelay time.Duration) *Collector {
+	parameters := url.Values{"queue": {queue}, "types": eventTypes}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Collector{
+		quit:      make(chan bool),
+		ctx:       ctx,
+		cancel:    cancel,
+		results:   results,
+		eventsURL: strings.TrimRight(address, "/") + eventsPath + "?" + parameters.Encode(),
+		user:      user,
+		password:  password,
+		filter:    filter,
+		httpClient: &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+		}},
+		minDelay: minDelay,
+		log:      logging.GetLogger(),
+	}
+}
+
+//Stop stops the Collector.
+func (c *Collector) Stop() {
+	c.cancel()
+	<-c.quit
+	c.log.Debug("Icinga2Collector stopped")
+}
+
+//Connects to the event stream, after an error it reconnects with an increasing delay.
+func (c *Collector) run() {
+	defer close(c.quit)
+	delay := c.minDelay
+	for {
+		connected, err := c.stream()
+		if c.ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = c.minDelay
+		}
+		c.log.Warnf("Icinga2Collector: %s, reconnecting in %s", err, delay)
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+//stream reads events till the connection breaks, connected is true if the API accepted the subscription.
+func (c *Collector) stream() (connected bool, err error) {
+	var body io.Reader
+	if c.filter != "" {
+		raw, _ := json.Marshal(map[string]string{"filter": c.filter})
+		body = strings.NewReader(string(raw))
+	}
+	req, err := http.NewRequest("POST", c.eventsURL, body)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(c.ctx)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Nagflux")
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("%s - %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	c.log.Info("Icinga2Collector: subscribed to the event stream")
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var e event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			c.log.Warn("Icinga2Collector: could not parse event: ", err)
+			continue
+		}
+		for _, p := range c.eventToPrintables(e) {
+			if !c.push(p) {
+				return true, c.ctx.Err()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+	return true, errorStreamClosed
+}
+
+//push adds the rewritten printable to every queue, it returns false if the collector got stopped.
+func (c *Collector) push(printable collector.Printable) bool {
+	for _, p := range c.results.Rewrite(printable) {
+		for _, r := range c.results.For(p) {
+			select {
+			case <-c.ctx.Done():
+				return false
+			case r <- p:
+			case <-time.After(time.Duration(10) * time.Second):
+				c.log.Warn("Icinga2Collector: Could not write to buffer")
+			}
+		}
+	}
+	return true
+}
+
+//eventToPrintables converts a single event, unknown events are ignored.
+func (c *Collector) eventToPrintables(e event) []collector.Printable {
+	var result []collector.Printable
+	switch e.Type {
+	case "CheckResult":
+		if e.CheckResult == nil {
+			return nil
+		}
+		for perfdata := range c.nagiosWorker.PerformanceDataIterator(e.spoolfileLine()) {
+			result = append(result, perfdata)
+		}
+	case "Notification":
+		if e.CheckResult == nil {
+			return nil
+		}
+		notificationType := "HOST NOTIFICATION"
+		if e.Service != "" {
+			notificationType = "SERVICE NOTIFICATION"
+		}
+		message := e.CheckResult.Output
+		if e.Text != "" {
+			message = e.Text
+		}
+		result = append(result, livestatus.NewNotificationData(
+			collector.AllFilterable, e.Host, e.Service, message, seconds(e.Timestamp), strings.Join(e.Users, ","),
+			notificationType, e.notificationLevel(),
+		))
+	case "DowntimeStarted":
+		if d := e.Downtime; d != nil {
+			result = append(result, livestatus.NewDowntimeData(
+				collector.AllFilterable, d.HostName, d.ServiceName, d.Comment, seconds(e.Timestamp), seconds(d.EndTime), d.Author,
+			))
+		}
+	case "DowntimeRemoved":
+		//Only a downtime which has been cancelled before its end needs an additional end
+		if d := e.Downtime; d != nil && d.TriggerTime > 0 && e.Timestamp < d.EndTime {
+			result = append(result, livestatus.NewDowntimeData(
+				collector.AllFilterable, d.HostName, d.ServiceName, d.Comment, "", seconds(e.Timestamp), d.Author,
+			))
+		}
+	case "CommentAdded":
+		if comment := e.Comment; comment != nil {
+			result = append(result, livestatus.NewCommentData(
+				collector.AllFilterable, comment.HostName, comment.ServiceName, comment.Text, seconds(comment.EntryTime),
+				comment.Author, fmt.Sprint(comment.EntryType),
+			))
+		}
+	default:
+		c.log.Debug("Icinga2Collector: ignoring event type: ", e.Type)
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package icinga2
+
+import (
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/collector/spoolfile"
+	"github.com/griesbacher/nagflux/config"
+	"github.com/griesbacher/nagflux/data"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testConfig = `[InfluxDBGlobal]
+	HostcheckAlias = "hostcheck"
+`
+
+//Events as they are sent by Icinga2, the first connection is closed after them.
+var firstConnection = []string{
+	`{"type":"CheckResult","timestamp":1500000001.5,"host":"h","service":"load","check_result":{"output":"OK","state":0,"execution_end":1500000001.2,"command":["/usr/lib/nagios/plugins/check_load","-w","1"],"performance_data":["load1=0.5;1;2;0","load5=0.25;1;2;0"]}}`,
+	`not json`,
+	`{"type":"Notification","timestamp":1500000002,"host":"h","service":"load","notification_type":"PROBLEM","users":["a","b"],"check_result":{"output":"load is high","state":2}}`,
+}
+
+var secondConnection = []string{
+	`{"type":"CheckResult","timestamp":1500000003,"host":"h","check_result":{"output":"UP","state":0,"command":"/bin/check_ping -H h","performance_data":[{"type":"PerfdataValue","label":"rta","value":0.5,"unit":"ms","warn":100,"crit":null,"min":0}]}}`,
+	`{"type":"CommentAdded","timestamp":1500000004,"comment":{"host_name":"h","service_name":"","author":"admin","text":"hello","entry_time":1500000004,"entry_type":1}}`,
+	`{"type":"DowntimeStarted","timestamp":1500000005,"downtime":{"host_name":"h","service_name":"load","author":"admin","comment":"maintenance","end_time":1500003600,"trigger_time":1500000005}}`,
+	`{"type":"DowntimeRemoved","timestamp":1500000600,"downtime":{"host_name":"h","service_name":"load","author":"admin","comment":"maintenance","end_time":1500003600,"trigger_time":1500000005}}`,
+}
+
+func newStub(t *testing.T) *httptest.Server {
+	connections := 0
+	mutex := &sync.Mutex{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "root" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != eventsPath || r.URL.Query().Get("queue") != "nagflux" || len(r.URL.Query()["types"]) != len(eventTypes) {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		mutex.Lock()
+		connections++
+		reconnected := connections > 1
+		mutex.Unlock()
+		events := firstConnection
+		if reconnected {
+			events = secondConnection
+		}
+		for _, e := range events {
+			fmt.Fprintln(w, e)
+			w.(http.Flusher).Flush()
+		}
+		if reconnected {
+			<-r.Context().Done()
+		}
+	}))
+}
+
+func messagePoint(service, typ, author, message string, timestamp int64) collector.Point {
+	return collector.Point{
+		Filterable:  collector.AllFilterable,
+		Measurement: "messages",
+		Tags:        map[string]string{"host": "h", "service": service, "author": author, "type": typ},
+		Fields:      map[string]interface{}{"message": message},
+		Timestamp:   timestamp,
+		Precision:   time.Second,
+	}
+}
+
+func TestCollector(t *testing.T) {
+	config.InitConfigFromString(testConfig)
+	stub := newStub(t)
+	defer stub.Close()
+
+	queue := make(chan collector.Printable, 100)
+	results := collector.NewResultQueues()
+	results.Set(data.Target{Name: "a", Datatype: data.InfluxDB}, queue)
+	c := newCollector(results, stub.URL, "root", "secret", "nagflux", "", false, time.Duration(10)*time.Millisecond)
+	c.nagiosWorker = spoolfile.NewNagiosSpoolfileWorker(-1, nil, results, nil, nil, nil, 4096, collector.AllFilterable)
+	go c.run()
+	defer c.Stop()
+
+	var received []collector.Printable
+	for len(received) < 7 {
+		select {
+		case p := <-queue:
+			received = append(received, p)
+		case <-time.After(time.Duration(5) * time.Second):
+			t.Fatalf("Expected 7 printables, got %d", len(received))
+		}
+	}
+
+	for i, expected := range []struct{ label, command, service string }{
+		{"load1", "check_load", "load"}, {"load5", "check_load", "load"},
+	} {
+		perf, ok := received[i].(spoolfile.PerformanceData)
+		if !ok || perf.PerformanceLabel != expected.label || perf.Command != expected.command || perf.Service != expected.service || perf.Time != "1500000001000" {
+			t.Errorf("Unexpected perfdata, expected: %+v, actual: %+v", expected, received[i])
+		}
+	}
+	if points := received[2].Points(); !reflect.DeepEqual(points, []collector.Point{
+		messagePoint("load", "service_notification", "a,b", "CRITICAL:<br> load is high", 1500000002),
+	}) {
+		t.Errorf("Unexpected notification: %v", points)
+	}
+	perf, ok := received[3].(spoolfile.PerformanceData)
+	if !ok || perf.PerformanceLabel != "'rta'" || perf.Command != "check_ping" || perf.Unit != "ms" || perf.Fields["warn"] != "100.0" || perf.Fields["min"] != "0.0" {
+		t.Errorf("Unexpected host perfdata: %+v", received[3])
+	}
+	if points := received[4].Points(); !reflect.DeepEqual(points, []collector.Point{
+		messagePoint("hostcheck", "comment", "admin", "hello", 1500000004),
+	}) {
+		t.Errorf("Unexpected comment: %v", points)
+	}
+	if points := received[5].Points(); !reflect.DeepEqual(points, []collector.Point{
+		messagePoint("load", "downtime", "admin", "Downtime start: <br>maintenance", 1500000005),
+		messagePoint("load", "downtime", "admin", "Downtime end: <br>maintenance", 1500003600),
+	}) {
+		t.Errorf("Unexpected downtime: %v", points)
+	}
+	if points := received[6].Points(); !reflect.DeepEqual(points, []collector.Point{
+		messagePoint("load", "downtime", "admin", "Downtime end: <br>maintenance", 1500000600),
+	}) {
+		t.Errorf("Unexpected removed downtime: %v", points)
+	}
+}
@@ -0,0 +1,163 @@
+package icinga2
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//event is a single object of the Icinga2 event stream, only the used attributes are mapped.
+type event struct {
+	Type             string       `json:"type"`
+	Timestamp        float64      `json:"timestamp"`
+	Host             string       `json:"host"`
+	Service          string       `json:"service"`
+	CheckResult      *checkResult `json:"check_result"`
+	NotificationType string       `json:"notification_type"`
+	Users            []string     `json:"users"`
+	Text             string       `json:"text"`
+	Downtime         *downtime    `json:"downtime"`
+	Comment          *comment     `json:"comment"`
+}
+
+type checkResult struct {
+	Output          string            `json:"output"`
+	State           float64           `json:"state"`
+	ExecutionEnd    float64           `json:"execution_end"`
+	Command         json.RawMessage   `json:"command"`
+	PerformanceData []json.RawMessage `json:"performance_data"`
+}
+
+type downtime struct {
+	HostName    string  `json:"host_name"`
+	ServiceName string  `json:"service_name"`
+	Author      string  `json:"author"`
+	Comment     string  `json:"comment"`
+	EndTime     float64 `json:"end_time"`
+	TriggerTime float64 `json:"trigger_time"`
+}
+
+type comment struct {
+	HostName    string  `json:"host_name"`
+	ServiceName string  `json:"service_name"`
+	Author      string  `json:"author"`
+	Text        string  `json:"text"`
+	EntryTime   float64 `json:"entry_time"`
+	EntryType   int     `json:"entry_type"`
+}
+
+//perfdataValue is the structured perfdata, Icinga2 uses it if the perfdata has been parsed.
+type perfdataValue struct {
+	Label string      `json:"label"`
+	Value float64     `json:"value"`
+	Unit  string      `json:"unit"`
+	Warn  interface{} `json:"warn"`
+	Crit  interface{} `json:"crit"`
+	Min   interface{} `json:"min"`
+	Max   interface{} `json:"max"`
+}
+
+var serviceStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+//hostStates maps the state of a host check result, Icinga2 uses the service states for it.
+var hostStates = []string{"UP", "UP", "DOWN", "DOWN"}
+
+//spoolfileLine converts the check result into the map of a spoolfile line.
+func (e event) spoolfileLine() map[string]string {
+	typ := "HOST"
+	if e.Service != "" {
+		typ = "SERVICE"
+	}
+	timestamp := e.CheckResult.ExecutionEnd
+	if timestamp == 0 {
+		timestamp = e.Timestamp
+	}
+	return map[string]string{
+		"DATATYPE":           typ + "PERFDATA",
+		"TIMET":              seconds(timestamp),
+		"HOSTNAME":           e.Host,
+		"SERVICEDESC":        e.Service,
+		typ + "PERFDATA":     e.CheckResult.perfdata(),
+		typ + "CHECKCOMMAND": e.CheckResult.commandName(),
+	}
+}
+
+//perfdata joins the perfdata like it is written to the spoolfiles.
+func (r checkResult) perfdata() string {
+	var result []string
+	for _, raw := range r.PerformanceData {
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			result = append(result, text)
+			continue
+		}
+		var value perfdataValue
+		if err := json.Unmarshal(raw, &value); err == nil && value.Label != "" {
+			result = append(result, value.String())
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+//String prints the perfdata like a plugin.
+func (v perfdataValue) String() string {
+	result := fmt.Sprintf("'%s'=%s%s", v.Label, strconv.FormatFloat(v.Value, 'f', -1, 64), v.Unit)
+	for _, threshold := range []interface{}{v.Warn, v.Crit, v.Min, v.Max} {
+		result += ";"
+		if number, ok := threshold.(float64); ok {
+			result += strconv.FormatFloat(number, 'f', -1, 64)
+		}
+	}
+	return strings.TrimRight(result, ";")
+}
+
+//commandName returns the name of the executed plugin, the command is either a string or a list of arguments.
+//The event does not contain the name of the CheckCommand object, so wrappers like check_by_ssh or check_nrpe are
+//returned instead of the plugin they run. Relabel rules can set a better command, e.g. by the service name.
+func (r checkResult) commandName() string {
+	var command string
+	var arguments []string
+	if err := json.Unmarshal(r.Command, &arguments); err == nil && len(arguments) > 0 {
+		command = arguments[0]
+	} else if err := json.Unmarshal(r.Command, &command); err == nil {
+		if fields := strings.Fields(command); len(fields) > 0 {
+			command = fields[0]
+		}
+	}
+	if command == "" {
+		return ""
+	}
+	return path.Base(command)
+}
+
+//state returns the name of the state of the check result.
+func (e event) state() string {
+	states := hostStates
+	if e.Service != "" {
+		states = serviceStates
+	}
+	if state := int(e.CheckResult.State); state >= 0 && state < len(states) {
+		return states[state]
+	}
+	return "UNKNOWN"
+}
+
+//notificationLevel is the state for problems and recoveries, otherwise the notification type with the state like in the Nagios log.
+func (e event) notificationLevel() string {
+	state := e.state()
+	switch e.NotificationType {
+	case "", "PROBLEM", "RECOVERY":
+		return state
+	}
+	return fmt.Sprintf("%s (%s)", e.NotificationType, state)
+}
+
+//seconds converts an Icinga2 timestamp into seconds, zero is treated as missing.
+func seconds(timestamp float64) string {
+	if timestamp <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(int64(timestamp), 10)
+}
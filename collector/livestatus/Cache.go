@@ -1,25 +1,231 @@
 package livestatus
 
 import (
-	"strconv"
+	"strings"
 )
 
-//Cache contains stored data
+//Cache contains the downtimes of livestatus and the acknowledgements, both indexed by the host.
+//The key of the inner downtime map is the id of the downtime.
 type Cache struct {
-	downtime map[string]map[string]string
+	downtimes        map[string]map[string]downtime
+	acknowledgements map[string][]acknowledgement
 }
 
-func (cache *Cache) addDowntime(host, service, start string) {
-	if _, hostExists := cache.downtime[host]; !hostExists {
-		cache.downtime[host] = map[string]string{service: start}
-	} else if _, serviceExists := cache.downtime[host][service]; !serviceExists {
-		cache.downtime[host][service] = start
+//downtime is a scheduled downtime of a host, the service is empty, or of a service.
+type downtime struct {
+	host    string
+	service string
+	//serviceDescription is used to find the downtime alerts in the livestatus log.
+	serviceDescription string
+	start              int64
+	end                int64
+	//fixed downtimes last from start to end, flexible ones last duration seconds after they were triggered.
+	fixed     bool
+	duration  int64
+	triggered int64
+	//stopped is the time the downtime was cancelled, 0 as long as it exists.
+	stopped int64
+}
+
+//interval returns the time in which the downtime was active, ok is false if it has not been triggered yet.
+func (d downtime) interval() (from, to int64, ok bool) {
+	if d.fixed {
+		from, to = d.start, d.end
+	} else if d.triggered > 0 {
+		from, to = d.triggered, d.triggered+d.duration
 	} else {
-		oldTimestamp, _ := strconv.Atoi(cache.downtime[host][service])
-		newTimestamp, _ := strconv.Atoi(start)
-		//Take timestamp if its newer
-		if oldTimestamp > newTimestamp {
-			cache.downtime[host][service] = start
+		return 0, 0, false
+	}
+	if d.stopped > 0 && d.stopped < to {
+		to = d.stopped
+	}
+	return from, to, true
+}
+
+func (d downtime) contains(timestamp int64) bool {
+	from, to, ok := d.interval()
+	return ok && from <= timestamp && timestamp <= to
+}
+
+//ackKey identifies an acknowledged host, the service is empty, or service.
+type ackKey struct {
+	host    string
+	service string
+}
+
+//acknowledgement is the time in which the problem of a host/service was acknowledged.
+type acknowledgement struct {
+	ackKey
+	start int64
+	//stopped is the time the acknowledgement was removed, 0 as long as it exists.
+	stopped int64
+}
+
+func (ack acknowledgement) contains(timestamp int64) bool {
+	return ack.start <= timestamp && (ack.stopped == 0 || timestamp <= ack.stopped)
+}
+
+func newCache() Cache {
+	return Cache{downtimes: map[string]map[string]downtime{}, acknowledgements: map[string][]acknowledgement{}}
+}
+
+func (cache Cache) addDowntime(id string, d downtime) {
+	if _, hostExists := cache.downtimes[d.host]; !hostExists {
+		cache.downtimes[d.host] = map[string]downtime{}
+	}
+	cache.downtimes[d.host][id] = d
+}
+
+//inDowntime returns true if the host/service was in downtime at the timestamp, services inherit the downtimes of their host.
+func (cache Cache) inDowntime(host, service string, timestamp int64) bool {
+	for _, d := range cache.downtimes[host] {
+		if (d.service == service || d.service == "") && d.contains(timestamp) {
+			return true
+		}
+	}
+	return false
+}
+
+//isAcknowledged returns true if the problem of the host/service was acknowledged at the timestamp.
+func (cache Cache) isAcknowledged(host, service string, timestamp int64) bool {
+	for _, ack := range cache.acknowledgements[host] {
+		if ack.service == service && ack.contains(timestamp) {
+			return true
+		}
+	}
+	return false
+}
+
+//updateAcknowledgements returns the acknowledgements of the current ones, the value is the time of the acknowledgement.
+//Acknowledgements which are gone are stopped at now and kept until retention after it.
+func (cache Cache) updateAcknowledgements(current map[ackKey]int64, now int64, retention int64) map[string][]acknowledgement {
+	result := map[string][]acknowledgement{}
+	known := map[ackKey]bool{}
+	for host, acks := range cache.acknowledgements {
+		for _, ack := range acks {
+			if ack.stopped == 0 {
+				if _, found := current[ack.ackKey]; found {
+					known[ack.ackKey] = true
+				} else {
+					ack.stopped = now
+				}
+			}
+			if ack.stopped == 0 || ack.stopped+retention >= now {
+				result[host] = append(result[host], ack)
+			}
+		}
+	}
+	for key, start := range current {
+		if !known[key] {
+			result[key.host] = append(result[key.host], acknowledgement{key, start, 0})
+		}
+	}
+	return result
+}
+
+//update replaces the downtimes by the current ones of livestatus and keeps what is known about the previous ones.
+//Downtimes which are gone before their end are stopped at now, removed downtimes are kept until retention after their end.
+func (cache Cache) update(current map[string]downtime, now int64, retention int64) Cache {
+	result := Cache{downtimes: map[string]map[string]downtime{}, acknowledgements: cache.acknowledgements}
+	for id, d := range current {
+		if previous, found := cache.downtimes[d.host][id]; found {
+			d.triggered = previous.triggered
+		}
+		result.addDowntime(id, d)
+	}
+	for _, downtimes := range cache.downtimes {
+		for id, d := range downtimes {
+			if _, found := current[id]; found {
+				continue
+			}
+			if d.stopped == 0 {
+				d.stopped = now
+			}
+			if from, to, ok := d.interval(); ok && from <= now && to+retention >= now {
+				result.addDowntime(id, d)
+			}
+		}
+	}
+	return result
+}
+
+//addLogEntry applies a downtime alert of the livestatus log. STARTED triggers the flexible downtimes of the host/service,
+//which were waiting at that time. CANCELLED sets the exact end of the removed downtimes which were active at that time.
+func (cache Cache) addLogEntry(host, serviceDescription, stateType string, timestamp int64) {
+	downtimes := cache.downtimes[host]
+	for id, d := range downtimes {
+		if d.serviceDescription != serviceDescription {
+			continue
+		}
+		switch stateType {
+		case "STARTED":
+			if !d.fixed && d.start <= timestamp && timestamp <= d.end && (d.triggered == 0 || timestamp < d.triggered) {
+				d.triggered = timestamp
+			}
+		case "CANCELLED":
+			if from, _, ok := d.interval(); ok && d.stopped > 0 && from <= timestamp && timestamp < d.stopped {
+				d.stopped = timestamp
+			}
+		}
+		downtimes[id] = d
+	}
+}
+
+//objectInfo contains the groups and the whitelisted custom variables of a host or service.
+type objectInfo struct {
+	groups          []string
+	contactGroups   []string
+	customVariables map[string]string
+}
+
+//objectCache contains the groups and custom variables of the hosts and services.
+type objectCache struct {
+	hosts    map[string]objectInfo
+	services map[string]map[string]objectInfo
+}
+
+func newObjectCache() objectCache {
+	return objectCache{hosts: map[string]objectInfo{}, services: map[string]map[string]objectInfo{}}
+}
+
+func (cache objectCache) addService(host, service string, info objectInfo) {
+	if _, hostExists := cache.services[host]; !hostExists {
+		cache.services[host] = map[string]objectInfo{}
+	}
+	cache.services[host][service] = info
+}
+
+//tags returns the custom variables of the host, overwritten by the ones of the service, and the groups.
+//Hostchecks get the contact groups of the host, services their own.
+func (cache objectCache) tags(host, service string, groups bool) map[string]string {
+	hostInfo, hostFound := cache.hosts[host]
+	serviceInfo, serviceFound := cache.services[host][service]
+	if !hostFound && !serviceFound {
+		return nil
+	}
+	result := map[string]string{}
+	for name, value := range hostInfo.customVariables {
+		result[name] = value
+	}
+	contactGroups := hostInfo.contactGroups
+	if service != "" {
+		for name, value := range serviceInfo.customVariables {
+			result[name] = value
+		}
+		contactGroups = serviceInfo.contactGroups
+	}
+	if groups {
+		addGroupTag(result, "hostgroups", hostInfo.groups)
+		if service != "" {
+			addGroupTag(result, "servicegroups", serviceInfo.groups)
 		}
+		addGroupTag(result, "contactgroups", contactGroups)
+	}
+	return result
+}
+
+func addGroupTag(tags map[string]string, name string, groups []string) {
+	if len(groups) > 0 {
+		tags[name] = strings.Join(groups, ",")
 	}
 }
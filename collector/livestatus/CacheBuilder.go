@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/griesbacher/nagflux/logging"
 	"github.com/kdar/factorlog"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,36 +18,86 @@ type CacheBuilder struct {
 	log                 *factorlog.FactorLog
 	downtimeCache       Cache
 	mutex               *sync.Mutex
+	//logSince is the start of the next downtime log query, 0 before the first one.
+	logSince int64
+	//customVariables is the whitelist of custom variables, the names are upper case without the leading underscore.
+	customVariables map[string]bool
+	groups          bool
+	objects         objectCache
 }
 
 const (
 	//Updateinterval on livestatus data.
 	intervalToCheckLivestatusCache = time.Duration(30) * time.Second
-	//QueryForServicesInDowntime livestatusquery for services in downtime.
-	QueryForServicesInDowntime = `GET services
-Columns: downtimes host_name display_name
-Filter: scheduled_downtime_depth > 0
-OutputFormat: csv
+	//Updateinterval on the groups and custom variables, which change rarely.
+	intervalToCheckLivestatusObjects = time.Duration(5) * time.Minute
+	//Time in which removed downtimes are kept, so late perfdata is still marked correctly.
+	downtimeRetention = time.Duration(24) * time.Hour
+	//Overlap of the log queries, so downtime alerts which are written late are not missed.
+	downtimeLogOverlap = time.Duration(1) * time.Minute
+	//QueryForDowntimeIntervals livestatusquery for the interval of every downtime
+	QueryForDowntimeIntervals = `GET downtimes
+Columns: id host_name service_display_name service_description start_time end_time fixed duration
+OutputFormat: json
 
 `
-	//QueryForHostsInDowntime livestatusquery for hosts in downtime
-	QueryForHostsInDowntime = `GET hosts
-Columns: downtimes name
-Filter: scheduled_downtime_depth > 0
-OutputFormat: csv
+	//QueryForDowntimeLog livestatusquery for the downtime alerts since the given time, which trigger the flexible downtimes
+	QueryForDowntimeLog = `GET log
+Columns: time host_name service_description state_type
+Filter: type = HOST DOWNTIME ALERT
+Filter: type = SERVICE DOWNTIME ALERT
+Or: 2
+Filter: time < %d
+Negate:
+OutputFormat: json
 
 `
-	//QueryForDowntimeid livestatusquery for downtime start/end
-	QueryForDowntimeid = `GET downtimes
-Columns: id start_time entry_time
-OutputFormat: csv
+	//QueryForAcknowledgedHosts livestatusquery for hosts with an acknowledged problem
+	QueryForAcknowledgedHosts = `GET hosts
+Columns: name
+Filter: acknowledged = 1
+OutputFormat: json
+
+`
+	//QueryForAcknowledgedServices livestatusquery for services with an acknowledged problem
+	QueryForAcknowledgedServices = `GET services
+Columns: host_name display_name
+Filter: acknowledged = 1
+OutputFormat: json
+
+`
+	//QueryForAcknowledgementComments livestatusquery for the comments of the acknowledgements, which contain their time
+	QueryForAcknowledgementComments = `GET comments
+Columns: host_name service_display_name entry_time
+Filter: entry_type = 4
+OutputFormat: json
+
+`
+	//QueryForHostObjects livestatusquery for the groups and custom variables of the hosts
+	QueryForHostObjects = `GET hosts
+Columns: name groups contact_groups custom_variable_names custom_variable_values
+OutputFormat: json
+
+`
+	//QueryForServiceObjects livestatusquery for the groups and custom variables of the services
+	QueryForServiceObjects = `GET services
+Columns: host_name display_name groups contact_groups custom_variable_names custom_variable_values
+OutputFormat: json
 
 `
 )
 
 //NewLivestatusCacheBuilder constructor, which also starts it immediately.
-func NewLivestatusCacheBuilder(livestatusConnector *Connector) *CacheBuilder {
-	cache := &CacheBuilder{livestatusConnector, make(chan bool, 2), logging.GetLogger(), Cache{make(map[string]map[string]string)}, &sync.Mutex{}}
+//customVariables is the whitelist of custom variables, like _LOCATION, and groups enables the host, service and
+//contact groups, both are returned by Tags.
+func NewLivestatusCacheBuilder(livestatusConnector *Connector, customVariables []string, groups bool) *CacheBuilder {
+	cache := &CacheBuilder{livestatusConnector, make(chan bool, 2), logging.GetLogger(), newCache(), &sync.Mutex{},
+		0, map[string]bool{}, groups, newObjectCache()}
+	for _, name := range customVariables {
+		if name = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(name), "_")); name != "" {
+			cache.customVariables[name] = true
+		}
+	}
 	go cache.run(intervalToCheckLivestatusCache)
 	return cache
 }
@@ -58,94 +109,239 @@ func (builder *CacheBuilder) Stop() {
 	builder.log.Debug("LivestatusCacheBuilder stopped")
 }
 
-//Loop which caches livestatus downtimes and waits to quit.
+//Loop which caches livestatus downtimes, groups and custom variables and waits to quit.
 func (builder *CacheBuilder) run(checkInterval time.Duration) {
-	newCache := builder.createLivestatusCache()
-	builder.mutex.Lock()
-	builder.downtimeCache = newCache
-	builder.mutex.Unlock()
+	var objectsUpdated time.Time
 	for {
+		if newCache, err := builder.createLivestatusCache(); err != nil {
+			builder.log.Warn("Could not cache the downtimes: ", err)
+		} else {
+			builder.mutex.Lock()
+			builder.downtimeCache = newCache
+			builder.mutex.Unlock()
+		}
+		if (builder.groups || len(builder.customVariables) > 0) && time.Since(objectsUpdated) >= intervalToCheckLivestatusObjects {
+			if newObjects, err := builder.createObjectCache(); err != nil {
+				builder.log.Warn("Could not cache the groups and custom variables: ", err)
+			} else {
+				builder.mutex.Lock()
+				builder.objects = newObjects
+				builder.mutex.Unlock()
+				objectsUpdated = time.Now()
+			}
+		}
 		select {
 		case <-builder.quit:
 			builder.quit <- true
 			return
 		case <-time.After(checkInterval):
-			newCache = builder.createLivestatusCache()
-			builder.mutex.Lock()
-			builder.downtimeCache = newCache
-			builder.mutex.Unlock()
 		}
 	}
 }
 
-//Builds host/service map which are in downtime
-func (builder CacheBuilder) createLivestatusCache() Cache {
-	result := Cache{downtime: make(map[string]map[string]string)}
-	downtimeCsv := make(chan []string)
-	finishedDowntime := make(chan bool)
-	hostServiceCsv := make(chan []string)
-	finished := make(chan bool)
-	go builder.livestatusConnector.connectToLivestatus(QueryForDowntimeid, downtimeCsv, finishedDowntime)
-	go builder.livestatusConnector.connectToLivestatus(QueryForHostsInDowntime, hostServiceCsv, finished)
-	go builder.livestatusConnector.connectToLivestatus(QueryForServicesInDowntime, hostServiceCsv, finished)
-
-	jobsFinished := 0
-	//contains id to starttime
-	downtimes := map[string]string{}
-	for jobsFinished < 2 {
-		select {
-		case downtimesLine := <-downtimeCsv:
-			if len(downtimesLine) < 3 {
-				builder.log.Debug("downtimesLine", downtimesLine)
-				break
-			}
-			startTime, _ := strconv.Atoi(downtimesLine[1])
-			entryTime, _ := strconv.Atoi(downtimesLine[2])
-			latestTime := startTime
-			if startTime < entryTime {
-				latestTime = entryTime
-			}
-			for _, id := range strings.Split(downtimesLine[0], ",") {
-				downtimes[id] = fmt.Sprint(latestTime)
-			}
-		case <-finishedDowntime:
-			for jobsFinished < 2 {
-				select {
-				case hostService := <-hostServiceCsv:
-					for _, id := range strings.Split(hostService[0], ",") {
-						if len(hostService) == 2 {
-							result.addDowntime(hostService[1], "", downtimes[id])
-						} else if len(hostService) == 3 {
-							result.addDowntime(hostService[1], hostService[2], downtimes[id])
-						}
-					}
-				case <-finished:
-					jobsFinished++
-				case <-time.After(intervalToCheckLivestatusCache / 3):
-					builder.log.Info("Livestatus timed out...(host/service)")
-					return result
-				}
-			}
-		case <-time.After(intervalToCheckLivestatusCache / 3):
-			builder.log.Info("Livestatus timed out...(downtimes)")
-			return result
+//Updates the downtime intervals by the current downtimes and the downtime alerts of the log, which trigger the flexible
+//ones and contain the exact end of cancelled ones, and the acknowledgements. Only the run loop may call it, because it
+//reads the previous cache.
+func (builder *CacheBuilder) createLivestatusCache() (Cache, error) {
+	rows, err := builder.livestatusConnector.queryRows(QueryForDowntimeIntervals)
+	if err != nil {
+		return Cache{}, err
+	}
+	now := time.Now()
+	current := map[string]downtime{}
+	since := now.Unix()
+	for _, row := range rows {
+		d := downtime{
+			host:               row.String("host_name"),
+			service:            row.String("service_display_name"),
+			serviceDescription: row.String("service_description"),
+			start:              row.Int("start_time"),
+			end:                row.Int("end_time"),
+			fixed:              row.Int("fixed") != 0,
+			duration:           row.Int("duration"),
+		}
+		current[row.String("id")] = d
+		if d.start < since {
+			since = d.start
+		}
+	}
+	builder.mutex.Lock()
+	result := builder.downtimeCache.update(current, now.Unix(), int64(downtimeRetention/time.Second))
+	logSince := builder.logSince
+	builder.mutex.Unlock()
+
+	if acknowledged, err := builder.queryAcknowledgements(now.Unix()); err != nil {
+		builder.log.Warn("Could not query the acknowledgements: ", err)
+	} else {
+		result.acknowledgements = result.updateAcknowledgements(acknowledged, now.Unix(), int64(downtimeRetention/time.Second))
+	}
+
+	//the first query reaches back to the start of the oldest downtime, the later ones overlap the previous
+	if logSince > 0 {
+		since = logSince
+	}
+	entries, err := builder.livestatusConnector.queryRows(fmt.Sprintf(QueryForDowntimeLog, since))
+	if err != nil {
+		//the intervals are still up to date, the log is queried again from the same time in the next run
+		builder.log.Warn("Could not query the downtime alerts: ", err)
+		return result, nil
+	}
+	for _, entry := range entries {
+		result.addLogEntry(entry.String("host_name"), entry.String("service_description"), entry.String("state_type"), entry.Int("time"))
+	}
+	builder.mutex.Lock()
+	builder.logSince = now.Add(-downtimeLogOverlap).Unix()
+	builder.mutex.Unlock()
+	return result, nil
+}
+
+//queryAcknowledgements returns the acknowledged hosts and services with the time of their oldest acknowledgement
+//comment. If the comment is missing, e.g. it has been deleted, now is used.
+func (builder *CacheBuilder) queryAcknowledgements(now int64) (map[ackKey]int64, error) {
+	result := map[ackKey]int64{}
+	hosts, err := builder.livestatusConnector.queryRows(QueryForAcknowledgedHosts)
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range hosts {
+		result[ackKey{host.String("name"), ""}] = now
+	}
+	services, err := builder.livestatusConnector.queryRows(QueryForAcknowledgedServices)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		result[ackKey{service.String("host_name"), service.String("display_name")}] = now
+	}
+	comments, err := builder.livestatusConnector.queryRows(QueryForAcknowledgementComments)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		key := ackKey{comment.String("host_name"), comment.String("service_display_name")}
+		if start, acknowledged := result[key]; acknowledged && comment.Int("entry_time") < start {
+			result[key] = comment.Int("entry_time")
 		}
 	}
-	return result
+	return result, nil
+}
+
+//Caches the groups and whitelisted custom variables of the hosts and services.
+func (builder *CacheBuilder) createObjectCache() (objectCache, error) {
+	result := newObjectCache()
+	hosts, err := builder.livestatusConnector.queryRows(QueryForHostObjects)
+	if err != nil {
+		return result, err
+	}
+	for _, host := range hosts {
+		result.hosts[host.String("name")] = builder.objectInfo(host)
+	}
+	services, err := builder.livestatusConnector.queryRows(QueryForServiceObjects)
+	if err != nil {
+		return result, err
+	}
+	for _, service := range services {
+		result.addService(service.String("host_name"), service.String("display_name"), builder.objectInfo(service))
+	}
+	return result, nil
 }
 
-//IsServiceInDowntime returns true if the host/service is in downtime
-func (builder CacheBuilder) IsServiceInDowntime(host, service, time string) bool {
-	result := false
+//objectInfo reads the sorted groups and the whitelisted custom variables, their names become lower case tags.
+func (builder *CacheBuilder) objectInfo(row Row) objectInfo {
+	info := objectInfo{groups: row.Strings("groups"), contactGroups: row.Strings("contact_groups"), customVariables: map[string]string{}}
+	sort.Strings(info.groups)
+	sort.Strings(info.contactGroups)
+	names := row.Strings("custom_variable_names")
+	values := row.Strings("custom_variable_values")
+	for i, name := range names {
+		if i < len(values) && builder.customVariables[strings.ToUpper(name)] {
+			info.customVariables[strings.ToLower(name)] = values[i]
+		}
+	}
+	return info
+}
+
+//Tags returns the whitelisted custom variables and the groups of the host/service.
+func (builder *CacheBuilder) Tags(host, service string) map[string]string {
 	builder.mutex.Lock()
-	if _, hostExists := builder.downtimeCache.downtime[host]; hostExists {
-		if _, serviceExists := builder.downtimeCache.downtime[host][service]; serviceExists {
-			if builder.downtimeCache.downtime[host][service] <= time {
-				result = true
-			}
+	defer builder.mutex.Unlock()
+	return builder.objects.tags(host, service, builder.groups)
+}
+
+//IsServiceInDowntime returns true if the host/service, or the host of the service, was in downtime at the given unix time.
+func (builder *CacheBuilder) IsServiceInDowntime(host, service, time string) bool {
+	timestamp, err := strconv.ParseInt(time, 10, 64)
+	if err != nil {
+		return false
+	}
+	builder.mutex.Lock()
+	defer builder.mutex.Unlock()
+	return builder.downtimeCache.inDowntime(host, service, timestamp)
+}
+
+//IsAcknowledged returns true if the problem of the host/service was acknowledged at the given unix time.
+func (builder *CacheBuilder) IsAcknowledged(host, service, time string) bool {
+	timestamp, err := strconv.ParseInt(time, 10, 64)
+	if err != nil {
+		return false
+	}
+	builder.mutex.Lock()
+	defer builder.mutex.Unlock()
+	return builder.downtimeCache.isAcknowledged(host, service, timestamp)
+}
+
+//CacheBuilders contains the downtime caches of the livestatus sites, the key is the name of the site.
+type CacheBuilders map[string]*CacheBuilder
+
+//IsServiceInDowntime asks the cache of the given site, which is the site tag of the perfdata.
+//If the site is unknown, e.g. the perfdata has no site tag, it is true if the host/service is in downtime on any site.
+func (builders CacheBuilders) IsServiceInDowntime(site, host, service, time string) bool {
+	if builder, found := builders[site]; found {
+		return builder.IsServiceInDowntime(host, service, time)
+	}
+	for _, builder := range builders {
+		if builder.IsServiceInDowntime(host, service, time) {
+			return true
 		}
 	}
+	return false
+}
 
-	builder.mutex.Unlock()
-	return result
+//IsAcknowledged asks the cache of the given site like IsServiceInDowntime, without a known site any cache is asked.
+func (builders CacheBuilders) IsAcknowledged(site, host, service, time string) bool {
+	if builder, found := builders[site]; found {
+		return builder.IsAcknowledged(host, service, time)
+	}
+	for _, builder := range builders {
+		if builder.IsAcknowledged(host, service, time) {
+			return true
+		}
+	}
+	return false
+}
+
+//Tags returns the custom variables and groups of the host/service of the given site. If the site is unknown, the
+//first site in the order of their names which knows the host/service is used.
+func (builders CacheBuilders) Tags(site, host, service string) map[string]string {
+	if builder, found := builders[site]; found {
+		return builder.Tags(host, service)
+	}
+	sites := make([]string, 0, len(builders))
+	for name := range builders {
+		sites = append(sites, name)
+	}
+	sort.Strings(sites)
+	for _, name := range sites {
+		if tags := builders[name].Tags(host, service); tags != nil {
+			return tags
+		}
+	}
+	return nil
+}
+
+//Stop signals every cache to stop.
+func (builders CacheBuilders) Stop() {
+	for _, builder := range builders {
+		builder.Stop()
+	}
 }
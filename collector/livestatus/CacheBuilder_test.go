@@ -1,57 +1,148 @@
 package livestatus
 
 import (
+	"fmt"
 	"github.com/griesbacher/nagflux/logging"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
 
 func TestNewCacheBuilder(t *testing.T) {
 	logging.InitTestLogger()
-	connector := &Connector{logging.GetLogger(), "localhost:6558", "tcp"}
-	builder := NewLivestatusCacheBuilder(connector)
+	connector := &Connector{Log: logging.GetLogger(), LivestatusAddress: "localhost:6558", ConnectionType: "tcp"}
+	builder := NewLivestatusCacheBuilder(connector, nil, false)
 	if builder == nil {
 		t.Error("Constructor returned null pointer")
 	}
 }
 
-func DisabledTestServiceInDowntime(t *testing.T) {
+func TestCacheBuilder_ServiceInDowntime(t *testing.T) {
 	logging.InitTestLogger()
-	queries := map[string]string{}
-	queries[QueryForServicesInDowntime] = "1,2;host1;service1\n"
-	queries[QueryForHostsInDowntime] = "3,4;host1\n5;host2\n"
-	queries[QueryForDowntimeid] = "1;0;1\n2;2;3\n3;0;1\n4;1;2\n5;2;1\n"
-	livestatus := &MockLivestatus{"localhost:6558", "tcp", queries, true}
-	go livestatus.StartMockLivestatus()
-	connector := &Connector{logging.GetLogger(), livestatus.LivestatusAddress, livestatus.ConnectionType}
+	mockLive := &MockLivestatus{Queries: map[string]string{
+		QueryForDowntimeIntervals: `[[1,"host1","service 1","service1",100,200,1,0],[2,"host1","","",300,1000,0,50],` +
+			`[3,"host2","","",400,500,1,0]]`,
+		fmt.Sprintf(QueryForDowntimeLog, 50): `[[350,"host1","","STARTED"]]`,
+		QueryForAcknowledgedHosts:            `[["host2"]]`,
+		QueryForAcknowledgedServices:         `[["host1","service 1"]]`,
+		QueryForAcknowledgementComments:      `[["host1","service 1",300],["host1","service 1",200],["host3","",100]]`,
+	}}
+	listener, _ := startCountingLivestatus(t, mockLive, false)
+	defer listener.Close()
+	connector := &Connector{Log: logging.GetLogger(), LivestatusAddress: listener.Addr().String(), ConnectionType: "tcp"}
+	cacheBuilder := &CacheBuilder{livestatusConnector: connector, log: logging.GetLogger(), mutex: &sync.Mutex{},
+		downtimeCache: newCache(), logSince: 50}
+	cache, err := cacheBuilder.createLivestatusCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheBuilder.downtimeCache = cache
+	if cacheBuilder.logSince <= 50 {
+		t.Errorf("The next log query should start later, actual: %d", cacheBuilder.logSince)
+	}
 
-	cacheBuilder := NewLivestatusCacheBuilder(connector)
-	time.Sleep(time.Duration(2) * time.Second)
+	tests := []struct {
+		host, service, time string
+		expected            bool
+	}{
+		{"host1", "service 1", "100", true},
+		{"host1", "service 1", "201", false},
+		{"host1", "", "150", false},
+		//the flexible host downtime was triggered at 350 and is inherited by the services
+		{"host1", "", "349", false},
+		{"host1", "", "350", true},
+		{"host1", "service 1", "400", true},
+		{"host1", "service 1", "401", false},
+		{"host2", "", "450", true},
+		{"host2", "", "invalid", false},
+	}
+	for _, test := range tests {
+		if actual := cacheBuilder.IsServiceInDowntime(test.host, test.service, test.time); actual != test.expected {
+			t.Errorf("%+v: expected: %t, actual: %t", test, test.expected, actual)
+		}
+	}
 
-	cacheBuilder.Stop()
-	livestatus.StopMockLivestatus()
+	//the oldest comment is the start, without a comment the acknowledgement starts now
+	if !cacheBuilder.IsAcknowledged("host1", "service 1", "200") || cacheBuilder.IsAcknowledged("host1", "service 1", "199") {
+		t.Error(`"host1","service 1" should be acknowledged since 200`)
+	}
+	if cacheBuilder.IsAcknowledged("host2", "", "1000") || !cacheBuilder.IsAcknowledged("host2", "", fmt.Sprint(time.Now().Unix()+1)) {
+		t.Error(`"host2" should be acknowledged since now`)
+	}
+	if cacheBuilder.IsAcknowledged("host3", "", "1000") {
+		t.Error(`"host3" is not acknowledged`)
+	}
+}
 
-	intern := map[string]map[string]string{"host1": map[string]string{"": "1", "service1": "1"}, "host2": map[string]string{"": "2"}}
-	cacheBuilder.mutex.Lock()
-	if !reflect.DeepEqual(cacheBuilder.downtimeCache.downtime, intern) {
-		t.Errorf("Internall Cache does not fit.\nExpexted:%s\nResult:%s\n", intern, cacheBuilder.downtimeCache.downtime)
+func TestCacheBuilders_IsServiceInDowntime(t *testing.T) {
+	cache := func(host, service string) *CacheBuilder {
+		return &CacheBuilder{downtimeCache: Cache{downtimes: downtimesByHost(map[string]downtime{"1": {host: host, service: service, start: 1, end: 3, fixed: true}})},
+			mutex: &sync.Mutex{}}
 	}
-	cacheBuilder.mutex.Unlock()
-	if !cacheBuilder.IsServiceInDowntime("host1", "service1", "1") {
-		t.Errorf(`"host1","service1","1" should be in downtime`)
+	builders := CacheBuilders{
+		"berlin": cache("host1", "service1"),
+		"munich": cache("host2", ""),
 	}
-	if !cacheBuilder.IsServiceInDowntime("host1", "service1", "2") {
-		t.Errorf(`"host1","service1","2" should be in downtime`)
+	tests := []struct {
+		site, host, service string
+		expected            bool
+	}{
+		{"berlin", "host1", "service1", true},
+		{"munich", "host1", "service1", false},
+		{"munich", "host2", "", true},
+		//without a known site every cache is asked
+		{"", "host1", "service1", true},
+		{"hamburg", "host2", "", true},
+		{"", "host3", "", false},
 	}
-	if cacheBuilder.IsServiceInDowntime("host1", "service1", "0") {
-		t.Errorf(`"host1","service1","0" should NOT be in downtime`)
+	for _, test := range tests {
+		if actual := builders.IsServiceInDowntime(test.site, test.host, test.service, "2"); actual != test.expected {
+			t.Errorf("%+v: expected: %t, actual: %t", test, test.expected, actual)
+		}
 	}
-	if cacheBuilder.IsServiceInDowntime("host1", "", "0") {
-		t.Errorf(`"host1","","0" should NOT be in downtime`)
+}
+
+func TestCacheBuilder_Tags(t *testing.T) {
+	logging.InitTestLogger()
+	mockLive := &MockLivestatus{Queries: map[string]string{
+		QueryForHostObjects: `[["host1",["web","linux"],["admins"],["LOCATION","SECRET"],["berlin","x"]]]`,
+		QueryForServiceObjects: `[["host1","http",["http"],["webadmins"],["LOCATION","OWNER"],["munich","bob"]],` +
+			`["host2","load",[],[],[],[]]]`,
+	}}
+	listener, _ := startCountingLivestatus(t, mockLive, false)
+	defer listener.Close()
+	connector := &Connector{Log: logging.GetLogger(), LivestatusAddress: listener.Addr().String(), ConnectionType: "tcp"}
+	builder := &CacheBuilder{livestatusConnector: connector, log: logging.GetLogger(), mutex: &sync.Mutex{},
+		customVariables: map[string]bool{"LOCATION": true, "OWNER": true}, groups: true}
+	objects, err := builder.createObjectCache()
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !cacheBuilder.IsServiceInDowntime("host1", "", "2") {
-		t.Errorf(`"host1","","2" should be in downtime`)
+	builder.objects = objects
+	builders := CacheBuilders{"berlin": builder}
+
+	data := []struct {
+		site     string
+		host     string
+		service  string
+		expected map[string]string
+	}{
+		{"berlin", "host1", "", map[string]string{"location": "berlin", "hostgroups": "linux,web", "contactgroups": "admins"}},
+		{"berlin", "host1", "http", map[string]string{"location": "munich", "owner": "bob", "hostgroups": "linux,web",
+			"servicegroups": "http", "contactgroups": "webadmins"}},
+		{"unknown", "host2", "load", map[string]string{}},
+		{"berlin", "host3", "", nil},
+	}
+	for _, d := range data {
+		if actual := builders.Tags(d.site, d.host, d.service); !reflect.DeepEqual(actual, d.expected) {
+			t.Errorf("%s %s %s: Expected: %v actual: %v", d.site, d.host, d.service, d.expected, actual)
+		}
 	}
 
+	builder.groups = false
+	expected := map[string]string{"location": "berlin"}
+	if actual := builders.Tags("berlin", "host1", ""); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %v actual: %v", expected, actual)
+	}
 }
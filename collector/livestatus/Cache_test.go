@@ -5,27 +5,142 @@ import (
 	"testing"
 )
 
-func TestAddDowntime(t *testing.T) {
-	cache := Cache{make(map[string]map[string]string)}
-	if !reflect.DeepEqual(cache.downtime, make(map[string]map[string]string)) {
-		t.Error("Cache should be empty at the beginning.")
+func downtimesByHost(downtimes map[string]downtime) map[string]map[string]downtime {
+	cache := Cache{downtimes: map[string]map[string]downtime{}}
+	for id, d := range downtimes {
+		cache.addDowntime(id, d)
 	}
+	return cache.downtimes
+}
 
-	cache.addDowntime("hostname", "servicename", "123")
-	intern := map[string]map[string]string{"hostname": map[string]string{"servicename": "123"}}
-	if !reflect.DeepEqual(cache.downtime, intern) {
-		t.Error("Added element is missing.")
+func acknowledgementsByHost(acks []acknowledgement) map[string][]acknowledgement {
+	result := map[string][]acknowledgement{}
+	for _, ack := range acks {
+		result[ack.host] = append(result[ack.host], ack)
 	}
+	return result
+}
 
-	cache.addDowntime("hostname2", "", "123")
-	intern = map[string]map[string]string{"hostname": map[string]string{"servicename": "123"}, "hostname2": map[string]string{"": "123"}}
-	if !reflect.DeepEqual(cache.downtime, intern) {
-		t.Error("Added element is missing.")
+func TestCache_InDowntime(t *testing.T) {
+	cache := Cache{downtimes: downtimesByHost(map[string]downtime{
+		"1": {host: "host1", service: "service1", start: 100, end: 200, fixed: true},
+		"2": {host: "host2", start: 100, end: 200, fixed: true, stopped: 150},
+		"3": {host: "host3", service: "service3", start: 100, end: 1000, duration: 50, triggered: 300},
+		"4": {host: "host4", service: "service4", start: 100, end: 1000, duration: 50},
+	})}
+	tests := []struct {
+		host, service string
+		timestamp     int64
+		expected      bool
+	}{
+		{"host1", "service1", 99, false},
+		{"host1", "service1", 100, true},
+		{"host1", "service1", 200, true},
+		{"host1", "service1", 201, false},
+		//service downtimes do not affect the host
+		{"host1", "", 150, false},
+		//host downtimes are inherited by the services
+		{"host2", "", 120, true},
+		{"host2", "service2", 120, true},
+		//cancelled before the end
+		{"host2", "service2", 160, false},
+		//flexible downtimes last their duration after they were triggered
+		{"host3", "service3", 299, false},
+		{"host3", "service3", 350, true},
+		{"host3", "service3", 351, false},
+		{"host4", "service4", 500, false},
+	}
+	for _, test := range tests {
+		if actual := cache.inDowntime(test.host, test.service, test.timestamp); actual != test.expected {
+			t.Errorf("%+v: expected: %t, actual: %t", test, test.expected, actual)
+		}
 	}
+}
+
+func TestCache_Update(t *testing.T) {
+	previous := Cache{downtimes: downtimesByHost(map[string]downtime{
+		"1": {host: "host1", start: 100, end: 200, fixed: true},
+		"2": {host: "host2", start: 100, end: 1000, fixed: true},
+		"3": {host: "host3", start: 100, end: 1000, duration: 50, triggered: 300},
+		"4": {host: "host4", start: 100, end: 1000, duration: 50},
+		"5": {host: "host5", start: 10, end: 100, fixed: true, stopped: 100},
+	})}
+	current := map[string]downtime{
+		"3": {host: "host3", start: 100, end: 1000, duration: 50},
+		"6": {host: "host6", start: 600, end: 700, fixed: true},
+	}
+	expected := map[string]downtime{
+		//ended, but within the retention
+		"1": {host: "host1", start: 100, end: 200, fixed: true, stopped: 500},
+		//removed before its end
+		"2": {host: "host2", start: 100, end: 1000, fixed: true, stopped: 500},
+		//the trigger is kept
+		"3": {host: "host3", start: 100, end: 1000, duration: 50, triggered: 300},
+		"6": {host: "host6", start: 600, end: 700, fixed: true},
+	}
+	//4 has never been triggered and 5 is older than the retention
+	if actual := previous.update(current, 500, 350); !reflect.DeepEqual(actual.downtimes, downtimesByHost(expected)) {
+		t.Errorf("Expected: %+v\nactual: %+v", expected, actual.downtimes)
+	}
+}
 
-	cache.addDowntime("hostname2", "", "1")
-	intern = map[string]map[string]string{"hostname": map[string]string{"servicename": "123"}, "hostname2": map[string]string{"": "123"}}
-	if reflect.DeepEqual(cache.downtime, intern) {
-		t.Error("Added element is missing.")
+func TestCache_AddLogEntry(t *testing.T) {
+	cache := Cache{downtimes: downtimesByHost(map[string]downtime{
+		"1": {host: "host1", serviceDescription: "http", start: 100, end: 1000, duration: 50},
+		"2": {host: "host1", start: 100, end: 1000, duration: 50},
+		"3": {host: "host2", start: 100, end: 1000, fixed: true, stopped: 500},
+		"4": {host: "host3", start: 100, end: 1000, fixed: true},
+	})}
+	cache.addLogEntry("host1", "http", "STARTED", 300)
+	cache.addLogEntry("host1", "http", "STARTED", 200)
+	cache.addLogEntry("host1", "http", "STARTED", 2000)
+	cache.addLogEntry("host2", "", "CANCELLED", 400)
+	//only removed downtimes are cancelled
+	cache.addLogEntry("host3", "", "CANCELLED", 400)
+	expected := map[string]downtime{
+		"1": {host: "host1", serviceDescription: "http", start: 100, end: 1000, duration: 50, triggered: 200},
+		"2": {host: "host1", start: 100, end: 1000, duration: 50},
+		"3": {host: "host2", start: 100, end: 1000, fixed: true, stopped: 400},
+		"4": {host: "host3", start: 100, end: 1000, fixed: true},
+	}
+	if !reflect.DeepEqual(cache.downtimes, downtimesByHost(expected)) {
+		t.Errorf("Expected: %+v\nactual: %+v", expected, cache.downtimes)
+	}
+}
+
+func TestCache_Acknowledgements(t *testing.T) {
+	previous := Cache{acknowledgements: acknowledgementsByHost([]acknowledgement{
+		{ackKey{"host1", ""}, 100, 0},
+		{ackKey{"host1", "http"}, 100, 0},
+		{ackKey{"host2", ""}, 10, 50},
+	})}
+	current := map[ackKey]int64{{"host1", ""}: 100, {"host3", "load"}: 400}
+	cache := Cache{acknowledgements: previous.updateAcknowledgements(current, 500, 300)}
+	expected := []acknowledgement{
+		{ackKey{"host1", ""}, 100, 0},
+		{ackKey{"host1", "http"}, 100, 500},
+		{ackKey{"host3", "load"}, 400, 0},
+	}
+	if !reflect.DeepEqual(cache.acknowledgements, acknowledgementsByHost(expected)) {
+		t.Errorf("Expected: %+v\nactual: %+v", expected, cache.acknowledgements)
+	}
+
+	tests := []struct {
+		host, service string
+		timestamp     int64
+		expected      bool
+	}{
+		{"host1", "", 99, false},
+		{"host1", "", 1000, true},
+		{"host1", "http", 500, true},
+		{"host1", "http", 501, false},
+		//acknowledgements of the host are not inherited
+		{"host1", "load", 200, false},
+		{"host3", "load", 400, true},
+	}
+	for _, test := range tests {
+		if actual := cache.isAcknowledged(test.host, test.service, test.timestamp); actual != test.expected {
+			t.Errorf("%+v: expected: %t, actual: %t", test, test.expected, actual)
+		}
 	}
 }
@@ -3,9 +3,7 @@ package livestatus
 import (
 	"fmt"
 	"github.com/griesbacher/nagflux/collector"
-	"github.com/griesbacher/nagflux/config"
 	"github.com/griesbacher/nagflux/data"
-	"github.com/griesbacher/nagflux/helper"
 	"github.com/griesbacher/nagflux/logging"
 	"github.com/kdar/factorlog"
 	"regexp"
@@ -20,6 +18,10 @@ type Collector struct {
 	livestatusConnector *Connector
 	log                 *factorlog.FactorLog
 	logQuery            string
+	eventQuery          string
+	minutesToWait       int
+	site                string
+	deduplicator        *Deduplicator
 }
 
 const (
@@ -27,38 +29,55 @@ const (
 	intervalToCheckLivestatus = time.Duration(2) * time.Minute
 	QueryLivestatusVersion    = `GET status
 Columns: livestatus_version
-OutputFormat: csv
+OutputFormat: json
 
 `
 	//QueryIcinga2ForNotifications livestatusquery for notifications with Icinga2 Livestatus.
 	QueryIcinga2ForNotifications = `GET log
-Columns: type time contact_name message
+Columns: type time contact_name host_name service_description state_type plugin_output comment
 Filter: type ~ .*NOTIFICATION
 Filter: time < %d
 Negate:
-OutputFormat: csv
+OutputFormat: json
 
 `
 	//QueryNagiosForNotifications livestatusquery for notifications with nagioslike Livestatus.
 	QueryNagiosForNotifications = `GET log
-Columns: type time contact_name message
+Columns: type time contact_name host_name service_description state_type plugin_output comment
 Filter: type ~ .*NOTIFICATION
 Filter: time > %d
-OutputFormat: csv
+OutputFormat: json
+
+`
+	//QueryIcinga2ForEvents livestatusquery for state changes, flapping and acknowledgements with Icinga2 Livestatus.
+	QueryIcinga2ForEvents = `GET log
+Columns: type time contact_name host_name service_description state state_type plugin_output comment
+Filter: type ~ ^(HOST|SERVICE) (ALERT|FLAPPING ALERT|ACKNOWLEDGE ALERT)$
+Filter: time < %d
+Negate:
+OutputFormat: json
+
+`
+	//QueryNagiosForEvents livestatusquery for state changes, flapping and acknowledgements with nagioslike Livestatus.
+	QueryNagiosForEvents = `GET log
+Columns: type time contact_name host_name service_description state state_type plugin_output comment
+Filter: type ~ ^(HOST|SERVICE) (ALERT|FLAPPING ALERT|ACKNOWLEDGE ALERT)$
+Filter: time > %d
+OutputFormat: json
 
 `
 	//QueryForComments livestatusquery for comments
 	QueryForComments = `GET comments
 Columns: host_name service_display_name comment entry_time author entry_type
 Filter: entry_time > %d
-OutputFormat: csv
+OutputFormat: json
 
 `
 	//QueryForDowntimes livestatusquery for downtimes
 	QueryForDowntimes = `GET downtimes
 Columns: host_name service_display_name comment entry_time author end_time
 Filter: entry_time > %d
-OutputFormat: csv
+OutputFormat: json
 
 `
 	//Nagios nagioslike Livestatus
@@ -70,13 +89,20 @@ OutputFormat: csv
 )
 
 //NewLivestatusCollector constructor, which also starts it immediately.
-func NewLivestatusCollector(jobs collector.ResultQueues, livestatusConnector *Connector, detectVersion string) *Collector {
+//minutesToWait is used by the version detection, the site is added as tag to every message if it is not empty.
+//The deduplicator drops the events which have been emitted by a previous query, nil disables it.
+func NewLivestatusCollector(jobs collector.ResultQueues, livestatusConnector *Connector, detectVersion string, minutesToWait int,
+	site string, deduplicator *Deduplicator) *Collector {
 	live := &Collector{
 		quit:                make(chan bool, 2),
 		jobs:                jobs,
 		livestatusConnector: livestatusConnector,
 		log:                 logging.GetLogger(),
 		logQuery:            QueryNagiosForNotifications,
+		eventQuery:          QueryNagiosForEvents,
+		minutesToWait:       minutesToWait,
+		site:                site,
+		deduplicator:        deduplicator,
 	}
 	if detectVersion == "" {
 		switch getLivestatusVersion(live) {
@@ -85,6 +111,7 @@ func NewLivestatusCollector(jobs collector.ResultQueues, livestatusConnector *Co
 		case Icinga2:
 			live.log.Info("Livestatus type: Icinga2")
 			live.logQuery = QueryIcinga2ForNotifications
+			live.eventQuery = QueryIcinga2ForEvents
 		case Naemon:
 			live.log.Info("Livestatus type: Naemon")
 		}
@@ -95,6 +122,7 @@ func NewLivestatusCollector(jobs collector.ResultQueues, livestatusConnector *Co
 		case "Icinga2":
 			live.log.Info("Setting Livestatus version to: Icinga2")
 			live.logQuery = QueryIcinga2ForNotifications
+			live.eventQuery = QueryIcinga2ForEvents
 		case "Naemon":
 			live.log.Info("Setting Livestatus version to: Naemon")
 		default:
@@ -114,38 +142,43 @@ func (live *Collector) Stop() {
 
 //Loop which checks livestats for data or waits to quit.
 func (live Collector) run() {
-	live.queryData()
-	for {
-		select {
-		case <-live.quit:
-			live.quit <- true
-			return
-		case <-time.After(intervalToCheckLivestatus):
-			live.queryData()
-		}
-	}
+	poll(live.quit, intervalToCheckLivestatus, live.queryData, live.log)
 }
 
-//Queries livestatus and returns the data to the gobal queue
-func (live Collector) queryData() {
+//Queries livestatus and returns the data to the gobal queue, the error tells how many queries failed.
+func (live Collector) queryData() error {
 	printables := make(chan collector.Printable)
 	finished := make(chan bool)
 	go live.requestPrintablesFromLivestatus(live.logQuery, true, printables, finished)
 	go live.requestPrintablesFromLivestatus(QueryForComments, true, printables, finished)
 	go live.requestPrintablesFromLivestatus(QueryForDowntimes, true, printables, finished)
+	go live.requestPrintablesFromLivestatus(live.eventQuery, true, printables, finished)
 	jobsFinished := 0
-	for jobsFinished < 3 {
+	jobsFailed := 0
+	for jobsFinished < 4 {
 		select {
-		case job := <-printables:
-			for _, j := range live.jobs {
-				j <- job
+		case printable := <-printables:
+			if live.deduplicator != nil && !live.deduplicator.IsNew(printable) {
+				break
+			}
+			for _, job := range live.jobs.Rewrite(printable) {
+				for _, j := range live.jobs.For(job) {
+					j <- job
+				}
 			}
-		case <-finished:
+		case result := <-finished:
 			jobsFinished++
+			if !result {
+				jobsFailed++
+			}
 		case <-time.After(intervalToCheckLivestatus):
 			live.log.Warn("Livestatus timed out... (Collector.queryData())")
 		}
 	}
+	if jobsFailed > 0 {
+		return fmt.Errorf("%d of %d livestatus queries failed on %s", jobsFailed, jobsFinished, live.livestatusConnector.LivestatusAddress)
+	}
+	return nil
 }
 
 func (live Collector) requestPrintablesFromLivestatus(query string, addTimestampToQuery bool, printables chan collector.Printable, outerFinish chan bool) {
@@ -154,40 +187,26 @@ func (live Collector) requestPrintablesFromLivestatus(query string, addTimestamp
 		queryWithTimestamp = addTimestampToLivestatusQuery(query)
 	}
 
-	csv := make(chan []string)
+	rows := make(chan Row)
 	finished := make(chan bool)
-	go live.livestatusConnector.connectToLivestatus(queryWithTimestamp, csv, finished)
+	go live.livestatusConnector.connectToLivestatus(queryWithTimestamp, rows, finished)
 
 	for {
 		select {
-		case line := <-csv:
+		case row := <-rows:
 			switch query {
-			case QueryNagiosForNotifications:
-				if printable := live.handleQueryForNotifications(line); printable != nil {
-					printables <- printable
-				}
-			case QueryIcinga2ForNotifications:
-				if printable := live.handleQueryForNotifications(line); printable != nil {
+			case QueryNagiosForNotifications, QueryIcinga2ForNotifications:
+				if printable := live.handleQueryForNotifications(row); printable != nil {
 					printables <- printable
 				}
+			case QueryNagiosForEvents, QueryIcinga2ForEvents:
+				printables <- newEventData(row, live.site)
 			case QueryForComments:
-				if len(line) == 6 {
-					printables <- CommentData{collector.AllFilterable, Data{line[0], line[1], line[2], line[3], line[4]}, line[5]}
-				} else {
-					live.log.Warn("QueryForComments out of range", line)
-				}
+				printables <- CommentData{collector.AllFilterable, live.newData(row), row.String("entry_type")}
 			case QueryForDowntimes:
-				if len(line) == 6 {
-					printables <- DowntimeData{collector.AllFilterable, Data{line[0], line[1], line[2], line[3], line[4]}, line[5]}
-				} else {
-					live.log.Warn("QueryForDowntimes out of range", line)
-				}
+				printables <- DowntimeData{collector.AllFilterable, live.newData(row), row.String("end_time")}
 			case QueryLivestatusVersion:
-				if len(line) == 1 {
-					printables <- collector.SimplePrintable{Filterable: collector.AllFilterable, Text: line[0], Datatype: data.InfluxDB}
-				} else {
-					live.log.Warn("QueryLivestatusVersion out of range", line)
-				}
+				printables <- collector.SimplePrintable{Filterable: collector.AllFilterable, Text: row.String("livestatus_version"), Datatype: data.InfluxDB}
 			default:
 				live.log.Fatal("Found unknown query type" + query)
 			}
@@ -200,37 +219,35 @@ func (live Collector) requestPrintablesFromLivestatus(query string, addTimestamp
 	}
 }
 
+//newData reads the columns of comments and downtimes.
+func (live Collector) newData(row Row) Data {
+	return Data{row.String("host_name"), row.String("service_display_name"), row.String("comment"), row.String("entry_time"), row.String("author"), live.site}
+}
+
 func addTimestampToLivestatusQuery(query string) string {
 	return fmt.Sprintf(query, time.Now().Add(intervalToCheckLivestatus/100*-150).Unix())
 }
 
-func (live Collector) handleQueryForNotifications(line []string) *NotificationData {
-	switch line[0] {
-	case "HOST NOTIFICATION":
-		if len(line) == 10 {
-			//Custom: host_name, "", message, timestamp, author, notification_type, state
-			return &NotificationData{collector.AllFilterable, Data{line[4], "", line[9], line[1], line[8]}, line[0], line[5]}
-		} else if len(line) == 9 {
-			return &NotificationData{collector.AllFilterable, Data{line[4], "", line[7], line[1], line[2]}, line[0], line[5]}
-		} else if len(line) == 8 {
-			return &NotificationData{collector.AllFilterable, Data{line[4], "", line[7], line[1], line[2]}, line[0], line[5]}
-		} else {
-			live.log.Warn("HOST NOTIFICATION, undefinded linelenght: ", len(line), " Line:", helper.SPrintStringSlice(line))
+//handleQueryForNotifications converts host and service notifications, the level is the state_type like CRITICAL or
+//CUSTOM (OK). The comment of custom notifications replaces the plugin output, if the livestatus provides it.
+func (live Collector) handleQueryForNotifications(row Row) *NotificationData {
+	switch notificationType := row.String("type"); notificationType {
+	case "HOST NOTIFICATION", "SERVICE NOTIFICATION":
+		message := row.String("plugin_output")
+		if comment := row.String("comment"); comment != "" {
+			message = comment
 		}
-	case "SERVICE NOTIFICATION":
-		if len(line) == 11 {
-			//Custom
-			return &NotificationData{collector.AllFilterable, Data{line[4], line[5], line[10], line[1], line[9]}, line[0], line[6]}
-		} else if len(line) == 10 || len(line) == 9 {
-			return &NotificationData{collector.AllFilterable, Data{line[4], line[5], line[8], line[1], line[2]}, line[0], line[6]}
-		} else {
-			live.log.Warn("SERVICE NOTIFICATION, undefinded linelenght: ", len(line), " Line:", helper.SPrintStringSlice(line))
+		return &NotificationData{
+			collector.AllFilterable,
+			Data{row.String("host_name"), row.String("service_description"), message, row.String("time"), row.String("contact_name"), live.site},
+			notificationType,
+			row.String("state_type"),
 		}
 	default:
-		if strings.Contains(line[0], "NOTIFICATION SUPPRESSED") {
-			live.log.Debugf("Ignoring suppressed Notification: '%s', Line: %s", line[0], helper.SPrintStringSlice(line))
+		if strings.Contains(notificationType, "NOTIFICATION SUPPRESSED") {
+			live.log.Debugf("Ignoring suppressed Notification: '%s', Row: %v", notificationType, row)
 		} else {
-			live.log.Warnf("The notification type is unknown: '%s', whole line: '%s'", line[0], helper.SPrintStringSlice(line))
+			live.log.Warnf("The notification type is unknown: '%s', whole row: '%v'", notificationType, row)
 		}
 	}
 	return nil
@@ -243,12 +260,14 @@ func getLivestatusVersion(live *Collector) int {
 	live.requestPrintablesFromLivestatus(QueryLivestatusVersion, false, printables, finished)
 	i := 0
 	oneMinute := time.Duration(1) * time.Minute
-	roundsToWait := config.GetConfig().Livestatus.MinutesToWait
+	roundsToWait := live.minutesToWait
 Loop:
 	for roundsToWait != 0 {
 		select {
 		case versionPrintable := <-printables:
-			version = versionPrintable.PrintForInfluxDB("0")
+			if simple, ok := versionPrintable.(collector.SimplePrintable); ok {
+				version = simple.Text
+			}
 			break Loop
 		case <-time.After(oneMinute):
 			if i < roundsToWait {
@@ -1,9 +1,11 @@
 package livestatus
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/griesbacher/nagflux/collector"
 	"github.com/griesbacher/nagflux/logging"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -21,7 +23,7 @@ func TestNewLivestatusCollector(t *testing.T) {
 		LivestatusAddress: "localhost:6559",
 		ConnectionType:    "tcp",
 	}
-	collector := NewLivestatusCollector(make(collector.ResultQueues), connector, "")
+	collector := NewLivestatusCollector(collector.NewResultQueues(), connector, "", 0, "", nil)
 	if collector == nil {
 		t.Error("Constructor returned null pointer")
 	}
@@ -33,3 +35,27 @@ func TestAddTimestampToLivestatusQuery(t *testing.T) {
 		t.Error("addTimestampToLivestatusQuery has changed")
 	}
 }
+
+func TestHandleQueryForNotifications(t *testing.T) {
+	logging.InitTestLogger()
+	live := Collector{log: logging.GetLogger(), site: "berlin"}
+	row := func(typ, service, stateType, output, comment string) Row {
+		return Row{"type": typ, "time": json.Number("1458988932"), "contact_name": "admin", "host_name": "host 1",
+			"service_description": service, "state_type": stateType, "plugin_output": output, "comment": comment}
+	}
+	tests := []struct {
+		row      Row
+		expected *NotificationData
+	}{
+		{row("SERVICE NOTIFICATION", "load", "CRITICAL", "load; 5.0", ""), &NotificationData{collector.AllFilterable,
+			Data{"host 1", "load", "load; 5.0", "1458988932", "admin", "berlin"}, "SERVICE NOTIFICATION", "CRITICAL"}},
+		{row("HOST NOTIFICATION", "", "CUSTOM (UP)", "PING OK", "all; fine"), &NotificationData{collector.AllFilterable,
+			Data{"host 1", "", "all; fine", "1458988932", "admin", "berlin"}, "HOST NOTIFICATION", "CUSTOM (UP)"}},
+		{row("SERVICE NOTIFICATION SUPPRESSED", "load", "CRITICAL", "", ""), nil},
+	}
+	for _, test := range tests {
+		if result := live.handleQueryForNotifications(test.row); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Expected: %v, actual: %v", test.expected, result)
+		}
+	}
+}
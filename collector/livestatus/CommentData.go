@@ -2,7 +2,6 @@ package livestatus
 
 import (
 	"github.com/griesbacher/nagflux/collector"
-	"github.com/griesbacher/nagflux/helper"
 	"github.com/griesbacher/nagflux/logging"
 )
 
@@ -13,33 +12,13 @@ type CommentData struct {
 	entryType string
 }
 
-func (comment *CommentData) sanitizeValues() {
-	comment.Data.sanitizeValues()
-	comment.entryType = helper.SanitizeInfluxInput(comment.entryType)
+//Points converts the comment into a messages point
+func (comment CommentData) Points() []collector.Point {
+	return comment.genPoint(comment.Filterable, commentIDToText(comment.entryType), comment.comment, comment.entryTime)
 }
 
-//PrintForInfluxDB prints the data in influxdb lineformat
-func (comment CommentData) PrintForInfluxDB(version string) string {
-	comment.sanitizeValues()
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("0.9") {
-		var tags string
-		if text := commentIDToText(comment.entryType); text != "" {
-			tags = ",type=" + text
-		}
-		return comment.genInfluxLine(tags)
-	}
-	logging.GetLogger().Criticalf("This influxversion [%s] given in the config is not supported", version)
-	panic("")
-}
-
-//PrintForElasticsearch prints in the elasticsearch json format
-func (comment CommentData) PrintForElasticsearch(version, index string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("2.0") {
-		typ := commentIDToText(comment.entryType)
-		return comment.genElasticLineWithValue(index, typ, comment.comment, comment.entryTime)
-	}
-	logging.GetLogger().Criticalf("This influxversion [%s] given in the config is not supported", version)
-	panic("")
+func (comment CommentData) eventKey() eventKey {
+	return comment.key("comment " + comment.entryType)
 }
 
 func commentIDToText(id string) string {
@@ -1,79 +1,36 @@
 package livestatus
 
 import (
-	"fmt"
+	"github.com/griesbacher/nagflux/collector"
 	"github.com/griesbacher/nagflux/config"
 	"github.com/griesbacher/nagflux/logging"
+	"reflect"
 	"testing"
 )
 
-var PrintCommentData = []struct {
-	input         CommentData
-	outputInflux  string
-	outputElastic string
+var PointsCommentData = []struct {
+	input  CommentData
+	output []collector.Point
 }{
-	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932000"}, entryType: "1"},
-		`messages,host=host\ 1,service=service\ 1,type=comment,author=philip message="hallo world" 1458988932000000`,
-		`{"index":{"_index":"index-2016.03","_type":"messages"}}
-{"timestamp":1458988932000000,"message":"hallo world","author":"philip","host":"host 1","service":"service 1","type":"comment"}
-`},
-	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932000"}, entryType: "2"},
-		`messages,host=host\ 1,service=service\ 1,type=downtime,author=philip message="hallo world" 1458988932000000`,
-		`{"index":{"_index":"index-2016.03","_type":"messages"}}
-{"timestamp":1458988932000000,"message":"hallo world","author":"philip","host":"host 1","service":"service 1","type":"downtime"}
-`},
-	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932000"}, entryType: "3"},
-		`messages,host=host\ 1,service=service\ 1,type=flapping,author=philip message="hallo world" 1458988932000000`,
-		`{"index":{"_index":"index-2016.03","_type":"messages"}}
-{"timestamp":1458988932000000,"message":"hallo world","author":"philip","host":"host 1","service":"service 1","type":"flapping"}
-`},
-	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932000"}, entryType: "4"},
-		`messages,host=host\ 1,service=service\ 1,type=acknowledgement,author=philip message="hallo world" 1458988932000000`,
-		`{"index":{"_index":"index-2016.03","_type":"messages"}}
-{"timestamp":1458988932000000,"message":"hallo world","author":"philip","host":"host 1","service":"service 1","type":"acknowledgement"}
-`},
-	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932000"}, entryType: "5"},
-		`messages,host=host\ 1,service=service\ 1,author=philip message="hallo world" 1458988932000000`,
-		`{"index":{"_index":"index-2016.03","_type":"messages"}}
-{"timestamp":1458988932000000,"message":"hallo world","author":"philip","host":"host 1","service":"service 1","type":""}
-`},
+	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932"}, entryType: "1"},
+		[]collector.Point{messagePoint("host 1", "service 1", "comment", "philip", "hallo world", 1458988932)}},
+	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932"}, entryType: "2"},
+		[]collector.Point{messagePoint("host 1", "service 1", "downtime", "philip", "hallo world", 1458988932)}},
+	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932"}, entryType: "3"},
+		[]collector.Point{messagePoint("host 1", "service 1", "flapping", "philip", "hallo world", 1458988932)}},
+	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932"}, entryType: "4"},
+		[]collector.Point{messagePoint("host 1", "service 1", "acknowledgement", "philip", "hallo world", 1458988932)}},
+	{CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932"}, entryType: "5"},
+		[]collector.Point{messagePoint("host 1", "service 1", "", "philip", "hallo world", 1458988932)}},
 }
 
-func TestSanitizeValuesComment(t *testing.T) {
-	t.Parallel()
-	comment := CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip"}, entryType: "1"}
-	comment.sanitizeValues()
-	if comment.Data.hostName != `host\ 1` {
-		t.Errorf("The notificationType should be escaped. Expected: %s Got: %s", `host\ 1`, comment.Data.hostName)
-	}
-}
-
-func TestPrintInfluxdbComment(t *testing.T) {
-	t.Parallel()
-	logging.InitTestLogger()
-	comment := CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world"}, entryType: "1"}
-	if !didThisPanic(comment.PrintForInfluxDB, "0.8") {
-		t.Error("This should panic, due to unsuported influxdb version")
-	}
-	for _, data := range PrintCommentData {
-		actual := data.input.PrintForInfluxDB("0.9")
-		if actual != data.outputInflux {
-			t.Errorf("Print(%s): expected: %s, actual: %s", data.input, data.outputInflux, actual)
-		}
-	}
-}
-
-func TestPrintElasticsearchComment(t *testing.T) {
+func TestPointsComment(t *testing.T) {
 	logging.InitTestLogger()
-	config.InitConfigFromString(fmt.Sprintf(Config, "monthly"))
-	comment := CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932000"}, entryType: "1"}
-	if !didThatPanic(comment.PrintForElasticsearch, "1.0", "index") {
-		t.Error("This should panic, due to unsuported elasticsearch version")
-	}
-	for _, data := range PrintCommentData {
-		actual := data.input.PrintForElasticsearch("2.0", "index")
-		if actual != data.outputElastic {
-			t.Errorf("Print(%s): expected: %s, actual: %s", data.input, data.outputElastic, actual)
+	config.InitConfigFromString(Config)
+	for _, data := range PointsCommentData {
+		actual := data.input.Points()
+		if !reflect.DeepEqual(actual, data.output) {
+			t.Errorf("Points(%v): expected: %v, actual: %v", data.input, data.output, actual)
 		}
 	}
 }
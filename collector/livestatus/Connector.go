@@ -1,13 +1,25 @@
 package livestatus
 
 import (
-	"bufio"
-	"encoding/csv"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"github.com/griesbacher/nagflux/statistics"
 	"github.com/kdar/factorlog"
 	"io"
+	"io/ioutil"
 	"net"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//maxIdleConnections is the amount of connections a connector keeps alive.
+	maxIdleConnections = 4
+	//responseHeaderLength is the length of the fixed16 header: status code, space, padded body length and newline.
+	responseHeaderLength = 16
 )
 
 //Connector fetches data from livestatus.
@@ -15,53 +27,217 @@ type Connector struct {
 	Log               *factorlog.FactorLog
 	LivestatusAddress string
 	ConnectionType    string
+	//TLSConfig is used by the connection type tls.
+	TLSConfig *tls.Config
+	//DialTimeout and ReadTimeout are disabled if they are zero, the ReadTimeout applies while waiting for data.
+	DialTimeout time.Duration
+	ReadTimeout time.Duration
+	//KeepAlive reuses the connections for the next queries.
+	KeepAlive bool
+	mutex     sync.Mutex
+	idle      []net.Conn
+	stopped   bool
 }
 
-//Queries livestatus and returns an list of list outer list are lines inner elements within the line.
-func (connector Connector) connectToLivestatus(query string, result chan []string, outerFinish chan bool) {
-	var conn net.Conn
-	switch connector.ConnectionType {
-	case "tcp":
-		conn, _ = net.Dial("tcp", connector.LivestatusAddress)
-	case "file":
-		conn, _ = net.Dial("unix", connector.LivestatusAddress)
-	default:
-		connector.Log.Critical("Connection type is unknown, options are: tcp, file. Input:" + connector.ConnectionType)
-		outerFinish <- false
-		return
+//statusError is a livestatus answer with a status code other than 200.
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("Livestatus returned %d: %s", e.status, e.message)
+}
+
+//timeoutReader extends the read deadline on every read, so large answers do not time out as long as data arrives.
+type timeoutReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r timeoutReader) Read(p []byte) (int, error) {
+	if r.timeout > 0 {
+		r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	}
+	return r.conn.Read(p)
+}
+
+//NewTLSConfig creates the config for the connection type tls. The caFile replaces the system CAs, certFile and keyFile
+//are the client certificate. Every file is optional.
+func NewTLSConfig(caFile, certFile, keyFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the CA file: %s", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("The CA file contains no PEM certificate: %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load the client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+//Stop closes the idle connections, connections which are in use are closed after their query.
+func (connector *Connector) Stop() {
+	connector.mutex.Lock()
+	connector.stopped = true
+	for _, conn := range connector.idle {
+		conn.Close()
 	}
-	if conn == nil {
+	connector.idle = nil
+	connector.mutex.Unlock()
+	connector.Log.Debug("LivestatusConnector stopped")
+}
+
+//Queries livestatus and passes every row of the answer to the result.
+//outerFinish is false if the query failed, the error is logged.
+func (connector *Connector) connectToLivestatus(query string, result chan Row, outerFinish chan bool) {
+	if err := connector.queryLivestatus(query, result); err != nil {
+		connector.Log.Warnf("Livestatus query on %s failed: %s", connector.LivestatusAddress, err)
 		outerFinish <- false
 		return
 	}
+	outerFinish <- true
+}
 
-	defer conn.Close()
-	fmt.Fprintf(conn, query)
-	reader := bufio.NewReader(conn)
+//queryLivestatus sends the query, which has to use the OutputFormat json, and passes every row to the result.
+func (connector *Connector) queryLivestatus(query string, result chan Row) error {
+	rows, err := connector.queryRows(query)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		result <- row
+	}
+	return nil
+}
 
-	length := 1
-	for length > 0 {
-		message, _, err := reader.ReadLine()
+//queryRows sends the query, which has to use the OutputFormat json, and returns all rows.
+func (connector *Connector) queryRows(query string) ([]Row, error) {
+	columns := columnsOfQuery(query)
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("The query has no Columns: %q", query)
+	}
+	startTime := time.Now()
+	body, err := connector.request(connector.addHeaders(query))
+	if err != nil {
+		return nil, err
+	}
+	if promServer := statistics.GetPrometheusServer(); promServer.LivestatusQueryDuration != nil {
+		promServer.LivestatusQueryDuration.WithLabelValues(connector.LivestatusAddress).Observe(time.Since(startTime).Seconds())
+	}
+	return newRows(columns, body)
+}
+
+//addHeaders replaces the empty line at the end of the query with the headers for the fixed16 answer and keep alive.
+func (connector *Connector) addHeaders(query string) string {
+	query = strings.TrimRight(query, "\n") + "\nResponseHeader: fixed16\n"
+	if connector.KeepAlive {
+		query += "KeepAlive: on\n"
+	}
+	return query + "\n"
+}
+
+//request sends the query on an idle connection if there is one. If a reused connection is broken, e.g. closed by
+//livestatus, the query is retried on the next one.
+func (connector *Connector) request(query string) ([]byte, error) {
+	for {
+		conn, reused, err := connector.getConnection()
 		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				connector.Log.Warn(err)
-			}
+			return nil, err
 		}
-		length = len(message)
-		if length > 0 {
-			csvReader := csv.NewReader(strings.NewReader(string(message)))
-			csvReader.Comma = ';'
-			csvReader.LazyQuotes = true
-			records, err := csvReader.Read()
-			if err != nil {
-				connector.Log.Warn("Query failed while csv parsing:" + query)
-				connector.Log.Warn(string(message))
-				connector.Log.Warn(err)
-			}
-			result <- records
+		body, err := connector.roundTrip(conn, query)
+		if err == nil {
+			connector.putConnection(conn)
+			return body, nil
 		}
+		conn.Close()
+		if _, isStatus := err.(statusError); isStatus || !reused {
+			return nil, err
+		}
+		connector.Log.Debugf("Livestatus connection to %s is broken, retrying: %s", connector.LivestatusAddress, err)
+	}
+}
+
+//roundTrip writes the query and reads the answer by the length of the fixed16 header.
+func (connector *Connector) roundTrip(conn net.Conn, query string) ([]byte, error) {
+	var deadline time.Time
+	if connector.ReadTimeout > 0 {
+		deadline = time.Now().Add(connector.ReadTimeout)
+	}
+	conn.SetWriteDeadline(deadline)
+	if _, err := io.WriteString(conn, query); err != nil {
+		return nil, err
+	}
+	reader := timeoutReader{conn, connector.ReadTimeout}
+	header := make([]byte, responseHeaderLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	status, err := strconv.Atoi(string(header[0:3]))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid response header: %q", header)
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(string(header[4:])))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid response header: %q", header)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		return nil, statusError{status, strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
+
+//getConnection returns an idle connection or dials a new one, reused is true for idle ones.
+func (connector *Connector) getConnection() (conn net.Conn, reused bool, err error) {
+	connector.mutex.Lock()
+	if n := len(connector.idle); n > 0 {
+		conn = connector.idle[n-1]
+		connector.idle = connector.idle[:n-1]
+	}
+	connector.mutex.Unlock()
+	if conn != nil {
+		return conn, true, nil
+	}
+	conn, err = connector.dial()
+	return conn, false, err
+}
+
+//putConnection keeps the connection for the next query, if keep alive is enabled and the pool is not full.
+func (connector *Connector) putConnection(conn net.Conn) {
+	connector.mutex.Lock()
+	defer connector.mutex.Unlock()
+	if !connector.KeepAlive || connector.stopped || len(connector.idle) >= maxIdleConnections {
+		conn.Close()
+		return
+	}
+	connector.idle = append(connector.idle, conn)
+}
+
+//dial opens the connection of the configured type.
+func (connector *Connector) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: connector.DialTimeout}
+	switch connector.ConnectionType {
+	case "tcp":
+		return dialer.Dial("tcp", connector.LivestatusAddress)
+	case "file":
+		return dialer.Dial("unix", connector.LivestatusAddress)
+	case "tls":
+		return tls.DialWithDialer(dialer, "tcp", connector.LivestatusAddress, connector.TLSConfig)
+	default:
+		return nil, fmt.Errorf("Connection type is unknown, options are: tcp, file, tls. Input: %s", connector.ConnectionType)
 	}
-	outerFinish <- true
 }
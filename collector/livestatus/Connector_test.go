@@ -2,12 +2,21 @@ package livestatus
 
 import (
 	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
 	"github.com/griesbacher/nagflux/helper"
 	"github.com/griesbacher/nagflux/logging"
+	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -21,6 +30,8 @@ type MockLivestatus struct {
 
 var mutex = &sync.Mutex{}
 
+const testQuery = "GET test\nColumns: a b\nOutputFormat: json\n\n"
+
 func (mockLive *MockLivestatus) StartMockLivestatus() {
 	var listener net.Listener
 	var err error
@@ -53,23 +64,45 @@ func (mockLive *MockLivestatus) StartMockLivestatus() {
 	}
 }
 
+//handle answers the queries of the connection, as long as they request keep alive.
 func (mockLive *MockLivestatus) handle(conn net.Conn) {
+	defer conn.Close()
 	connReader := bufio.NewReader(conn)
-	connWriter := bufio.NewWriter(conn)
+	for mockLive.answer(connReader, conn) {
+	}
+}
+
+//answer reads one query and writes its answer, it returns true if the connection should be kept alive.
+func (mockLive *MockLivestatus) answer(connReader *bufio.Reader, conn net.Conn) bool {
 	query := ""
-	line, _ := connReader.ReadString('\n')
+	fixed16, keepAlive := false, false
+	line, err := connReader.ReadString('\n')
 	for line != "\n" {
-		query += line
-		line, _ = connReader.ReadString('\n')
+		if err != nil {
+			return false
+		}
+		switch line {
+		case "ResponseHeader: fixed16\n":
+			fixed16 = true
+		case "KeepAlive: on\n":
+			keepAlive = true
+		default:
+			query += line
+		}
+		line, err = connReader.ReadString('\n')
 	}
 	query += "\n"
 	answer, found := mockLive.Queries[query]
 	if found == false {
-		answer = "\n\n"
+		answer = "[]\n"
+	}
+	connWriter := bufio.NewWriter(conn)
+	if fixed16 {
+		fmt.Fprintf(connWriter, "%03d %11d\n", 200, len(answer))
 	}
 	connWriter.WriteString(answer)
 	connWriter.Flush()
-	conn.Close()
+	return keepAlive
 }
 
 func (mockLive *MockLivestatus) StopMockLivestatus() {
@@ -78,18 +111,18 @@ func (mockLive *MockLivestatus) StopMockLivestatus() {
 
 func TestConnectToLivestatus(t *testing.T) {
 	//Create Livestatus mock
-	livestatus := MockLivestatus{"localhost:6560", "tcp", map[string]string{"test\n\n": "foo;bar\n"}, true}
+	livestatus := MockLivestatus{"localhost:6560", "tcp", map[string]string{testQuery: `[["foo","bar"]]`}, true}
 
 	go livestatus.StartMockLivestatus()
-	connector := Connector{logging.GetLogger(), livestatus.LivestatusAddress, livestatus.ConnectionType}
+	connector := Connector{Log: logging.GetLogger(), LivestatusAddress: livestatus.LivestatusAddress, ConnectionType: livestatus.ConnectionType}
 	if err := helper.WaitForPort("tcp", "localhost:6560", time.Duration(2)*time.Second); err != nil {
 		panic(err)
 	}
-	csv := make(chan []string)
+	csv := make(chan Row)
 	finished := make(chan bool)
-	go connector.connectToLivestatus("test\n\n", csv, finished)
+	go connector.connectToLivestatus(testQuery, csv, finished)
 
-	expected := []string{"foo", "bar"}
+	expected := Row{"a": "foo", "b": "bar"}
 
 	waitingForTheEnd := true
 	for waitingForTheEnd {
@@ -109,11 +142,179 @@ func TestConnectToLivestatus(t *testing.T) {
 	}
 	livestatus.StopMockLivestatus()
 
-	connector2 := Connector{logging.GetLogger(), "/live", "file"}
-	csv2 := make(chan []string)
+	connector2 := Connector{Log: logging.GetLogger(), LivestatusAddress: "/live", ConnectionType: "file"}
+	csv2 := make(chan Row)
 	finished2 := make(chan bool)
-	go connector2.connectToLivestatus("test\n\n", csv2, finished2)
+	go connector2.connectToLivestatus(testQuery, csv2, finished2)
 	if result := <-finished2; result {
 		t.Error("Expected an error with unknown connection type")
 	}
 }
+
+func TestConnectToLivestatus_TLS(t *testing.T) {
+	logging.InitTestLogger()
+	//the test server provides a certificate for 127.0.0.1
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	livestatus := &MockLivestatus{Queries: map[string]string{testQuery: `[["foo","bar"]]`}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go livestatus.handle(conn)
+		}
+	}()
+
+	caFile, err := ioutil.TempFile("", "nagflux-livestatus-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	tlsConfig, err := NewTLSConfig(caFile.Name(), "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector := Connector{Log: logging.GetLogger(), LivestatusAddress: listener.Addr().String(), ConnectionType: "tls",
+		TLSConfig: tlsConfig, DialTimeout: time.Second, ReadTimeout: time.Second}
+	csv := make(chan Row, 1)
+	if err := connector.queryLivestatus(testQuery, csv); err != nil {
+		t.Fatal(err)
+	}
+	if row := <-csv; !reflect.DeepEqual(row, Row{"a": "foo", "b": "bar"}) {
+		t.Errorf("Expected: map[a:foo b:bar], result: %v", row)
+	}
+
+	connector.TLSConfig = &tls.Config{}
+	if err := connector.queryLivestatus(testQuery, csv); err == nil {
+		t.Error("Expected an error with an unknown CA")
+	}
+}
+
+func TestConnectToLivestatus_Errors(t *testing.T) {
+	logging.InitTestLogger()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//accepts the connection but never answers
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Duration(2) * time.Second)
+		}
+	}()
+	connector := Connector{Log: logging.GetLogger(), LivestatusAddress: listener.Addr().String(), ConnectionType: "tcp",
+		ReadTimeout: time.Duration(100) * time.Millisecond}
+	if err := connector.queryLivestatus(testQuery, make(chan Row)); err == nil {
+		t.Error("Expected a read timeout")
+	}
+	listener.Close()
+	if err := connector.queryLivestatus(testQuery, make(chan Row)); err == nil {
+		t.Error("Expected a dial error")
+	}
+
+	if _, err := NewTLSConfig("/does/not/exist", "", "", false); err == nil {
+		t.Error("Expected an error with a missing CA file")
+	}
+	if _, err := NewTLSConfig("", "/does/not/exist", "", false); err == nil {
+		t.Error("Expected an error with a missing client certificate")
+	}
+}
+
+//startCountingLivestatus serves the mock on a random port and counts the accepted connections.
+//If closeAfterAnswer is set, the connections are closed after every answer like after an idle timeout.
+func startCountingLivestatus(t *testing.T, mockLive *MockLivestatus, closeAfterAnswer bool) (net.Listener, *int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := new(int32)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(accepted, 1)
+			if closeAfterAnswer {
+				go func() {
+					mockLive.answer(bufio.NewReader(conn), conn)
+					conn.Close()
+				}()
+			} else {
+				go mockLive.handle(conn)
+			}
+		}
+	}()
+	return listener, accepted
+}
+
+func TestConnector_KeepAlive(t *testing.T) {
+	logging.InitTestLogger()
+	//semicolons, quotes and blank lines are part of the values
+	mockLive := &MockLivestatus{Queries: map[string]string{testQuery: `[["foo;bar","x\n\n\"y\""],` + "\n" + `["foo",3]]`}}
+	expected := []Row{{"a": "foo;bar", "b": "x\n\n\"y\""}, {"a": "foo", "b": json.Number("3")}}
+	for _, closeAfterAnswer := range []bool{false, true} {
+		listener, accepted := startCountingLivestatus(t, mockLive, closeAfterAnswer)
+		connector := &Connector{Log: logging.GetLogger(), LivestatusAddress: listener.Addr().String(), ConnectionType: "tcp",
+			ReadTimeout: time.Second, KeepAlive: true}
+		for i := 0; i < 3; i++ {
+			csv := make(chan Row, 10)
+			if err := connector.queryLivestatus(testQuery, csv); err != nil {
+				t.Fatalf("%t: %s", closeAfterAnswer, err)
+			}
+			close(csv)
+			var result []Row
+			for line := range csv {
+				result = append(result, line)
+			}
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("Expected: %q, result: %q", expected, result)
+			}
+		}
+		connector.Stop()
+		listener.Close()
+		if closeAfterAnswer {
+			if count := atomic.LoadInt32(accepted); count != 3 {
+				t.Errorf("Broken connections should be replaced, expected 3 connections, actual: %d", count)
+			}
+		} else if count := atomic.LoadInt32(accepted); count != 1 {
+			t.Errorf("The connection should be reused, actual connections: %d", count)
+		}
+	}
+}
+
+func TestConnector_StatusError(t *testing.T) {
+	logging.InitTestLogger()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		bufio.NewReader(conn).ReadString('\n')
+		message := "Invalid GET request, no such table 'foo'\n"
+		fmt.Fprintf(conn, "%03d %11d\n%s", 404, len(message), message)
+	}()
+	connector := &Connector{Log: logging.GetLogger(), LivestatusAddress: listener.Addr().String(), ConnectionType: "tcp"}
+	err = connector.queryLivestatus("GET foo\nColumns: name\n\n", make(chan Row))
+	if statusErr, ok := err.(statusError); !ok || statusErr.status != 404 {
+		t.Errorf("Expected the status 404, actual: %v", err)
+	}
+}
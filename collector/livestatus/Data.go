@@ -1,10 +1,11 @@
 package livestatus
 
 import (
-	"fmt"
+	"github.com/griesbacher/nagflux/collector"
 	"github.com/griesbacher/nagflux/config"
-	"github.com/griesbacher/nagflux/helper"
-	"strings"
+	"github.com/griesbacher/nagflux/logging"
+	"strconv"
+	"time"
 )
 
 //Data contains basic data extracted from livestatusqueries.
@@ -14,43 +15,55 @@ type Data struct {
 	comment            string
 	entryTime          string
 	author             string
+	//site is the name of the livestatus section the data comes from, empty for the unnamed one.
+	site string
 }
 
-//Escape all bad chars.
-func (live *Data) sanitizeValues() {
-	live.hostName = helper.SanitizeInfluxInput(live.hostName)
-	live.serviceDisplayName = helper.SanitizeInfluxInput(live.serviceDisplayName)
-	live.entryTime = helper.SanitizeInfluxInput(live.entryTime)
-	live.author = helper.SanitizeInfluxInput(live.author)
-}
-
-//Generates the Influxdb tablename.
-func (live Data) getTablename() string {
+//Generates a messages point, the type and site tags are omitted if they are empty.
+func (live Data) genPoint(filter collector.Filterable, typ, message, timestamp string) []collector.Point {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		logging.GetLogger().Warn("Livestatus timestamp is not valid: " + timestamp)
+		return nil
+	}
+	point := collector.Point{
+		Filterable:  filter,
+		Measurement: "messages",
+		Tags: map[string]string{
+			"host":    live.hostName,
+			"service": live.serviceDisplayName,
+			"author":  live.author,
+		},
+		Fields:    map[string]interface{}{"message": message},
+		Timestamp: seconds,
+		Precision: time.Second,
+	}
 	if live.serviceDisplayName == "" {
-		live.serviceDisplayName = config.GetConfig().InfluxDBGlobal.HostcheckAlias
+		point.Tags["service"] = config.GetConfig().InfluxDBGlobal.HostcheckAlias
 	}
-	return fmt.Sprintf("messages,host=%s,service=%s", live.hostName, live.serviceDisplayName)
+	if typ != "" {
+		point.Tags["type"] = typ
+	}
+	if live.site != "" {
+		point.Tags["site"] = live.site
+	}
+	return []collector.Point{point}
 }
 
-//Generates the linedata which can be parsed from influxdb
-func (live Data) genInfluxLine(tags string) string {
-	return live.genInfluxLineWithValue(tags, live.comment)
+//NewNotificationData creates a notification for collectors besides livestatus, the timestamp is in seconds.
+//notificationType is HOST NOTIFICATION or SERVICE NOTIFICATION, the level is prepended to the message.
+func NewNotificationData(filter collector.Filterable, host, service, message, timestamp, author, notificationType, level string) NotificationData {
+	return NotificationData{filter, Data{host, service, message, timestamp, author, ""}, notificationType, level}
 }
 
-//Generates the linedata which can be parsed from influxdb
-func (live Data) genInfluxLineWithValue(tags, text string) string {
-	tags += ",author=" + live.author
-	return fmt.Sprintf("%s%s message=\"%s\" %s", live.getTablename(), tags, text, helper.CastStringTimeFromSToMs(live.entryTime))
+//NewCommentData creates a comment for collectors besides livestatus, the timestamp is in seconds.
+//entryType is the livestatus entry_type: 1 comment, 2 downtime, 3 flapping and 4 acknowledgement.
+func NewCommentData(filter collector.Filterable, host, service, comment, timestamp, author, entryType string) CommentData {
+	return CommentData{filter, Data{host, service, comment, timestamp, author, ""}, entryType}
 }
 
-func (live Data) genElasticLineWithValue(index, typ, value, timestamp string) string {
-	value = strings.Replace(value, `"`, `\"`, -1)
-	if live.serviceDisplayName == "" {
-		live.serviceDisplayName = config.GetConfig().ElasticsearchGlobal.HostcheckAlias
-	}
-	head := fmt.Sprintf(`{"index":{"_index":"%s","_type":"messages"}}`, helper.GenIndex(index, timestamp)) + "\n"
-	data := fmt.Sprintf(`{"timestamp":%s,"message":"%s","author":"%s","host":"%s","service":"%s","type":"%s"}`+"\n",
-		helper.CastStringTimeFromSToMs(timestamp), value, live.author, live.hostName, live.serviceDisplayName, typ,
-	)
-	return head + data
+//NewDowntimeData creates a downtime for collectors besides livestatus, the timestamps are in seconds.
+//An empty start or end time omits the point.
+func NewDowntimeData(filter collector.Filterable, host, service, comment, startTime, endTime, author string) DowntimeData {
+	return DowntimeData{filter, Data{host, service, comment, startTime, author, ""}, endTime}
 }
@@ -1,52 +1,55 @@
 package livestatus
 
 import (
-	"fmt"
-	"github.com/griesbacher/nagflux/helper"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"github.com/griesbacher/nagflux/logging"
 	"reflect"
 	"testing"
+	"time"
 )
 
-func TestDataSanitizeValues(t *testing.T) {
-	t.Parallel()
-	live1 := Data{"host", "service", "comm\\ent a", "0", "author"}
-	live2 := live1
-	live2.sanitizeValues()
-	if !reflect.DeepEqual(live1, live2) {
-		t.Errorf("Sanitize should not change the comment. \n1:%s\n2:%s", live1, live2)
+//messagePoint builds the expected point of the livestatus types.
+func messagePoint(host, service, typ, author, message string, timestamp int64) collector.Point {
+	point := collector.Point{
+		Measurement: "messages",
+		Tags:        map[string]string{"host": host, "service": service, "author": author},
+		Fields:      map[string]interface{}{"message": message},
+		Timestamp:   timestamp,
+		Precision:   time.Second,
 	}
+	if typ != "" {
+		point.Tags["type"] = typ
+	}
+	return point
 }
 
-func TestGetTablename(t *testing.T) {
-	t.Parallel()
-	live := Data{"host", "service", "comment", "0", "author"}
-	tablename := fmt.Sprintf("messages,host=%s,service=%s", live.hostName, live.serviceDisplayName)
-	if live.getTablename() != tablename {
-		t.Error("Tablename should match")
+func TestGenPoint(t *testing.T) {
+	logging.InitTestLogger()
+	config.InitConfigFromString(Config)
+	live := Data{"host 1", "service 1", "comment", "1458988932", "author", ""}
+	expected := []collector.Point{messagePoint("host 1", "service 1", "comment", "author", "special text", 1458988932)}
+	if result := live.genPoint(collector.EmptyFilterable, "comment", "special text", live.entryTime); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected:%v\nResult:%v", expected, result)
 	}
-	tablename2 := fmt.Sprintf("%s%s%s%smessages", live.hostName, "", live.serviceDisplayName, "")
-	if live.getTablename() == tablename2 {
-		t.Error("Tablname should not match")
+	if result := live.genPoint(collector.EmptyFilterable, "comment", "special text", live.entryTime); result[0].TimestampMs() != 1458988932000 {
+		t.Errorf("Expected the timestamp in ms, got: %d", result[0].TimestampMs())
 	}
-}
 
-func TestGenInfluxLineWithValue(t *testing.T) {
-	t.Parallel()
-	live := Data{"host", "service", "comment", "0", "author"}
+	hostcheck := Data{"host 1", "", "comment", "0", "author", ""}
+	expected = []collector.Point{messagePoint("host 1", "hostcheck", "", "author", "comment", 0)}
+	if result := hostcheck.genPoint(collector.EmptyFilterable, "", "comment", hostcheck.entryTime); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected:%v\nResult:%v", expected, result)
+	}
 
-	expected := fmt.Sprintf("%s%s message=\"%s\" %s", live.getTablename(), ",author="+live.author, "special text", helper.CastStringTimeFromSToMs(live.entryTime))
-	result := live.genInfluxLineWithValue("", "special text")
-	if expected != result {
-		t.Errorf("Expected:%s\nResult:%s", expected, result)
+	site := Data{"host 1", "service 1", "comment", "1458988932", "author", "berlin"}
+	expected = []collector.Point{messagePoint("host 1", "service 1", "comment", "author", "comment", 1458988932)}
+	expected[0].Tags["site"] = "berlin"
+	if result := site.genPoint(collector.EmptyFilterable, "comment", "comment", site.entryTime); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected:%v\nResult:%v", expected, result)
 	}
-}
 
-func TestGenInfluxLine(t *testing.T) {
-	t.Parallel()
-	live := Data{"host", "service", "comment", "0", "author"}
-	expected := fmt.Sprintf("%s%s message=\"%s\" %s", live.getTablename(), ",a=1,b=2,author="+live.author, "comment", helper.CastStringTimeFromSToMs(live.entryTime))
-	result := live.genInfluxLine(",a=1,b=2")
-	if expected != result {
-		t.Errorf("Expected:%s\nResult:%s", expected, result)
+	if result := live.genPoint(collector.EmptyFilterable, "comment", "comment", "no time"); result != nil {
+		t.Errorf("An invalid timestamp should not create a point, got: %v", result)
 	}
 }
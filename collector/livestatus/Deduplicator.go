@@ -0,0 +1,171 @@
+package livestatus
+
+import (
+	"container/list"
+	"encoding/json"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/kdar/factorlog"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultDeduplicationSize is the amount of remembered events, if none is configured.
+	DefaultDeduplicationSize = 10000
+	//Interval in which the emitted events are written to the state file.
+	intervalToSaveDeduplicationState = time.Duration(1) * time.Minute
+)
+
+//eventKey identifies an event of livestatus, which is queried again by the overlapping time windows.
+type eventKey struct {
+	Type    string
+	Host    string
+	Service string
+	Time    string
+	Author  string
+}
+
+//event is implemented by the printables of livestatus which can be deduplicated.
+type event interface {
+	eventKey() eventKey
+}
+
+//key builds the eventKey of the data with the given type.
+func (live Data) key(typ string) eventKey {
+	return eventKey{Type: typ, Host: live.hostName, Service: live.serviceDisplayName, Time: live.entryTime, Author: live.author}
+}
+
+//Deduplicator remembers the emitted events in a bounded LRU, so every event is emitted once.
+//The events can be persisted in a state file, so they are not emitted again after a restart.
+type Deduplicator struct {
+	quit      chan bool
+	size      int
+	stateFile string
+	//the front contains the latest event
+	order  *list.List
+	events map[eventKey]*list.Element
+	mutex  *sync.Mutex
+	log    *factorlog.FactorLog
+}
+
+//NewDeduplicator constructor, which loads the state file and starts to persist the state.
+//A size below 1 uses the DefaultDeduplicationSize, leave stateFile empty to keep the events only in memory.
+func NewDeduplicator(size int, stateFile string) *Deduplicator {
+	if size < 1 {
+		size = DefaultDeduplicationSize
+	}
+	dedup := &Deduplicator{
+		quit:      make(chan bool),
+		size:      size,
+		stateFile: stateFile,
+		order:     list.New(),
+		events:    map[eventKey]*list.Element{},
+		mutex:     &sync.Mutex{},
+		log:       logging.GetLogger(),
+	}
+	if stateFile != "" {
+		dedup.load()
+	}
+	go dedup.run()
+	return dedup
+}
+
+//Stop stops the deduplicator and writes the state file.
+func (d *Deduplicator) Stop() {
+	d.quit <- true
+	<-d.quit
+	d.save()
+	d.log.Debug("Deduplicator stopped")
+}
+
+//Saves the state in an interval.
+func (d *Deduplicator) run() {
+	for {
+		select {
+		case <-d.quit:
+			d.quit <- true
+			return
+		case <-time.After(intervalToSaveDeduplicationState):
+			d.save()
+		}
+	}
+}
+
+//IsNew returns false if the event has been emitted before, otherwise it is remembered.
+//Printables which are no events are always new.
+func (d *Deduplicator) IsNew(printable collector.Printable) bool {
+	e, ok := printable.(event)
+	if !ok {
+		return true
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.add(e.eventKey())
+}
+
+//add moves known keys to the front and returns false, new ones are added and the oldest is removed if the LRU is full.
+func (d *Deduplicator) add(key eventKey) bool {
+	if element, found := d.events[key]; found {
+		d.order.MoveToFront(element)
+		return false
+	}
+	d.events[key] = d.order.PushFront(key)
+	if d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.events, oldest.Value.(eventKey))
+	}
+	return true
+}
+
+//Reads the state file, a missing file is not an error.
+func (d *Deduplicator) load() {
+	raw, err := ioutil.ReadFile(d.stateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			d.log.Warn("Deduplicator: could not read the state file: ", err)
+		}
+		return
+	}
+	//the oldest event comes first
+	var keys []eventKey
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		d.log.Warn("Deduplicator: could not parse the state file: ", err)
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, key := range keys {
+		d.add(key)
+	}
+}
+
+//Writes the events to a temporary file, which replaces the state file afterwards.
+func (d *Deduplicator) save() {
+	if d.stateFile == "" {
+		return
+	}
+	d.mutex.Lock()
+	keys := make([]eventKey, 0, d.order.Len())
+	for element := d.order.Back(); element != nil; element = element.Prev() {
+		keys = append(keys, element.Value.(eventKey))
+	}
+	d.mutex.Unlock()
+
+	raw, err := json.Marshal(keys)
+	if err != nil {
+		d.log.Warn("Deduplicator: could not serialize the state: ", err)
+		return
+	}
+	tmp := d.stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		d.log.Warn("Deduplicator: could not write the state file: ", err)
+		return
+	}
+	if err := os.Rename(tmp, d.stateFile); err != nil {
+		d.log.Warn("Deduplicator: could not replace the state file: ", err)
+	}
+}
@@ -0,0 +1,61 @@
+package livestatus
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func comment(host, timestamp string) CommentData {
+	return NewCommentData(collector.AllFilterable, host, "service 1", "text", timestamp, "philip", "1")
+}
+
+func TestDeduplicator_IsNew(t *testing.T) {
+	dedup := NewDeduplicator(2, "")
+	defer dedup.Stop()
+	tests := []struct {
+		printable collector.Printable
+		expected  bool
+	}{
+		{comment("host 1", "1"), true},
+		{comment("host 1", "1"), false},
+		//the type is part of the key
+		{NewDowntimeData(collector.AllFilterable, "host 1", "service 1", "text", "1", "2", "philip"), true},
+		{comment("host 2", "1"), true},
+		//the oldest event has been removed from the LRU
+		{comment("host 1", "1"), true},
+		{collector.SimplePrintable{Text: "no event"}, true},
+		{collector.SimplePrintable{Text: "no event"}, true},
+	}
+	for i, test := range tests {
+		if actual := dedup.IsNew(test.printable); actual != test.expected {
+			t.Errorf("%d: expected: %t, actual: %t", i, test.expected, actual)
+		}
+	}
+}
+
+func TestDeduplicator_StateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nagflux-dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := path.Join(dir, "dedup.state")
+
+	dedup := NewDeduplicator(2, stateFile)
+	for _, host := range []string{"host 1", "host 2", "host 3"} {
+		dedup.IsNew(comment(host, "1"))
+	}
+	dedup.Stop()
+
+	dedup = NewDeduplicator(2, stateFile)
+	defer dedup.Stop()
+	if dedup.IsNew(comment("host 3", "1")) || dedup.IsNew(comment("host 2", "1")) {
+		t.Error("The events of the state file should be known")
+	}
+	if !dedup.IsNew(comment("host 1", "1")) {
+		t.Error("The oldest event should not be persisted")
+	}
+}
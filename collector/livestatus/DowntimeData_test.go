@@ -1,52 +1,22 @@
 package livestatus
 
 import (
-	"fmt"
+	"github.com/griesbacher/nagflux/collector"
 	"github.com/griesbacher/nagflux/config"
 	"github.com/griesbacher/nagflux/logging"
+	"reflect"
 	"testing"
 )
 
-func TestSanitizeValuesDowntime(t *testing.T) {
-	t.Parallel()
-	down := DowntimeData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip"}, endTime: "123"}
-	down.sanitizeValues()
-	if down.Data.hostName != `host\ 1` {
-		t.Errorf("The notificationType should be escaped. Expected: %s Got: %s", `host\ 1`, down.Data.hostName)
-	}
-}
-
-func TestPrintInfluxdbDowntime(t *testing.T) {
+func TestPointsDowntime(t *testing.T) {
 	logging.InitTestLogger()
-	down := DowntimeData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip"}, endTime: "123"}
-	if !didThisPanic(down.PrintForInfluxDB, "0.8") {
-		t.Errorf("This should panic, due to unsuported influxdb version")
+	config.InitConfigFromString(Config)
+	down := DowntimeData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", entryTime: "1458988932"}, endTime: "123"}
+	expected := []collector.Point{
+		messagePoint("host 1", "service 1", "downtime", "philip", "Downtime start: <br>", 1458988932),
+		messagePoint("host 1", "service 1", "downtime", "philip", "Downtime end: <br>", 123),
 	}
-
-	result := down.PrintForInfluxDB("0.9")
-	expected := `messages,host=host\ 1,service=service\ 1,type=downtime,author=philip message="Downtime start: <br>" 000
-messages,host=host\ 1,service=service\ 1,type=downtime,author=philip message="Downtime end: <br>" 123000`
-	if result != expected {
-		t.Errorf("The result did not match the expected. Result:\n%s \nExpected:\n%s", result, expected)
-	}
-}
-
-func TestPrintElasticsearchDowntime(t *testing.T) {
-	logging.InitTestLogger()
-	config.InitConfigFromString(fmt.Sprintf(Config, "monthly"))
-	down := DowntimeData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", entryTime: "1458988932000"}, endTime: "123"}
-	if !didThatPanic(down.PrintForElasticsearch, "1.0", "index") {
-		t.Errorf("This should panic, due to unsuported elasticsearch version")
-	}
-
-	result := down.PrintForElasticsearch("2.0", "index")
-	expected := `{"index":{"_index":"index-2016.03","_type":"messages"}}
-{"timestamp":1458988932000000,"message":"Downtime start: <br>","author":"philip","host":"host 1","service":"service 1","type":"downtime"}
-
-{"index":{"_index":"index-1970.01","_type":"messages"}}
-{"timestamp":123000,"message":"Downtime end: <br>","author":"philip","host":"host 1","service":"service 1","type":"downtime"}
-`
-	if result != expected {
-		t.Errorf("The result did not match the expected. Result: %sExpected: %s", result, expected)
+	if result := down.Points(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("The result did not match the expected. Result:\n%v \nExpected:\n%v", result, expected)
 	}
 }
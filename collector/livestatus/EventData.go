@@ -0,0 +1,104 @@
+package livestatus
+
+import (
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/logging"
+	"strings"
+)
+
+//EventData adds state changes, flapping and acknowledgements of the livestatus log to the livestatus data
+type EventData struct {
+	collector.Filterable
+	Data
+	eventType string
+	//state is the text of the state like CRITICAL, it is empty for flapping and acknowledgements.
+	state string
+	//stateType is HARD or SOFT for alerts and STARTED, STOPPED or DISABLED for flapping and acknowledgements.
+	stateType string
+}
+
+//newEventData converts a row of the event query, alerts use the plugin output as message and the others their comment.
+func newEventData(row Row, site string) EventData {
+	eventType := row.String("type")
+	event := EventData{
+		Filterable: collector.AllFilterable,
+		Data:       Data{row.String("host_name"), row.String("service_description"), row.String("plugin_output"), row.String("time"), row.String("contact_name"), site},
+		eventType:  eventType,
+		stateType:  row.String("state_type"),
+	}
+	if eventType == "HOST ALERT" || eventType == "SERVICE ALERT" {
+		event.state = stateToText(eventType == "HOST ALERT", row.Int("state"))
+	} else if comment := row.String("comment"); comment != "" {
+		event.comment = comment
+	}
+	return event
+}
+
+//Points converts the event into a messages point with the tags state and state_type
+func (event EventData) Points() []collector.Point {
+	value := event.comment
+	if event.state != "" {
+		value = fmt.Sprintf("%s (%s):<br> %s", event.state, event.stateType, event.comment)
+	} else if event.stateType != "" {
+		value = fmt.Sprintf("%s:<br> %s", event.stateType, event.comment)
+	}
+	points := event.genPoint(event.Filterable, eventToText(event.eventType), strings.TrimSpace(value), event.entryTime)
+	for _, point := range points {
+		if event.state != "" {
+			point.Tags["state"] = event.state
+		}
+		if event.stateType != "" {
+			point.Tags["state_type"] = event.stateType
+		}
+	}
+	return points
+}
+
+func (event EventData) eventKey() eventKey {
+	return event.key(event.eventType)
+}
+
+func eventToText(input string) string {
+	switch input {
+	case "HOST ALERT":
+		return "host_alert"
+	case "SERVICE ALERT":
+		return "service_alert"
+	case "HOST FLAPPING ALERT":
+		return "host_flapping"
+	case "SERVICE FLAPPING ALERT":
+		return "service_flapping"
+	case "HOST ACKNOWLEDGE ALERT":
+		return "host_acknowledgement"
+	case "SERVICE ACKNOWLEDGE ALERT":
+		return "service_acknowledgement"
+	}
+	logging.GetLogger().Warn("This event type is not supported:" + input)
+	return ""
+}
+
+func stateToText(host bool, state int64) string {
+	if host {
+		switch state {
+		case 0:
+			return "UP"
+		case 1:
+			return "DOWN"
+		case 2:
+			return "UNREACHABLE"
+		}
+	} else {
+		switch state {
+		case 0:
+			return "OK"
+		case 1:
+			return "WARNING"
+		case 2:
+			return "CRITICAL"
+		case 3:
+			return "UNKNOWN"
+		}
+	}
+	return fmt.Sprint(state)
+}
@@ -0,0 +1,44 @@
+package livestatus
+
+import (
+	"encoding/json"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"github.com/griesbacher/nagflux/logging"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func eventRow(typ, service string, state int, stateType, output, comment string) Row {
+	return Row{"type": typ, "time": json.Number("1458988932"), "contact_name": "", "host_name": "host 1",
+		"service_description": service, "state": json.Number(strconv.Itoa(state)), "state_type": stateType,
+		"plugin_output": output, "comment": comment}
+}
+
+func TestPointsEvent(t *testing.T) {
+	logging.InitTestLogger()
+	config.InitConfigFromString(Config)
+	tests := []struct {
+		row                                     Row
+		service, typ, message, state, stateType string
+	}{
+		{eventRow("SERVICE ALERT", "load", 2, "HARD", "load 5.0", ""), "load", "service_alert", "CRITICAL (HARD):<br> load 5.0", "CRITICAL", "HARD"},
+		{eventRow("HOST ALERT", "", 1, "SOFT", "PING CRITICAL", ""), "hostcheck", "host_alert", "DOWN (SOFT):<br> PING CRITICAL", "DOWN", "SOFT"},
+		{eventRow("SERVICE FLAPPING ALERT", "load", 0, "STARTED", "", "Service appears to have started flapping"),
+			"load", "service_flapping", "STARTED:<br> Service appears to have started flapping", "", "STARTED"},
+		{eventRow("HOST ACKNOWLEDGE ALERT", "", 0, "STARTED", "", "working on it"), "hostcheck", "host_acknowledgement", "STARTED:<br> working on it", "", "STARTED"},
+	}
+	for _, test := range tests {
+		expected := messagePoint("host 1", test.service, test.typ, "", test.message, 1458988932)
+		expected.Tags["site"] = "berlin"
+		if test.state != "" {
+			expected.Tags["state"] = test.state
+		}
+		expected.Tags["state_type"] = test.stateType
+		expected.Filterable = collector.AllFilterable
+		if result := newEventData(test.row, "berlin").Points(); !reflect.DeepEqual(result, []collector.Point{expected}) {
+			t.Errorf("Result does not match the expected.\n%v\n%v", result, expected)
+		}
+	}
+}
@@ -1,44 +1,32 @@
 package livestatus
 
 import (
-	"fmt"
+	"github.com/griesbacher/nagflux/collector"
 	"github.com/griesbacher/nagflux/config"
 	"github.com/griesbacher/nagflux/logging"
+	"reflect"
 	"testing"
 )
 
-func TestSanitizeValuesNotification(t *testing.T) {
-	t.Parallel()
-	notification := NotificationData{Data: Data{hostName: "host 1"}, notificationType: "HOST NOTIFICATION", notificationLevel: "WARN"}
-	notification.sanitizeValues()
-
-	if notification.notificationType != `HOST\ NOTIFICATION` {
-		t.Errorf("The notificationType should be escaped. Expected: %s Got: %s", `HOST\ NOTIFICATION`, notification.notificationType)
-	}
-}
-
-func TestPrintNotification(t *testing.T) {
+func TestPointsNotification(t *testing.T) {
 	logging.InitTestLogger()
-	notification := NotificationData{Data: Data{hostName: "host 1", author: "philip"}, notificationType: "HOST NOTIFICATION", notificationLevel: "WARN"}
-	if !didThisPanic(notification.PrintForInfluxDB, "0.8") {
-		t.Error("Printed for unsuported influxdb version but got a response")
-	}
-
-	result := notification.PrintForInfluxDB("0.9")
-	if result != `messages,host=host\ 1,service=hostcheck,type=host_notification,author=philip message="WARN:<br> " 000` {
-		t.Errorf("Result does not match the expected. Result: %s", result)
+	config.InitConfigFromString(Config)
+	notification := NotificationData{Data: Data{hostName: "host 1", author: "philip", entryTime: "1458988932"}, notificationType: "HOST NOTIFICATION", notificationLevel: "WARN"}
+	expected := []collector.Point{messagePoint("host 1", "hostcheck", "host_notification", "philip", "WARN:<br> ", 1458988932)}
+	if result := notification.Points(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Result does not match the expected.\n%v\n%v", result, expected)
 	}
 
-	notification2 := NotificationData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip"}, notificationType: "SERVICE NOTIFICATION", notificationLevel: "WARN"}
-	result2 := notification2.PrintForInfluxDB("0.9")
-	if result2 != `messages,host=host\ 1,service=service\ 1,type=service_notification,author=philip message="WARN:<br> " 000` {
-		t.Errorf("Result does not match the expected. Result: %s", result2)
+	notification2 := NotificationData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", entryTime: "1458988932"}, notificationType: "SERVICE NOTIFICATION", notificationLevel: "WARN"}
+	expected2 := []collector.Point{messagePoint("host 1", "service 1", "service_notification", "philip", "WARN:<br> ", 1458988932)}
+	if result2 := notification2.Points(); !reflect.DeepEqual(result2, expected2) {
+		t.Errorf("Result does not match the expected.\n%v\n%v", result2, expected2)
 	}
 
-	notification3 := NotificationData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip"}, notificationType: "NULL NOTIFICATION", notificationLevel: "WARN"}
-	result3 := notification3.PrintForInfluxDB("0.9")
-	if result3 != `messages,host=host\ 1,service=service\ 1,author=philip message="WARN:<br> " 000` {
-		t.Errorf("Result does not match the expected. Result: %s", result3)
+	notification3 := NotificationData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", entryTime: "1458988932"}, notificationType: "NULL NOTIFICATION", notificationLevel: "WARN"}
+	expected3 := []collector.Point{messagePoint("host 1", "service 1", "", "philip", "WARN:<br> ", 1458988932)}
+	if result3 := notification3.Points(); !reflect.DeepEqual(result3, expected3) {
+		t.Errorf("Result does not match the expected.\n%v\n%v", result3, expected3)
 	}
 }
 
@@ -96,58 +84,3 @@ const Config = `[main]
 	Address = "http://localhost:9200"
 	Index = "nagflux"
 	Version = 2.1`
-
-func TestPrintForElasticsearchNotification(t *testing.T) {
-	logging.InitTestLogger()
-	config.InitConfigFromString(fmt.Sprintf(Config, "monthly"))
-	notification := NotificationData{Data: Data{hostName: "host 1", author: "philip", entryTime: "1458988932000"}, notificationType: "HOST NOTIFICATION", notificationLevel: "WARN"}
-	if !didThatPanic(notification.PrintForElasticsearch, "1.0", "index") {
-		t.Error("Printed for unsuported elasticsearch version but got a response")
-	}
-
-	result := notification.PrintForElasticsearch("2.0", "index")
-	expected := `{"index":{"_index":"index-2016.03","_type":"messages"}}
-{"timestamp":1458988932000000,"message":"WARN:<br> ","author":"philip","host":"host 1","service":"hostcheck","type":"host_notification"}
-`
-	if result != expected {
-		t.Errorf("Result does not match the expected.\n%s%s", result, expected)
-	}
-
-	notification2 := NotificationData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", entryTime: "1458988932000"}, notificationType: "SERVICE NOTIFICATION", notificationLevel: "WARN"}
-	result2 := notification2.PrintForElasticsearch("2.0", "index")
-	expected2 := `{"index":{"_index":"index-2016.03","_type":"messages"}}
-{"timestamp":1458988932000000,"message":"WARN:<br> ","author":"philip","host":"host 1","service":"service 1","type":"service_notification"}
-`
-	if result2 != expected2 {
-		t.Errorf("Result does not match the expected.\n%s%s", result2, expected2)
-	}
-
-	notification3 := NotificationData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", entryTime: "1458988932000"}, notificationType: "NULL NOTIFICATION", notificationLevel: "WARN"}
-	result3 := notification3.PrintForElasticsearch("2.0", "index")
-	expected3 := `{"index":{"_index":"index-2016.03","_type":"messages"}}
-{"timestamp":1458988932000000,"message":"WARN:<br> ","author":"philip","host":"host 1","service":"service 1","type":""}
-`
-	if result3 != expected3 {
-		t.Errorf("Result does not match the expected.\n%s%s", result3, expected3)
-	}
-}
-
-func didThisPanic(f func(string) string, arg string) (result bool) {
-	defer func() {
-		if rec := recover(); rec != nil {
-			result = true
-		}
-	}()
-	f(arg)
-	return false
-}
-
-func didThatPanic(f func(string, string) string, arg1, arg2 string) (result bool) {
-	defer func() {
-		if rec := recover(); rec != nil {
-			result = true
-		}
-	}()
-	f(arg1, arg2)
-	return false
-}
@@ -0,0 +1,112 @@
+package livestatus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Row is a line of a livestatus answer, the values are accessible by the names of the queried columns.
+type Row map[string]interface{}
+
+//newRows decodes a json answer into rows, the values are assigned to the columns in their order.
+func newRows(columns []string, body []byte) ([]Row, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var values [][]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("Could not decode the json answer: %s", err)
+	}
+	rows := make([]Row, 0, len(values))
+	for _, line := range values {
+		if len(line) != len(columns) {
+			return nil, fmt.Errorf("The answer has %d values, expected the columns: %s", len(line), strings.Join(columns, " "))
+		}
+		row := Row{}
+		for i, column := range columns {
+			row[column] = line[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+//columnsOfQuery returns the names of the Columns header.
+func columnsOfQuery(query string) []string {
+	for _, line := range strings.Split(query, "\n") {
+		if strings.HasPrefix(line, "Columns:") {
+			return strings.Fields(strings.TrimPrefix(line, "Columns:"))
+		}
+	}
+	return nil
+}
+
+//String returns the value as text, lists are joined by a comma. A missing column is empty.
+func (row Row) String(column string) string {
+	switch value := row[column].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case []interface{}:
+		return strings.Join(row.Strings(column), ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+//Int returns the value as number, it is 0 if the column is missing or not a number.
+func (row Row) Int(column string) int64 {
+	switch value := row[column].(type) {
+	case json.Number:
+		if number, err := value.Int64(); err == nil {
+			return number
+		}
+		if number, err := value.Float64(); err == nil {
+			return int64(number)
+		}
+	case string:
+		if number, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return number
+		}
+	}
+	return 0
+}
+
+//Float returns the value as floating point number, it is 0 if the column is missing or not a number.
+func (row Row) Float(column string) float64 {
+	switch value := row[column].(type) {
+	case json.Number:
+		if number, err := value.Float64(); err == nil {
+			return number
+		}
+	case string:
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	}
+	return 0
+}
+
+//Strings returns the elements of a list column like downtimes, a single value becomes a list with one element.
+func (row Row) Strings(column string) []string {
+	switch value := row[column].(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		result := make([]string, len(value))
+		for i, element := range value {
+			result[i] = Row{"": element}.String("")
+		}
+		return result
+	default:
+		return []string{row.String(column)}
+	}
+}
@@ -0,0 +1,66 @@
+package livestatus
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNewRows(t *testing.T) {
+	t.Parallel()
+	rows, err := newRows([]string{"name", "downtimes", "comment"}, []byte(`[["host1",[1,2],"a;b\n\"c\""],["host2",[],""]]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Row{
+		{"name": "host1", "downtimes": []interface{}{json.Number("1"), json.Number("2")}, "comment": "a;b\n\"c\""},
+		{"name": "host2", "downtimes": []interface{}{}, "comment": ""},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected: %v, actual: %v", expected, rows)
+	}
+
+	if rows, err := newRows([]string{"name"}, []byte("\n")); rows != nil || err != nil {
+		t.Errorf("An empty answer should have no rows: %v %v", rows, err)
+	}
+	if _, err := newRows([]string{"name"}, []byte(`[["host1","too many"]]`)); err == nil {
+		t.Error("Expected an error if the values do not fit the columns")
+	}
+	if _, err := newRows([]string{"name"}, []byte("host1;1")); err == nil {
+		t.Error("Expected an error with csv")
+	}
+}
+
+func TestRow(t *testing.T) {
+	t.Parallel()
+	row := Row{"text": "a", "number": json.Number("1458988932"), "float": json.Number("1.5"), "list": []interface{}{json.Number("1"), "b"}}
+	tests := []struct {
+		actual, expected interface{}
+	}{
+		{row.String("text"), "a"},
+		{row.String("number"), "1458988932"},
+		{row.String("list"), "1,b"},
+		{row.String("missing"), ""},
+		{row.Int("number"), int64(1458988932)},
+		{row.Int("float"), int64(1)},
+		{row.Int("text"), int64(0)},
+		{row.Strings("list"), []string{"1", "b"}},
+		{row.Strings("number"), []string{"1458988932"}},
+		{row.Strings("missing"), []string(nil)},
+	}
+	for i, test := range tests {
+		if !reflect.DeepEqual(test.actual, test.expected) {
+			t.Errorf("%d: expected: %#v, actual: %#v", i, test.expected, test.actual)
+		}
+	}
+}
+
+func TestColumnsOfQuery(t *testing.T) {
+	t.Parallel()
+	if columns := columnsOfQuery(QueryForComments); !reflect.DeepEqual(columns, []string{"host_name", "service_display_name", "comment", "entry_time", "author", "entry_type"}) {
+		t.Errorf("Unexpected columns: %v", columns)
+	}
+	if columns := columnsOfQuery("GET status\n\n"); columns != nil {
+		t.Errorf("Expected no columns: %v", columns)
+	}
+}
@@ -0,0 +1,118 @@
+package livestatus
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/kdar/factorlog"
+	"time"
+)
+
+const (
+	//StatesMeasurement is the measurement of the host and service states.
+	StatesMeasurement = "states"
+	//QueryForHostStates livestatusquery for the state of all hosts
+	QueryForHostStates = `GET hosts
+Columns: name state state_type latency execution_time percent_state_change acknowledged scheduled_downtime_depth
+OutputFormat: json
+
+`
+	//QueryForServiceStates livestatusquery for the state of all services
+	QueryForServiceStates = `GET services
+Columns: host_name display_name state state_type latency execution_time percent_state_change acknowledged scheduled_downtime_depth
+OutputFormat: json
+
+`
+)
+
+//StatePoller writes a snapshot of the state of every host and service into the states measurement.
+type StatePoller struct {
+	quit                chan bool
+	jobs                collector.ResultQueues
+	livestatusConnector *Connector
+	interval            time.Duration
+	site                string
+	log                 *factorlog.FactorLog
+}
+
+//NewStatePoller constructor, which also starts it immediately. The site is added as tag if it is not empty.
+func NewStatePoller(jobs collector.ResultQueues, livestatusConnector *Connector, interval time.Duration, site string) *StatePoller {
+	poller := &StatePoller{
+		quit:                make(chan bool, 2),
+		jobs:                jobs,
+		livestatusConnector: livestatusConnector,
+		interval:            interval,
+		site:                site,
+		log:                 logging.GetLogger(),
+	}
+	go poll(poller.quit, poller.interval, poller.queryStates, poller.log)
+	return poller
+}
+
+//Stop signals the poller to stop.
+func (poller *StatePoller) Stop() {
+	poller.quit <- true
+	<-poller.quit
+	poller.log.Debug("StatePoller stopped")
+}
+
+//queryStates queries the hosts and services, all points of a poll have the same timestamp.
+func (poller *StatePoller) queryStates() error {
+	timestamp := time.Now().Unix()
+	for _, query := range []string{QueryForHostStates, QueryForServiceStates} {
+		rows := make(chan Row)
+		finished := make(chan error)
+		go func(query string) {
+			finished <- poller.livestatusConnector.queryLivestatus(query, rows)
+		}(query)
+	answer:
+		for {
+			select {
+			case row := <-rows:
+				for _, job := range poller.jobs.Rewrite(poller.statePoint(row, timestamp)) {
+					for _, j := range poller.jobs.For(job) {
+						j <- job
+					}
+				}
+			case err := <-finished:
+				if err != nil {
+					return err
+				}
+				break answer
+			}
+		}
+	}
+	return nil
+}
+
+//statePoint converts a row of the host or service query, acknowledged and downtime are 0 or 1.
+func (poller *StatePoller) statePoint(row Row, timestamp int64) collector.Point {
+	tags := map[string]string{"host": row.String("name"), "service": config.GetConfig().InfluxDBGlobal.HostcheckAlias}
+	if _, isService := row["display_name"]; isService {
+		tags["host"] = row.String("host_name")
+		tags["service"] = row.String("display_name")
+	}
+	if poller.site != "" {
+		tags["site"] = poller.site
+	}
+	downtime := int64(0)
+	if row.Int("scheduled_downtime_depth") > 0 {
+		downtime = 1
+	}
+	return collector.Point{
+		Filterable:  collector.AllFilterable,
+		Measurement: StatesMeasurement,
+		Tags:        tags,
+		Fields: map[string]interface{}{
+			"state":                row.Int("state"),
+			"state_type":           row.Int("state_type"),
+			"latency":              row.Float("latency"),
+			"execution_time":       row.Float("execution_time"),
+			"percent_state_change": row.Float("percent_state_change"),
+			"acknowledged":         row.Int("acknowledged"),
+			"downtime":             downtime,
+		},
+		Timestamp: timestamp,
+		Precision: time.Second,
+	}
+}
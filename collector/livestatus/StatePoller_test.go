@@ -0,0 +1,80 @@
+package livestatus
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/logging"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatePoller_QueryStates(t *testing.T) {
+	logging.InitTestLogger()
+	config.InitConfigFromString(Config)
+	mockLive := &MockLivestatus{Queries: map[string]string{
+		QueryForHostStates:    `[["host 1",1,1,0.5,2.25,10.5,1,0]]`,
+		QueryForServiceStates: `[["host 1","load",2,0,0.1,0.2,0,0,1]]`,
+	}}
+	listener, _ := startCountingLivestatus(t, mockLive, false)
+	defer listener.Close()
+	connector := &Connector{Log: logging.GetLogger(), LivestatusAddress: listener.Addr().String(), ConnectionType: "tcp"}
+
+	queue := make(chan collector.Printable, 10)
+	jobs := collector.NewResultQueues()
+	jobs.Set(data.Target{Name: "all", Datatype: data.InfluxDB}, queue)
+	poller := &StatePoller{jobs: jobs, livestatusConnector: connector, site: "berlin", log: logging.GetLogger()}
+	if err := poller.queryStates(); err != nil {
+		t.Fatal(err)
+	}
+	close(queue)
+	var points []collector.Point
+	for printable := range queue {
+		points = append(points, printable.Points()...)
+	}
+	if len(points) != 2 {
+		t.Fatalf("Expected a host and a service point, actual: %v", points)
+	}
+	expected := []collector.Point{
+		{Filterable: collector.AllFilterable, Measurement: StatesMeasurement, Precision: time.Second, Timestamp: points[0].Timestamp,
+			Tags: map[string]string{"host": "host 1", "service": "hostcheck", "site": "berlin"},
+			Fields: map[string]interface{}{"state": int64(1), "state_type": int64(1), "latency": 0.5, "execution_time": 2.25,
+				"percent_state_change": 10.5, "acknowledged": int64(1), "downtime": int64(0)}},
+		{Filterable: collector.AllFilterable, Measurement: StatesMeasurement, Precision: time.Second, Timestamp: points[0].Timestamp,
+			Tags: map[string]string{"host": "host 1", "service": "load", "site": "berlin"},
+			Fields: map[string]interface{}{"state": int64(2), "state_type": int64(0), "latency": 0.1, "execution_time": 0.2,
+				"percent_state_change": 0.0, "acknowledged": int64(0), "downtime": int64(1)}},
+	}
+	if !reflect.DeepEqual(points, expected) {
+		t.Errorf("Expected: %v\nactual: %v", expected, points)
+	}
+
+	listener.Close()
+	if err := poller.queryStates(); err == nil {
+		t.Error("Expected an error without livestatus")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+	interval := time.Duration(1) * time.Minute
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, minRetryDelay},
+		{2, 2 * minRetryDelay},
+		{3, 4 * minRetryDelay},
+		{4, interval},
+		{100, interval},
+	}
+	for _, test := range tests {
+		if actual := retryDelay(test.failures, interval); actual != test.expected {
+			t.Errorf("%d failures: expected: %s, actual: %s", test.failures, test.expected, actual)
+		}
+	}
+	if actual := retryDelay(1, time.Second); actual != time.Second {
+		t.Errorf("The delay should not exceed the interval: %s", actual)
+	}
+}
@@ -0,0 +1,44 @@
+package livestatus
+
+import (
+	"github.com/kdar/factorlog"
+	"time"
+)
+
+//minRetryDelay is the delay after the first failed poll, it doubles with every further failure.
+const minRetryDelay = time.Duration(10) * time.Second
+
+//poll calls query at once and then in the interval, until quit receives. A failed query is retried with an increasing
+//delay, which never exceeds the interval, so the time windows of the queries still overlap.
+func poll(quit chan bool, interval time.Duration, query func() error, log *factorlog.FactorLog) {
+	delay := time.Duration(0)
+	failures := 0
+	for {
+		select {
+		case <-quit:
+			quit <- true
+			return
+		case <-time.After(delay):
+			if err := query(); err != nil {
+				failures++
+				delay = retryDelay(failures, interval)
+				log.Warnf("%s, retrying in %s", err, delay)
+			} else {
+				failures = 0
+				delay = interval
+			}
+		}
+	}
+}
+
+//retryDelay returns the delay after the given amount of consecutive failures.
+func retryDelay(failures int, interval time.Duration) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < failures && delay < interval; i++ {
+		delay *= 2
+	}
+	if delay > interval {
+		return interval
+	}
+	return delay
+}
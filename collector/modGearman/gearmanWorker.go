@@ -27,7 +27,8 @@ type GearmanWorker struct {
 
 //NewGearmanWorker generates a new GearmanWorker.
 //leave the key empty to disable encryption, otherwise the gearmanpacketes are expected to be encrpyten with AES-ECB 128Bit and a 32 Byte Key.
-func NewGearmanWorker(address, queue, key string, results collector.ResultQueues, livestatusCacheBuilder *livestatus.CacheBuilder) *GearmanWorker {
+func NewGearmanWorker(address, queue, key string, results collector.ResultQueues, livestatusCacheBuilder livestatus.CacheBuilders,
+	unitNormalizer *spoolfile.UnitNormalizer, rateCalculator *spoolfile.RateCalculator) *GearmanWorker {
 	var decrypter *crypto.AESECBDecrypter
 	if key != "" {
 		byteKey := ShapeKey(key, DefaultModGearmanKeyLength)
@@ -41,7 +42,7 @@ func NewGearmanWorker(address, queue, key string, results collector.ResultQueues
 		quit:    make(chan bool),
 		results: results,
 		nagiosSpoolfileWorker: spoolfile.NewNagiosSpoolfileWorker(
-			-1, make(chan string), make(collector.ResultQueues), livestatusCacheBuilder, 4096, collector.AllFilterable,
+			-1, make(chan string), collector.NewResultQueues(), livestatusCacheBuilder, unitNormalizer, rateCalculator, 4096, collector.AllFilterable,
 		),
 		aesECBDecrypter: decrypter,
 		worker:          createGearmanWorker(address),
@@ -100,7 +101,7 @@ func (g GearmanWorker) run() {
 func (g GearmanWorker) handleLoad() {
 	bufferLimit := int(float32(config.GetConfig().Main.BufferSize) * 0.90)
 	for {
-		for _, r := range g.results {
+		for _, r := range g.results.All() {
 			if len(r) > bufferLimit && g.worker != nil {
 				g.worker.Lock()
 				for len(r) > bufferLimit {
@@ -153,11 +154,13 @@ func (g GearmanWorker) handelJob(job worker.Job) ([]byte, error) {
 	g.log.Debug("[ModGearman] ", string(job.Data()))
 	g.log.Debug("[ModGearman] ", splittedPerformanceData)
 	for singlePerfdata := range g.nagiosSpoolfileWorker.PerformanceDataIterator(splittedPerformanceData) {
-		for _, r := range g.results {
-			select {
-			case r <- singlePerfdata:
-			case <-time.After(time.Duration(1) * time.Minute):
-				logging.GetLogger().Warn("GearmanWorker: Could not write to buffer")
+		for _, printable := range g.results.Rewrite(singlePerfdata) {
+			for _, r := range g.results.For(printable) {
+				select {
+				case r <- printable:
+				case <-time.After(time.Duration(1) * time.Minute):
+					logging.GetLogger().Warn("GearmanWorker: Could not write to buffer")
+				}
 			}
 		}
 	}
@@ -10,17 +10,26 @@ const DefaultModGearmanKeyLength = 32
 
 //GetSecret parses the mod_gearman secret/file and returns one key.
 func GetSecret(secret, secretFile string) string {
+	result, err := ReadSecret(secret, secretFile)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+//ReadSecret like GetSecret but returns an error if the file can not be read.
+func ReadSecret(secret, secretFile string) (string, error) {
 	if secret != "" {
-		return secret
+		return secret, nil
 	}
 	if secretFile != "" {
-		if data, err := ioutil.ReadFile(secretFile); err != nil {
-			panic(err)
-		} else {
-			return strings.TrimSpace(string(data))
+		data, err := ioutil.ReadFile(secretFile)
+		if err != nil {
+			return "", err
 		}
+		return strings.TrimSpace(string(data)), nil
 	}
-	return ""
+	return "", nil
 }
 
 //ShapeKey expands the key to length, or cuts it.
@@ -1,9 +1,9 @@
 package nagflux
 
 import (
-	"fmt"
 	"github.com/griesbacher/nagflux/collector"
-	"github.com/griesbacher/nagflux/helper"
+	"strconv"
+	"time"
 )
 
 //Printable converts from nagfluxfile format to X
@@ -15,31 +15,25 @@ type Printable struct {
 	fields    map[string]string
 }
 
-//PrintForInfluxDB prints the data in influxdb lineformat
-func (p Printable) PrintForInfluxDB(version string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("0.9") {
-		line := p.Table
-		if len(p.tags) > 0 {
-			line += fmt.Sprintf(`,%s`, helper.PrintMapAsString(p.tags, ",", "="))
-		}
-		line += " "
-		if len(p.fields) > 0 {
-			line += fmt.Sprintf(`%s`, helper.PrintMapAsString(p.fields, ",", "="))
-		}
-		return fmt.Sprintf("%s %s", line, p.Timestamp)
+//Points converts the row into a single point, the fields are typed like in the influx lineformat
+func (p Printable) Points() []collector.Point {
+	timestamp, err := strconv.ParseInt(p.Timestamp, 10, 64)
+	if err != nil {
+		return nil
 	}
-	return ""
-}
-
-//PrintForElasticsearch prints in the elasticsearch json format
-func (p Printable) PrintForElasticsearch(version, index string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("2.0") {
-		head := fmt.Sprintf(`{"index":{"_index":"%s","_type":"%s"}}`, helper.GenIndex(index, p.Timestamp), p.Table) + "\n"
-		data := fmt.Sprintf(`{"timestamp":%s`, p.Timestamp)
-		data += helper.CreateJSONFromStringMap(p.tags)
-		data += helper.CreateJSONFromStringMap(p.fields)
-		data += "}\n"
-		return head + data
+	point := collector.Point{
+		Filterable:  p.Filterable,
+		Measurement: p.Table,
+		Tags:        map[string]string{},
+		Fields:      map[string]interface{}{},
+		Timestamp:   timestamp,
+		Precision:   time.Millisecond,
+	}
+	for k, v := range p.tags {
+		point.Tags[k] = v
+	}
+	for k, v := range p.fields {
+		point.Fields[k] = collector.ParseFieldValue(v)
 	}
-	return ""
+	return []collector.Point{point}
 }
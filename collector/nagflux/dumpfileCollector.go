@@ -63,7 +63,7 @@ func (dump *DumpfileCollector) run() {
 			dump.log.Warn(err)
 		} else {
 			dump.log.Infof("Loding dumpfile: %s", dump.dumpFile)
-			if dump.target.Datatype == data.InfluxDB {
+			if dump.target.Datatype != data.Elasticsearch {
 				reader := bufio.NewReaderSize(filehandle, dump.fileBufferSize)
 				line, isPrefix, err := reader.ReadLine()
 				for err == nil && !isPrefix {
@@ -2,12 +2,14 @@ package nagflux
 
 import (
 	"encoding/csv"
+	"fmt"
 	"github.com/griesbacher/nagflux/collector"
 	"github.com/griesbacher/nagflux/collector/spoolfile"
 	"github.com/griesbacher/nagflux/config"
 	"github.com/griesbacher/nagflux/helper"
 	"github.com/griesbacher/nagflux/logging"
 	"github.com/kdar/factorlog"
+	"io"
 	"os"
 	"time"
 )
@@ -64,15 +66,17 @@ func (nfc FileCollector) run() {
 			}
 			for _, currentFile := range spoolfile.FilesInDirectoryOlderThanX(nfc.folder, spoolfile.MinFileAge) {
 				logging.GetLogger().Debug("Reading file: ", currentFile)
-				for _, p := range nfc.parseFile(currentFile) {
-					for _, r := range nfc.results {
-						select {
-						case <-nfc.quit:
-							nfc.quit <- true
-							return
-						case r <- p:
-						case <-time.After(time.Duration(1) * time.Minute):
-							nfc.log.Warn("NagfluxFileCollector: Could not write to buffer")
+				for _, parsed := range nfc.parseFile(currentFile) {
+					for _, p := range nfc.results.Rewrite(parsed) {
+						for _, r := range nfc.results.For(p) {
+							select {
+							case <-nfc.quit:
+								nfc.quit <- true
+								return
+							case r <- p:
+							case <-time.After(time.Duration(1) * time.Minute):
+								nfc.log.Warn("NagfluxFileCollector: Could not write to buffer")
+							}
 						}
 					}
 				}
@@ -86,23 +90,31 @@ func (nfc FileCollector) run() {
 }
 
 func (nfc FileCollector) parseFile(filename string) []Printable {
-	result := []Printable{}
 	csvfile, err := os.Open(filename)
 	if err != nil {
 		nfc.log.Warn(err)
-		return result
+		return []Printable{}
 	}
 	defer csvfile.Close()
-	reader := csv.NewReader(csvfile)
-	reader.Comma = nfc.fieldSeparator
+	result, err := ParseCSV(csvfile, nfc.fieldSeparator)
+	if err != nil {
+		nfc.log.Warnf("%s: %s", filename, err)
+	}
+	return result
+}
+
+//ParseCSV reads the nagflux CSV format, the first record names the columns.
+func ParseCSV(input io.Reader, fieldSeparator rune) ([]Printable, error) {
+	log := logging.GetLogger()
+	result := []Printable{}
+	reader := csv.NewReader(input)
+	reader.Comma = fieldSeparator
 	records, err := reader.ReadAll()
 	if err != nil {
-		nfc.log.Warn(err)
-		return result
+		return result, err
 	}
-	if !helper.Contains(records[0], requiredFields) {
-		nfc.log.Warnf("The file %s doesn't contain all of these fields: %s", filename, requiredFields)
-		return result
+	if len(records) == 0 || !helper.Contains(records[0], requiredFields) {
+		return result, fmt.Errorf("The header doesn't contain all of these fields: %s", requiredFields)
 	}
 
 	tagIndices := map[int]string{}
@@ -118,7 +130,7 @@ func (nfc FileCollector) parseFile(filename string) []Printable {
 		} else if helper.Contains(optionalFields, []string{v}) {
 			continue
 		} else {
-			nfc.log.Warnf("This column does not fit the requirements: %s. Tags should start with t_, fields with f_", v)
+			log.Warnf("This column does not fit the requirements: %s. Tags should start with t_, fields with f_", v)
 		}
 	}
 
@@ -140,7 +152,7 @@ func (nfc FileCollector) parseFile(filename string) []Printable {
 				} else if val, ok := fieldIndices[i]; ok {
 					currentPrintable.fields[val] = v
 				} else {
-					nfc.log.Warnf("This should not happen: %s->%s", records[0][i], v)
+					log.Warnf("This should not happen: %s->%s", records[0][i], v)
 				}
 			}
 		}
@@ -151,5 +163,5 @@ func (nfc FileCollector) parseFile(filename string) []Printable {
 
 		result = append(result, currentPrintable)
 	}
-	return result
+	return result, nil
 }
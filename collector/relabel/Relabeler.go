@@ -0,0 +1,175 @@
+package relabel
+
+import (
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	//ActionReplace sets the Target tag, which defaults to the first source, to the Replacement. An empty result removes the tag.
+	ActionReplace = "replace"
+	//ActionDrop removes the points which match.
+	ActionDrop = "drop"
+	//ActionKeep removes the points which do not match.
+	ActionKeep = "keep"
+	//ActionAddTag adds the Target tag with the Replacement.
+	ActionAddTag = "addtag"
+	//ActionMeasurement renames the measurement to the Replacement.
+	ActionMeasurement = "measurement"
+	//ActionRoute sends the points to the targets given by the Replacement, a comma separated list of target names.
+	ActionRoute = "route"
+
+	//MeasurementSource can be used as source to match the measurement instead of a tag.
+	MeasurementSource = "measurement"
+	//Joins the values of multiple sources.
+	sourceSeparator = ";"
+)
+
+//Rule is a single relabel step, the Regex has to match the whole value of the sources.
+type Rule struct {
+	Name        string
+	Sources     []string
+	Regex       *regexp.Regexp
+	Action      string
+	Target      string
+	Replacement string
+}
+
+//NewRule validates and compiles a rule. source is a comma separated list of tags like host, service, command or
+//performanceLabel, their values are joined by ;. An empty regex matches everything, an empty replacement is $1.
+func NewRule(name, source, regex, action, target, replacement string) (Rule, error) {
+	rule := Rule{Name: name, Action: strings.ToLower(action), Target: target, Replacement: replacement}
+	for _, s := range strings.Split(source, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			rule.Sources = append(rule.Sources, s)
+		}
+	}
+	if len(rule.Sources) == 0 {
+		return Rule{}, fmt.Errorf("Relabel rule %s has no Source", name)
+	}
+	if regex == "" {
+		regex = "(.*)"
+	}
+	var err error
+	if rule.Regex, err = regexp.Compile("^(?:" + regex + ")$"); err != nil {
+		return Rule{}, fmt.Errorf("Relabel rule %s has an invalid Regex: %s", name, err)
+	}
+	if rule.Replacement == "" {
+		rule.Replacement = "$1"
+	}
+	switch rule.Action {
+	case ActionReplace:
+		if rule.Target == "" {
+			rule.Target = rule.Sources[0]
+		}
+	case ActionAddTag:
+		if rule.Target == "" {
+			return Rule{}, fmt.Errorf("Relabel rule %s needs a Target", name)
+		}
+	case ActionDrop, ActionKeep, ActionMeasurement, ActionRoute:
+	default:
+		return Rule{}, fmt.Errorf("Relabel rule %s has an unknown Action: '%s'", name, action)
+	}
+	return rule, nil
+}
+
+//apply changes the point, it returns false if the point has to be dropped.
+func (r Rule) apply(point *collector.Point) bool {
+	values := make([]string, len(r.Sources))
+	for i, source := range r.Sources {
+		if source == MeasurementSource {
+			values[i] = point.Measurement
+		} else {
+			values[i] = point.Tags[source]
+		}
+	}
+	value := strings.Join(values, sourceSeparator)
+	match := r.Regex.FindStringSubmatchIndex(value)
+	switch r.Action {
+	case ActionDrop:
+		return match == nil
+	case ActionKeep:
+		return match != nil
+	}
+	if match == nil {
+		return true
+	}
+	result := string(r.Regex.ExpandString(nil, r.Replacement, value, match))
+	switch r.Action {
+	case ActionReplace, ActionAddTag:
+		if result == "" {
+			delete(point.Tags, r.Target)
+		} else {
+			point.Tags[r.Target] = result
+		}
+	case ActionMeasurement:
+		if result != "" {
+			point.Measurement = result
+		}
+	case ActionRoute:
+		if result != "" {
+			point.Filter = result
+		}
+	}
+	return true
+}
+
+//Relabeler applies its rules in order on every point of the collectors.
+type Relabeler struct {
+	rules []Rule
+}
+
+//NewRelabeler creates a Relabeler with the given rules.
+func NewRelabeler(rules []Rule) *Relabeler {
+	return &Relabeler{rules: rules}
+}
+
+//FromConfig creates a Relabeler with the Relabel sections, they are applied in the order of their names.
+func FromConfig(cfg config.Config) (*Relabeler, error) {
+	names := make([]string, 0, len(cfg.Relabel))
+	for name := range cfg.Relabel {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rules := make([]Rule, 0, len(names))
+	for _, name := range names {
+		section := cfg.Relabel[name]
+		rule, err := NewRule(name, section.Source, section.Regex, section.Action, section.Target, section.Replacement)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return NewRelabeler(rules), nil
+}
+
+//Rewrite applies the rules on the points of the printable, every remaining point is returned as its own printable.
+//Preformatted printables are passed as they are.
+func (r *Relabeler) Rewrite(p collector.Printable) []collector.Printable {
+	if len(r.rules) == 0 {
+		return []collector.Printable{p}
+	}
+	if _, ok := p.(collector.SimplePrintable); ok {
+		return []collector.Printable{p}
+	}
+	var result []collector.Printable
+points:
+	for _, point := range p.Points() {
+		tags := make(map[string]string, len(point.Tags))
+		for k, v := range point.Tags {
+			tags[k] = v
+		}
+		point.Tags = tags
+		for _, rule := range r.rules {
+			if !rule.apply(&point) {
+				continue points
+			}
+		}
+		result = append(result, point)
+	}
+	return result
+}
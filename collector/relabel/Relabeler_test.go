@@ -0,0 +1,116 @@
+package relabel
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"reflect"
+	"testing"
+)
+
+const testConfig = `[Relabel "1-disk"]
+	Source = "service"
+	Regex = "Disk (.*)"
+	Action = "replace"
+	Replacement = "disk_$1"
+[Relabel "2-drop-test-hosts"]
+	Source = "host"
+	Regex = "test-.*"
+	Action = "drop"
+[Relabel "3-route"]
+	Source = "host,command"
+	Regex = "db[0-9]+;check_mysql.*"
+	Action = "route"
+	Replacement = "mysql"
+[Relabel "4-site"]
+	Source = "host"
+	Regex = "([a-z]+)-.*"
+	Action = "addtag"
+	Target = "site"
+`
+
+func point(measurement string, tags map[string]string) collector.Point {
+	return collector.Point{Filterable: collector.AllFilterable, Measurement: measurement, Tags: tags, Fields: map[string]interface{}{"value": 1.0}}
+}
+
+func TestFromConfig(t *testing.T) {
+	config.InitConfigFromString(testConfig)
+	relabeler, err := FromConfig(config.GetConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    collector.Point
+		expected []collector.Printable
+	}{
+		{point("metrics", map[string]string{"host": "ber-web1", "service": "Disk /var"}), []collector.Printable{
+			point("metrics", map[string]string{"host": "ber-web1", "service": "disk_/var", "site": "ber"}),
+		}},
+		{point("metrics", map[string]string{"host": "test-web1", "service": "Disk /var"}), nil},
+		{point("metrics", map[string]string{"host": "db1", "command": "check_mysql_health"}), []collector.Printable{
+			collector.Point{Filterable: collector.Filterable{Filter: "mysql"}, Measurement: "metrics",
+				Tags: map[string]string{"host": "db1", "command": "check_mysql_health"}, Fields: map[string]interface{}{"value": 1.0}},
+		}},
+	}
+	for _, test := range tests {
+		if result := relabeler.Rewrite(test.input); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Expected: %v, actual: %v", test.expected, result)
+		}
+	}
+
+	simple := collector.SimplePrintable{Filterable: collector.AllFilterable, Text: "test-host"}
+	if result := relabeler.Rewrite(simple); !reflect.DeepEqual(result, []collector.Printable{simple}) {
+		t.Errorf("Preformatted printables should not be changed: %v", result)
+	}
+}
+
+func TestRule(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name, source, regex, action, target, replacement string
+		input, expected                                  collector.Point
+		keep                                             bool
+	}{
+		{"replace with empty result removes the tag", "service", "tmp.*", ActionReplace, "", "$2",
+			point("metrics", map[string]string{"service": "tmp1"}), point("metrics", map[string]string{}), true},
+		{"replace into another tag", "performanceLabel", "'?([^']*)'?", ActionReplace, "label", "",
+			point("metrics", map[string]string{"performanceLabel": "'C:\\ used'"}),
+			point("metrics", map[string]string{"performanceLabel": "'C:\\ used'", "label": "C:\\ used"}), true},
+		{"regex has to match the whole value", "host", "web", ActionReplace, "", "x",
+			point("metrics", map[string]string{"host": "web1"}), point("metrics", map[string]string{"host": "web1"}), true},
+		{"keep drops not matching points", "command", "check_(load|disk)", ActionKeep, "", "",
+			point("metrics", map[string]string{"command": "check_ping"}), collector.Point{}, false},
+		{"keep", "command", "check_(load|disk)", ActionKeep, "", "",
+			point("metrics", map[string]string{"command": "check_load"}), point("metrics", map[string]string{"command": "check_load"}), true},
+		{"rename measurement", "measurement,command", "metrics;check_(.*)", ActionMeasurement, "", "metrics_$1",
+			point("metrics", map[string]string{"command": "check_load"}), point("metrics_load", map[string]string{"command": "check_load"}), true},
+		{"missing tags are empty", "downtime", "", ActionAddTag, "downtime", "false",
+			point("metrics", map[string]string{}), point("metrics", map[string]string{"downtime": "false"}), true},
+	}
+	for _, test := range tests {
+		rule, err := NewRule(test.name, test.source, test.regex, test.action, test.target, test.replacement)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		p := test.input
+		if keep := rule.apply(&p); keep != test.keep || (keep && !reflect.DeepEqual(p, test.expected)) {
+			t.Errorf("%s: expected: %t %v, actual: %t %v", test.name, test.keep, test.expected, keep, p)
+		}
+	}
+}
+
+func TestNewRule_Invalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct{ source, regex, action, target string }{
+		{"", "", ActionDrop, ""},
+		{"host", "(", ActionDrop, ""},
+		{"host", "", "rename", ""},
+		{"host", "", ActionAddTag, ""},
+	}
+	for _, test := range tests {
+		if _, err := NewRule("invalid", test.source, test.regex, test.action, test.target, ""); err == nil {
+			t.Errorf("Expected an error: %+v", test)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package spoolfile
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//Range is a Nagios threshold range like described in the plugin development guidelines.
+//Start and End are inclusive bounds and may be infinite. Without Inclusive an alert is raised if the value is outside
+//of [Start, End], with Inclusive (the @ prefix) if it is inside.
+type Range struct {
+	Start     float64
+	End       float64
+	Inclusive bool
+	//Simple is true if the range was given as a single number like 10, which is the same as 0:10
+	Simple bool
+}
+
+//ParseRange parses a threshold range, e.g. 10, 10:, ~:10, 10:20 or @10:20. Decimal commas have to be replaced before.
+func ParseRange(input string) (Range, error) {
+	result := Range{Start: 0, End: math.Inf(1)}
+	raw := strings.TrimSpace(input)
+	if strings.HasPrefix(raw, "@") {
+		result.Inclusive = true
+		raw = raw[1:]
+	}
+	if raw == "" {
+		return Range{}, fmt.Errorf("Range is empty: '%s'", input)
+	}
+
+	var err error
+	separator := strings.Index(raw, ":")
+	if separator < 0 {
+		result.Simple = true
+		if result.End, err = strconv.ParseFloat(raw, 64); err != nil {
+			return Range{}, fmt.Errorf("Range is not valid: '%s': %s", input, err)
+		}
+	} else {
+		start, end := raw[:separator], raw[separator+1:]
+		if start != "" {
+			if result.Start, err = parseBound(start, math.Inf(-1)); err != nil {
+				return Range{}, fmt.Errorf("Range start is not valid: '%s': %s", input, err)
+			}
+		}
+		if end != "" {
+			if result.End, err = parseBound(end, math.Inf(1)); err != nil {
+				return Range{}, fmt.Errorf("Range end is not valid: '%s': %s", input, err)
+			}
+		}
+	}
+	if result.Start > result.End {
+		return Range{}, fmt.Errorf("Range start is greater than the end: '%s'", input)
+	}
+	return result, nil
+}
+
+//parseBound parses a number, ~ stands for infinity.
+func parseBound(input string, infinity float64) (float64, error) {
+	if input == "~" {
+		return infinity, nil
+	}
+	return strconv.ParseFloat(input, 64)
+}
+
+//Alert returns true if the value raises an alert for this range.
+func (r Range) Alert(value float64) bool {
+	inside := value >= r.Start && value <= r.End
+	if r.Inclusive {
+		return inside
+	}
+	return !inside
+}
+
+//Fields converts the range into the fields <prefix>-min, <prefix>-max and <prefix>-inclusive, infinite bounds are omitted.
+//A simple range also gets the field <prefix> with its end, like before the ranges had been parsed.
+func (r Range) Fields(prefix string) map[string]string {
+	result := map[string]string{prefix + "-inclusive": strconv.FormatBool(r.Inclusive)}
+	if !math.IsInf(r.Start, 0) {
+		result[prefix+"-min"] = formatBound(r.Start)
+	}
+	if !math.IsInf(r.End, 0) {
+		result[prefix+"-max"] = formatBound(r.End)
+		if r.Simple {
+			result[prefix] = formatBound(r.End)
+		}
+	}
+	return result
+}
+
+//Fill returns how a graph should fill the alert area: inner for @ ranges, none for a simple range and outer otherwise.
+func (r Range) Fill() string {
+	if r.Inclusive {
+		return "inner"
+	} else if r.Simple {
+		return "none"
+	}
+	return "outer"
+}
+
+//formatBound formats a finite bound of the range as field value.
+func formatBound(bound float64) string {
+	return formatFloat(bound)
+}
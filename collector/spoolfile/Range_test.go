@@ -0,0 +1,98 @@
+package spoolfile
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+var inf = math.Inf(1)
+
+//The examples of the plugin development guidelines and some edge cases.
+var TestRanges = []struct {
+	input  string
+	result Range
+	fill   string
+	fields map[string]string
+	//values which raise an alert and ones which don't
+	alert, ok []float64
+}{
+	{"10", Range{Start: 0, End: 10, Simple: true}, "none",
+		map[string]string{"warn": "10.0", "warn-min": "0.0", "warn-max": "10.0", "warn-inclusive": "false"},
+		[]float64{-1, 10.5, 11}, []float64{0, 5, 10}},
+	{"10:", Range{Start: 10, End: inf}, "outer",
+		map[string]string{"warn-min": "10.0", "warn-inclusive": "false"},
+		[]float64{-1, 9.9}, []float64{10, 1000}},
+	{"~:10", Range{Start: -inf, End: 10}, "outer",
+		map[string]string{"warn-max": "10.0", "warn-inclusive": "false"},
+		[]float64{10.1, 11}, []float64{-1000, 10}},
+	{"10:20", Range{Start: 10, End: 20}, "outer",
+		map[string]string{"warn-min": "10.0", "warn-max": "20.0", "warn-inclusive": "false"},
+		[]float64{9, 21}, []float64{10, 15, 20}},
+	{"@10:20", Range{Start: 10, End: 20, Inclusive: true}, "inner",
+		map[string]string{"warn-min": "10.0", "warn-max": "20.0", "warn-inclusive": "true"},
+		[]float64{10, 15, 20}, []float64{9, 21}},
+	{"@10", Range{Start: 0, End: 10, Inclusive: true, Simple: true}, "inner",
+		map[string]string{"warn": "10.0", "warn-min": "0.0", "warn-max": "10.0", "warn-inclusive": "true"},
+		[]float64{0, 10}, []float64{-1, 11}},
+	{"@~:5", Range{Start: -inf, End: 5, Inclusive: true}, "inner",
+		map[string]string{"warn-max": "5.0", "warn-inclusive": "true"},
+		[]float64{-100, 5}, []float64{5.5}},
+	{"@10:", Range{Start: 10, End: inf, Inclusive: true}, "inner",
+		map[string]string{"warn-min": "10.0", "warn-inclusive": "true"},
+		[]float64{10, 100}, []float64{9}},
+	{":10", Range{Start: 0, End: 10}, "outer",
+		map[string]string{"warn-min": "0.0", "warn-max": "10.0", "warn-inclusive": "false"},
+		[]float64{-1, 11}, []float64{0, 10}},
+	{"~:", Range{Start: -inf, End: inf}, "outer",
+		map[string]string{"warn-inclusive": "false"},
+		[]float64{}, []float64{-100, 0, 100}},
+	{"10:~", Range{Start: 10, End: inf}, "outer",
+		map[string]string{"warn-min": "10.0", "warn-inclusive": "false"},
+		[]float64{9}, []float64{10}},
+	{"-10.5:-0.25", Range{Start: -10.5, End: -0.25}, "outer",
+		map[string]string{"warn-min": "-10.5", "warn-max": "-0.25", "warn-inclusive": "false"},
+		[]float64{-11, 0}, []float64{-10.5, -1}},
+	{" 5:5 ", Range{Start: 5, End: 5}, "outer",
+		map[string]string{"warn-min": "5.0", "warn-max": "5.0", "warn-inclusive": "false"},
+		[]float64{4.9, 5.1}, []float64{5}},
+}
+
+func TestParseRange(t *testing.T) {
+	t.Parallel()
+	for _, data := range TestRanges {
+		result, err := ParseRange(data.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", data.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, data.result) {
+			t.Errorf("%s: expected: %+v, actual: %+v", data.input, data.result, result)
+		}
+		if fill := result.Fill(); fill != data.fill {
+			t.Errorf("%s: expected fill: %s, actual: %s", data.input, data.fill, fill)
+		}
+		if fields := result.Fields("warn"); !reflect.DeepEqual(fields, data.fields) {
+			t.Errorf("%s: expected fields: %v, actual: %v", data.input, data.fields, fields)
+		}
+		for _, value := range data.alert {
+			if !result.Alert(value) {
+				t.Errorf("%s: %f should raise an alert", data.input, value)
+			}
+		}
+		for _, value := range data.ok {
+			if result.Alert(value) {
+				t.Errorf("%s: %f should not raise an alert", data.input, value)
+			}
+		}
+	}
+}
+
+func TestParseRange_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, input := range []string{"", "@", "abc", "20:10", "1:2:3", "~", "10:abc", "@@10"} {
+		if result, err := ParseRange(input); err == nil {
+			t.Errorf("%s: expected an error, got: %+v", input, result)
+		}
+	}
+}
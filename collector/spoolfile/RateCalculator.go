@@ -0,0 +1,180 @@
+package spoolfile
+
+import (
+	"encoding/json"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/kdar/factorlog"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	//CounterUnit is the unit of perfdata which is a continuous counter.
+	CounterUnit = "c"
+
+	//Interval in which the state is written to the state file.
+	intervalToSaveRateState = time.Duration(1) * time.Minute
+	//Counters which have not been updated for this duration are removed from the state.
+	rateStateMaxAge = time.Duration(24) * time.Hour
+)
+
+type counterKey struct {
+	Host             string
+	Service          string
+	PerformanceLabel string
+}
+
+//counterState is the last value of a counter, Time is in ms.
+type counterState struct {
+	counterKey
+	Value float64
+	Time  int64
+}
+
+//RateCalculator adds the field rate, the increase per second, to counter perfdata.
+//It keeps the last value of every counter and persists them in a state file, so no rate gets lost after a restart.
+type RateCalculator struct {
+	quit      chan bool
+	stateFile string
+	counters  map[counterKey]counterState
+	mutex     *sync.Mutex
+	log       *factorlog.FactorLog
+}
+
+//NewRateCalculator constructor, which loads the state file and starts to persist the state.
+//Leave stateFile empty to keep the state only in memory.
+func NewRateCalculator(stateFile string) *RateCalculator {
+	calculator := &RateCalculator{
+		quit:      make(chan bool),
+		stateFile: stateFile,
+		counters:  map[counterKey]counterState{},
+		mutex:     &sync.Mutex{},
+		log:       logging.GetLogger(),
+	}
+	if stateFile != "" {
+		calculator.load()
+	}
+	go calculator.run()
+	return calculator
+}
+
+//Stop stops the calculator and writes the state file.
+func (c *RateCalculator) Stop() {
+	c.quit <- true
+	<-c.quit
+	c.save()
+	c.log.Debug("RateCalculator stopped")
+}
+
+//Saves the state in an interval.
+func (c *RateCalculator) run() {
+	for {
+		select {
+		case <-c.quit:
+			c.quit <- true
+			return
+		case <-time.After(intervalToSaveRateState):
+			c.save()
+		}
+	}
+}
+
+//Calculate adds the rate field to counter perfdata, if there is a previous value of the counter.
+//If the value has decreased, the counter wrapped at its max or has been reset. Without a max a reset is assumed
+//and the rate is skipped once.
+func (c *RateCalculator) Calculate(perf PerformanceData) PerformanceData {
+	if perf.Unit != CounterUnit {
+		return perf
+	}
+	value, err := strconv.ParseFloat(perf.Fields["value"], 64)
+	if err != nil {
+		return perf
+	}
+	timestamp, err := strconv.ParseInt(perf.Time, 10, 64)
+	if err != nil {
+		return perf
+	}
+	key := counterKey{Host: perf.Hostname, Service: perf.Service, PerformanceLabel: perf.PerformanceLabel}
+
+	c.mutex.Lock()
+	last, found := c.counters[key]
+	if found && timestamp <= last.Time {
+		//duplicated or outdated data, the newer value is kept
+		c.mutex.Unlock()
+		return perf
+	}
+	c.counters[key] = counterState{counterKey: key, Value: value, Time: timestamp}
+	c.mutex.Unlock()
+	if !found {
+		return perf
+	}
+
+	increase := value - last.Value
+	if increase < 0 {
+		max, err := strconv.ParseFloat(perf.Fields["max"], 64)
+		if err != nil || max < last.Value {
+			c.log.Debugf("RateCalculator: counter has been reset: %+v", key)
+			return perf
+		}
+		//max -> 0 is one increment too
+		increase = max - last.Value + value + 1
+	}
+	perf.Fields["rate"] = formatFloat(increase / (float64(timestamp-last.Time) / 1000))
+	return perf
+}
+
+//Reads the state file, a missing file is not an error.
+func (c *RateCalculator) load() {
+	raw, err := ioutil.ReadFile(c.stateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.log.Warn("RateCalculator: could not read the state file: ", err)
+		}
+		return
+	}
+	var states []counterState
+	if err := json.Unmarshal(raw, &states); err != nil {
+		c.log.Warn("RateCalculator: could not parse the state file: ", err)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, state := range states {
+		c.counters[state.counterKey] = state
+	}
+}
+
+//Removes outdated counters and writes the state to a temporary file, which replaces the state file afterwards.
+func (c *RateCalculator) save() {
+	c.mutex.Lock()
+	oldest := time.Now().Add(-rateStateMaxAge).UnixNano() / int64(time.Millisecond)
+	states := make([]counterState, 0, len(c.counters))
+	for key, state := range c.counters {
+		if state.Time < oldest {
+			delete(c.counters, key)
+			continue
+		}
+		states = append(states, state)
+	}
+	c.mutex.Unlock()
+	if c.stateFile == "" {
+		return
+	}
+
+	raw, err := json.Marshal(states)
+	if err != nil {
+		c.log.Warn("RateCalculator: could not serialize the state: ", err)
+		return
+	}
+	tmp := c.stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		c.log.Warn("RateCalculator: could not write the state file: ", err)
+		return
+	}
+	if err := os.Rename(tmp, c.stateFile); err != nil {
+		c.log.Warn("RateCalculator: could not replace the state file: ", err)
+	}
+}
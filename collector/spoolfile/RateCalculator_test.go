@@ -0,0 +1,78 @@
+package spoolfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func counter(label, value, max, timestamp string) PerformanceData {
+	fields := map[string]string{"value": value}
+	if max != "" {
+		fields["max"] = max
+	}
+	return PerformanceData{Hostname: "h", Service: "if", PerformanceLabel: label, Unit: CounterUnit, Time: timestamp, Fields: fields}
+}
+
+var TestCounters = []struct {
+	perf PerformanceData
+	rate string
+}{
+	{counter("in", "1000", "", "1000000"), ""},
+	{counter("in", "1500", "", "1010000"), "50.0"},
+	{counter("out", "10", "", "1010000"), ""},
+	//outdated data does not change the state
+	{counter("in", "1200", "", "1005000"), ""},
+	{counter("in", "1500", "", "1020000"), "0.0"},
+	//reset without a max
+	{counter("in", "100", "", "1030000"), ""},
+	{counter("in", "200", "", "1040000"), "10.0"},
+	//wrap at the max
+	{counter("out", "4294967290", "4294967295", "1020000"), "429496728.0"},
+	{counter("out", "15", "4294967295", "1030000"), "2.1"},
+	//254 -> 255 -> 0 are two increments
+	{counter("err", "254", "255", "1000000"), ""},
+	{counter("err", "0", "255", "1001000"), "2.0"},
+	{PerformanceData{Hostname: "h", Service: "if", PerformanceLabel: "in", Unit: "B", Time: "1050000", Fields: map[string]string{"value": "300"}}, ""},
+}
+
+func TestRateCalculator_Calculate(t *testing.T) {
+	t.Parallel()
+	calculator := NewRateCalculator("")
+	defer calculator.Stop()
+	for i, data := range TestCounters {
+		result := calculator.Calculate(data.perf)
+		if rate := result.Fields["rate"]; rate != data.rate {
+			t.Errorf("%d: expected rate: '%s', actual: '%s'", i, data.rate, rate)
+		}
+	}
+}
+
+func TestRateCalculator_StateFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "nagflux-rate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := path.Join(dir, "rate.state")
+
+	now := time.Now().Unix() * 1000
+	calculator := NewRateCalculator(stateFile)
+	calculator.Calculate(counter("in", "1000", "", strconv.FormatInt(now, 10)))
+	//outdated counters are not persisted
+	calculator.Calculate(counter("out", "1000", "", "1000000"))
+	calculator.Stop()
+
+	calculator = NewRateCalculator(stateFile)
+	defer calculator.Stop()
+	if len(calculator.counters) != 1 {
+		t.Errorf("Expected one counter in the state, actual: %v", calculator.counters)
+	}
+	if rate := calculator.Calculate(counter("in", "3000", "", strconv.FormatInt(now+20000, 10))).Fields["rate"]; rate != "100.0" {
+		t.Errorf("Expected the rate based on the saved state, actual: '%s'", rate)
+	}
+}
@@ -0,0 +1,145 @@
+package spoolfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	//UnitPrefixSI scales KB, MB... by powers of 1000.
+	UnitPrefixSI = "si"
+	//UnitPrefixIEC scales KB, MB... by powers of 1024, like check_disk does.
+	UnitPrefixIEC = "iec"
+	//UnitPrefixNone disables the normalization.
+	UnitPrefixNone = "none"
+
+	//OriginalUnitTag is the tag containing the unit the plugin reported, if it has been normalized.
+	OriginalUnitTag = "originalUnit"
+)
+
+//Units whose prefix depends on the mode of the command, the value is the exponent.
+var prefixedByteUnits = map[string]float64{"KB": 1, "kB": 1, "MB": 2, "GB": 3, "TB": 4, "PB": 5}
+
+//Units which are always scaled by powers of 1024.
+var iecByteUnits = map[string]float64{"KiB": 1, "MiB": 2, "GiB": 3, "TiB": 4, "PiB": 5}
+
+//Time units and the divisor to get seconds.
+var timeUnits = map[string]float64{"ms": 1e3, "us": 1e6, "µs": 1e6, "ns": 1e9}
+
+//Fields which contain a value in the unit of the perfdata.
+var unitFields = []string{"value", "warn", "warn-min", "warn-max", "crit", "crit-min", "crit-max", "min", "max"}
+
+type unitRule struct {
+	command *regexp.Regexp
+	mode    string
+}
+
+//UnitNormalizer converts the values of perfdata into the base units bytes and seconds.
+//Percent and counters are already base units and stay untouched.
+type UnitNormalizer struct {
+	rules       []unitRule
+	defaultMode string
+}
+
+//NewUnitNormalizer creates a normalizer with the rules of the given file, without a file every command is scaled by SI prefixes.
+func NewUnitNormalizer(rulesFile string) (*UnitNormalizer, error) {
+	if rulesFile == "" {
+		return &UnitNormalizer{defaultMode: UnitPrefixSI}, nil
+	}
+	file, err := os.Open(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseUnitRules(file)
+}
+
+//parseUnitRules reads lines of the form "<command regex> <si|iec|none>", empty lines and lines starting with # are ignored.
+//The first matching rule is used, commands without a matching rule are scaled by SI prefixes.
+func parseUnitRules(input io.Reader) (*UnitNormalizer, error) {
+	normalizer := &UnitNormalizer{defaultMode: UnitPrefixSI}
+	scanner := bufio.NewScanner(input)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Unit rule in line %d has to be '<command regex> <si|iec|none>': '%s'", lineNumber, line)
+		}
+		command, err := regexp.Compile(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("Unit rule in line %d has an invalid regex: %s", lineNumber, err)
+		}
+		mode := strings.ToLower(parts[1])
+		if mode != UnitPrefixSI && mode != UnitPrefixIEC && mode != UnitPrefixNone {
+			return nil, fmt.Errorf("Unit rule in line %d has an unknown mode: '%s'", lineNumber, parts[1])
+		}
+		normalizer.rules = append(normalizer.rules, unitRule{command: command, mode: mode})
+	}
+	return normalizer, scanner.Err()
+}
+
+//mode returns the mode of the first rule matching the command.
+func (n UnitNormalizer) mode(command string) string {
+	for _, rule := range n.rules {
+		if rule.command.MatchString(command) {
+			return rule.mode
+		}
+	}
+	return n.defaultMode
+}
+
+//scale returns the base unit and the multiplier and divisor to convert the given unit,
+//false if it is unknown or already a base unit.
+func scale(unit, mode string) (string, float64, float64, bool) {
+	if exponent, ok := prefixedByteUnits[unit]; ok {
+		base := 1000.0
+		if mode == UnitPrefixIEC {
+			base = 1024
+		}
+		return "B", math.Pow(base, exponent), 1, true
+	}
+	if exponent, ok := iecByteUnits[unit]; ok {
+		return "B", math.Pow(1024, exponent), 1, true
+	}
+	if divisor, ok := timeUnits[unit]; ok {
+		return "s", 1, divisor, true
+	}
+	return "", 0, 0, false
+}
+
+//Normalize converts the value, thresholds and min/max of the perfdata into the base unit and keeps the original unit as tag.
+func (n UnitNormalizer) Normalize(perf PerformanceData) PerformanceData {
+	mode := n.mode(perf.Command)
+	if mode == UnitPrefixNone {
+		return perf
+	}
+	unit, multiplier, divisor, ok := scale(perf.Unit, mode)
+	if !ok {
+		return perf
+	}
+	for _, field := range unitFields {
+		value, ok := perf.Fields[field]
+		if !ok {
+			continue
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		perf.Fields[field] = formatFloat(number * multiplier / divisor)
+	}
+	perf.Tags[OriginalUnitTag] = perf.Unit
+	perf.Unit = unit
+	return perf
+}
@@ -0,0 +1,85 @@
+package spoolfile
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/helper"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testUnitRules = `
+# check_disk reports MB as 1024*1024 bytes
+^check_disk$   iec
+^check_legacy  none
+`
+
+var TestUnits = []struct {
+	command, unit string
+	fields        map[string]string
+	expectedUnit  string
+	expected      map[string]string
+	tags          map[string]string
+}{
+	{"check_http", "ms", map[string]string{"value": "150.0", "warn": "200.0", "crit-max": "500.0"},
+		"s", map[string]string{"value": "0.15", "warn": "0.2", "crit-max": "0.5"}, map[string]string{OriginalUnitTag: "ms"}},
+	{"check_http", "us", map[string]string{"value": "1500.0"},
+		"s", map[string]string{"value": "0.0015"}, map[string]string{OriginalUnitTag: "us"}},
+	{"check_http", "s", map[string]string{"value": "1.5"},
+		"s", map[string]string{"value": "1.5"}, map[string]string{}},
+	{"check_mem", "KB", map[string]string{"value": "2.0", "min": "0.0", "max": "16.0", "warn-inclusive": "false"},
+		"B", map[string]string{"value": "2000.0", "min": "0.0", "max": "16000.0", "warn-inclusive": "false"}, map[string]string{OriginalUnitTag: "KB"}},
+	{"check_mem", "GiB", map[string]string{"value": "1.5"},
+		"B", map[string]string{"value": "1610612736.0"}, map[string]string{OriginalUnitTag: "GiB"}},
+	{"check_disk", "MB", map[string]string{"value": "2.0", "warn-min": "1.0"},
+		"B", map[string]string{"value": "2097152.0", "warn-min": "1048576.0"}, map[string]string{OriginalUnitTag: "MB"}},
+	{"check_legacy_disk", "MB", map[string]string{"value": "2.0"},
+		"MB", map[string]string{"value": "2.0"}, map[string]string{}},
+	{"check_load", "%", map[string]string{"value": "44.0"},
+		"%", map[string]string{"value": "44.0"}, map[string]string{}},
+	{"check_if", "c", map[string]string{"value": "1234.0"},
+		"c", map[string]string{"value": "1234.0"}, map[string]string{}},
+	{"check_temp", "C", map[string]string{"value": "21.5"},
+		"C", map[string]string{"value": "21.5"}, map[string]string{}},
+}
+
+func TestUnitNormalizer_Normalize(t *testing.T) {
+	t.Parallel()
+	normalizer, err := parseUnitRules(strings.NewReader(testUnitRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range TestUnits {
+		result := normalizer.Normalize(PerformanceData{Command: data.command, Unit: data.unit, Fields: data.fields, Tags: map[string]string{}})
+		if result.Unit != data.expectedUnit || !reflect.DeepEqual(result.Fields, data.expected) || !reflect.DeepEqual(result.Tags, data.tags) {
+			t.Errorf("%s %s: expected: %s %v %v, actual: %s %v %v", data.command, data.unit,
+				data.expectedUnit, data.expected, data.tags, result.Unit, result.Fields, result.Tags)
+		}
+	}
+}
+
+func TestParseUnitRules_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, rules := range []string{"check_disk", "check_disk iec extra", "check_disk binary", "check_( si"} {
+		if _, err := parseUnitRules(strings.NewReader(rules)); err == nil {
+			t.Errorf("Expected an error for the rules: '%s'", rules)
+		}
+	}
+}
+
+func TestNagiosSpoolfileWorker_UnitNormalizer(t *testing.T) {
+	normalizer, _ := NewUnitNormalizer("")
+	w := NewNagiosSpoolfileWorker(0, nil, collector.NewResultQueues(), nil, normalizer, nil, 4096, collector.AllFilterable)
+	input := "DATATYPE::SERVICEPERFDATA	TIMET::1441791000	HOSTNAME::xxx	SERVICEDESC::http	SERVICEPERFDATA::time=150ms;200;~:500;0	SERVICECHECKCOMMAND::check_http"
+	var result []PerformanceData
+	for perf := range w.PerformanceDataIterator(helper.StringToMap(input, "\t", "::")) {
+		result = append(result, perf)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Expected one perfdata, got: %v", result)
+	}
+	expected := map[string]string{"value": "0.15", "warn": "0.2", "warn-min": "0.0", "warn-max": "0.2", "warn-inclusive": "false", "crit-max": "0.5", "crit-inclusive": "false", "min": "0.0"}
+	if result[0].Unit != "s" || result[0].Tags[OriginalUnitTag] != "ms" || !reflect.DeepEqual(result[0].Fields, expected) {
+		t.Errorf("Unexpected perfdata: %+v", result[0])
+	}
+}
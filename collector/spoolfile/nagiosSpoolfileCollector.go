@@ -28,7 +28,8 @@ type NagiosSpoolfileCollector struct {
 
 //NagiosSpoolfileCollectorFactory creates the give amount of Woker and starts them.
 func NagiosSpoolfileCollectorFactory(spoolDirectory string, workerAmount int, results collector.ResultQueues,
-	livestatusCacheBuilder *livestatus.CacheBuilder, fileBufferSize int, defaultTarget collector.Filterable) *NagiosSpoolfileCollector {
+	livestatusCacheBuilder livestatus.CacheBuilders, unitNormalizer *UnitNormalizer,
+	rateCalculator *RateCalculator, fileBufferSize int, defaultTarget collector.Filterable) *NagiosSpoolfileCollector {
 	s := &NagiosSpoolfileCollector{
 		quit:           make(chan bool),
 		jobs:           make(chan string, 100),
@@ -36,7 +37,7 @@ func NagiosSpoolfileCollectorFactory(spoolDirectory string, workerAmount int, re
 		workers:        make([]*NagiosSpoolfileWorker, workerAmount),
 	}
 
-	gen := NagiosSpoolfileWorkerGenerator(s.jobs, results, livestatusCacheBuilder, fileBufferSize, defaultTarget)
+	gen := NagiosSpoolfileWorkerGenerator(s.jobs, results, livestatusCacheBuilder, unitNormalizer, rateCalculator, fileBufferSize, defaultTarget)
 
 	for w := 0; w < workerAmount; w++ {
 		s.workers[w] = gen()
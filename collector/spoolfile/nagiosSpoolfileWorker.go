@@ -3,7 +3,6 @@ package spoolfile
 import (
 	"bufio"
 	"errors"
-	"fmt"
 	"github.com/griesbacher/nagflux/collector"
 	"github.com/griesbacher/nagflux/collector/livestatus"
 	"github.com/griesbacher/nagflux/helper"
@@ -37,7 +36,6 @@ const (
 
 var (
 	checkMulitRegex       = regexp.MustCompile(`^(.*::)(.*)`)
-	rangeRegex            = regexp.MustCompile(`[\d\.\-]+`)
 	regexPerformancelable = regexp.MustCompile(`([^=]+)=(U|[\d\.,\-]+)([\pL\/%]*);?([\d\.,\-:~@]+)?;?([\d\.,\-:~@]+)?;?([\d\.,\-]+)?;?([\d\.,\-]+)?;?\s*`)
 	regexAltCommand       = regexp.MustCompile(`.*\[(.*)\]\s?$`)
 )
@@ -48,20 +46,25 @@ type NagiosSpoolfileWorker struct {
 	quit                   chan bool
 	jobs                   chan string
 	results                collector.ResultQueues
-	livestatusCacheBuilder *livestatus.CacheBuilder
+	livestatusCacheBuilder livestatus.CacheBuilders
+	unitNormalizer         *UnitNormalizer
+	rateCalculator         *RateCalculator
 	fileBufferSize         int
 	defaultTarget          collector.Filterable
 }
 
 //NewNagiosSpoolfileWorker returns a new NagiosSpoolfileWorker.
 func NewNagiosSpoolfileWorker(workerID int, jobs chan string, results collector.ResultQueues,
-	livestatusCacheBuilder *livestatus.CacheBuilder, fileBufferSize int, defaultTarget collector.Filterable) *NagiosSpoolfileWorker {
+	livestatusCacheBuilder livestatus.CacheBuilders, unitNormalizer *UnitNormalizer,
+	rateCalculator *RateCalculator, fileBufferSize int, defaultTarget collector.Filterable) *NagiosSpoolfileWorker {
 	return &NagiosSpoolfileWorker{
 		workerID:               workerID,
 		quit:                   make(chan bool),
 		jobs:                   jobs,
 		results:                results,
 		livestatusCacheBuilder: livestatusCacheBuilder,
+		unitNormalizer:         unitNormalizer,
+		rateCalculator:         rateCalculator,
 		fileBufferSize:         fileBufferSize,
 		defaultTarget:          defaultTarget,
 	}
@@ -69,10 +72,11 @@ func NewNagiosSpoolfileWorker(workerID int, jobs chan string, results collector.
 
 //NagiosSpoolfileWorkerGenerator generates a worker and starts it.
 func NagiosSpoolfileWorkerGenerator(jobs chan string, results collector.ResultQueues,
-	livestatusCacheBuilder *livestatus.CacheBuilder, fileBufferSize int, defaultTarget collector.Filterable) func() *NagiosSpoolfileWorker {
+	livestatusCacheBuilder livestatus.CacheBuilders, unitNormalizer *UnitNormalizer,
+	rateCalculator *RateCalculator, fileBufferSize int, defaultTarget collector.Filterable) func() *NagiosSpoolfileWorker {
 	workerID := 0
 	return func() *NagiosSpoolfileWorker {
-		s := NewNagiosSpoolfileWorker(workerID, jobs, results, livestatusCacheBuilder, fileBufferSize, defaultTarget)
+		s := NewNagiosSpoolfileWorker(workerID, jobs, results, livestatusCacheBuilder, unitNormalizer, rateCalculator, fileBufferSize, defaultTarget)
 		workerID++
 		go s.run()
 		return s
@@ -110,15 +114,17 @@ func (w *NagiosSpoolfileWorker) run() {
 			for err == nil && !isPrefix {
 				splittedPerformanceData := helper.StringToMap(string(line), "\t", "::")
 				for singlePerfdata := range w.PerformanceDataIterator(splittedPerformanceData) {
-					for _, r := range w.results {
-						select {
-						case <-w.quit:
-							w.quit <- true
-							return
-						case r <- singlePerfdata:
-							queries++
-						case <-time.After(time.Duration(10) * time.Second):
-							logging.GetLogger().Warn("NagiosSpoolfileWorker: Could not write to buffer")
+					for _, printable := range w.results.Rewrite(singlePerfdata) {
+						for _, r := range w.results.For(printable) {
+							select {
+							case <-w.quit:
+								w.quit <- true
+								return
+							case r <- printable:
+								queries++
+							case <-time.After(time.Duration(10) * time.Second):
+								logging.GetLogger().Warn("NagiosSpoolfileWorker: Could not write to buffer")
+							}
 						}
 					}
 				}
@@ -218,6 +224,15 @@ func (w *NagiosSpoolfileWorker) PerformanceDataIterator(input map[string]string)
 				}
 			}
 
+			//Add the groups and custom variables of livestatus, the tags of the spoolfile take precedence
+			if w.livestatusCacheBuilder != nil {
+				for key, value := range w.livestatusCacheBuilder.Tags(perf.Tags["site"], perf.Hostname, perf.Service) {
+					if _, exists := perf.Tags[key]; !exists {
+						perf.Tags[key] = value
+					}
+				}
+			}
+
 			for i, data := range value {
 				data = strings.Replace(data, ",", ".", -1)
 				if i > 1 && i != 3 && data != "" {
@@ -228,34 +243,25 @@ func (w *NagiosSpoolfileWorker) PerformanceDataIterator(input map[string]string)
 					}
 
 					//Add downtime tag if needed
-					if performanceType == "value" && w.livestatusCacheBuilder != nil && w.livestatusCacheBuilder.IsServiceInDowntime(perf.Hostname, perf.Service, input[timet]) {
+					if performanceType == "value" && w.livestatusCacheBuilder != nil && w.livestatusCacheBuilder.IsServiceInDowntime(perf.Tags["site"], perf.Hostname, perf.Service, input[timet]) {
 						perf.Tags["downtime"] = "true"
 					}
+					//Add acknowledged tag if needed
+					if performanceType == "value" && w.livestatusCacheBuilder != nil && w.livestatusCacheBuilder.IsAcknowledged(perf.Tags["site"], perf.Hostname, perf.Service, input[timet]) {
+						perf.Tags["acknowledged"] = "true"
+					}
 
 					if performanceType == "warn" || performanceType == "crit" {
 						//Range handling
-						fillLabel := performanceType + "-fill"
-						rangeHits := rangeRegex.FindAllStringSubmatch(data, -1)
-						if len(rangeHits) == 1 {
-							perf.Tags[fillLabel] = "none"
-							perf.Fields[performanceType] = helper.StringIntToStringFloat(rangeHits[0][0])
-
-						} else if len(rangeHits) == 2 {
-							//If there is a range with no infinity as border, create two points
-							if strings.Contains(data, "@") {
-								perf.Tags[fillLabel] = "inner"
-							} else {
-								perf.Tags[fillLabel] = "outer"
-							}
-
-							for i, tag := range []string{"min", "max"} {
-								tagKey := fmt.Sprintf("%s-%s", performanceType, tag)
-								perf.Fields[tagKey] = helper.StringIntToStringFloat(rangeHits[i][0])
-							}
-						} else {
-							logging.GetLogger().Warnf("Could not parse warn/crit value. Host: %v, Service: %v, Element: %v, Wholedata: %v", perf.Hostname, perf.Service, data, value)
+						thresholdRange, err := ParseRange(data)
+						if err != nil {
+							logging.GetLogger().Warnf("Could not parse warn/crit value. Host: %v, Service: %v, Element: %v, Wholedata: %v, Error: %v", perf.Hostname, perf.Service, data, value, err)
+							continue
+						}
+						perf.Tags[performanceType+"-fill"] = thresholdRange.Fill()
+						for k, v := range thresholdRange.Fields(performanceType) {
+							perf.Fields[k] = v
 						}
-
 					} else {
 						if data == "U" {
 							perf.Fields["unknown"] = "true"
@@ -269,6 +275,12 @@ func (w *NagiosSpoolfileWorker) PerformanceDataIterator(input map[string]string)
 					}
 				}
 			}
+			if w.unitNormalizer != nil {
+				perf = w.unitNormalizer.Normalize(perf)
+			}
+			if w.rateCalculator != nil {
+				perf = w.rateCalculator.Calculate(perf)
+			}
 			ch <- perf
 		}
 		close(ch)
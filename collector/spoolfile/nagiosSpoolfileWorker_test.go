@@ -44,7 +44,7 @@ var TestPerformanceData = []struct {
 			PerformanceLabel: `'C:\ used %'`,
 			Unit:             "%",
 			Tags:             map[string]string{"warn-fill": "none", "crit-fill": "none"},
-			Fields:           map[string]string{"value": "44.0", "warn": "89.0", "crit": "94.0", "min": "0.0", "max": "100.0"},
+			Fields:           map[string]string{"value": "44.0", "warn": "89.0", "warn-min": "0.0", "warn-max": "89.0", "warn-inclusive": "false", "crit": "94.0", "crit-min": "0.0", "crit-max": "94.0", "crit-inclusive": "false", "min": "0.0", "max": "100.0"},
 			Filterable:       collector.AllFilterable,
 		}},
 	},
@@ -58,7 +58,7 @@ var TestPerformanceData = []struct {
 			PerformanceLabel: "a used",
 			Unit:             "",
 			Tags:             map[string]string{"warn-fill": "none", "crit-fill": "none"},
-			Fields:           map[string]string{"value": "4.0", "warn": "2.0", "crit": "10.0"},
+			Fields:           map[string]string{"value": "4.0", "warn": "2.0", "warn-min": "0.0", "warn-max": "2.0", "warn-inclusive": "false", "crit": "10.0", "crit-min": "0.0", "crit-max": "10.0", "crit-inclusive": "false"},
 			Filterable:       collector.AllFilterable,
 		}},
 	},
@@ -72,7 +72,7 @@ var TestPerformanceData = []struct {
 			PerformanceLabel: "a used",
 			Unit:             "",
 			Tags:             map[string]string{"warn-fill": "none", "crit-fill": "none"},
-			Fields:           map[string]string{"value": "4.0", "warn": "2.0", "crit": "10.0", "min": "1.0", "max": "4.0"},
+			Fields:           map[string]string{"value": "4.0", "warn": "2.0", "warn-min": "0.0", "warn-max": "2.0", "warn-inclusive": "false", "crit": "10.0", "crit-min": "0.0", "crit-max": "10.0", "crit-inclusive": "false", "min": "1.0", "max": "4.0"},
 			Filterable:       collector.AllFilterable,
 		}},
 	},
@@ -86,7 +86,7 @@ var TestPerformanceData = []struct {
 			PerformanceLabel: "a used",
 			Unit:             "",
 			Tags:             map[string]string{"warn-fill": "outer", "crit-fill": "outer"},
-			Fields:           map[string]string{"value": "4.0", "warn-min": "2.0", "warn-max": "4.0", "crit-min": "8.0", "crit-max": "10.0", "min": "1.0", "max": "4.0"},
+			Fields:           map[string]string{"value": "4.0", "warn-min": "2.0", "warn-max": "4.0", "warn-inclusive": "false", "crit-min": "8.0", "crit-max": "10.0", "crit-inclusive": "false", "min": "1.0", "max": "4.0"},
 			Filterable:       collector.AllFilterable,
 		}},
 	},
@@ -100,7 +100,7 @@ var TestPerformanceData = []struct {
 			PerformanceLabel: "a used",
 			Unit:             "",
 			Tags:             map[string]string{"warn-fill": "inner", "crit-fill": "inner"},
-			Fields:           map[string]string{"value": "4.0", "warn-min": "2.0", "warn-max": "4.0", "crit-min": "8.0", "crit-max": "10.0", "min": "1.0", "max": "4.0"},
+			Fields:           map[string]string{"value": "4.0", "warn-min": "2.0", "warn-max": "4.0", "warn-inclusive": "true", "crit-min": "8.0", "crit-max": "10.0", "crit-inclusive": "true", "min": "1.0", "max": "4.0"},
 			Filterable:       collector.AllFilterable,
 		}},
 	},
@@ -113,8 +113,8 @@ var TestPerformanceData = []struct {
 			Time:             "1441791005000",
 			PerformanceLabel: "a used",
 			Unit:             "",
-			Tags:             map[string]string{"warn-fill": "none", "crit-fill": "none"},
-			Fields:           map[string]string{"value": "4.0", "warn": "2.0", "crit": "10.0", "min": "1.0", "max": "4.0"},
+			Tags:             map[string]string{"warn-fill": "outer", "crit-fill": "outer"},
+			Fields:           map[string]string{"value": "4.0", "warn-min": "2.0", "warn-inclusive": "false", "crit-min": "10.0", "crit-inclusive": "false", "min": "1.0", "max": "4.0"},
 			Filterable:       collector.AllFilterable,
 		}},
 	},
@@ -127,8 +127,8 @@ var TestPerformanceData = []struct {
 			Time:             "1441791006000",
 			PerformanceLabel: "a used",
 			Unit:             "",
-			Tags:             map[string]string{"warn-fill": "none", "crit-fill": "none"},
-			Fields:           map[string]string{"value": "4.0", "warn": "2.0", "crit": "10.0", "min": "1.0", "max": "4.0"},
+			Tags:             map[string]string{"warn-fill": "outer", "crit-fill": "outer"},
+			Fields:           map[string]string{"value": "4.0", "warn-min": "0.0", "warn-max": "2.0", "warn-inclusive": "false", "crit-min": "0.0", "crit-max": "10.0", "crit-inclusive": "false", "min": "1.0", "max": "4.0"},
 			Filterable:       collector.AllFilterable,
 		}},
 	},
@@ -141,8 +141,8 @@ var TestPerformanceData = []struct {
 			Time:             "1441791007000",
 			PerformanceLabel: "a used",
 			Unit:             "",
-			Tags:             map[string]string{"warn-fill": "none", "crit-fill": "none"},
-			Fields:           map[string]string{"value": "4.0", "warn": "2.0", "crit": "10.0", "min": "1.0", "max": "4.0"},
+			Tags:             map[string]string{"warn-fill": "outer", "crit-fill": "outer"},
+			Fields:           map[string]string{"value": "4.0", "warn-max": "2.0", "warn-inclusive": "false", "crit-min": "10.0", "crit-inclusive": "false", "min": "1.0", "max": "4.0"},
 			Filterable:       collector.AllFilterable,
 		}},
 	},
@@ -195,7 +195,7 @@ var TestPerformanceData = []struct {
 			PerformanceLabel: `'C:\ used %'`,
 			Unit:             "%",
 			Tags:             map[string]string{"warn-fill": "none", "crit-fill": "none"},
-			Fields:           map[string]string{"value": "44.1", "warn": "89.2", "crit": "94.3", "min": "0.4", "max": "100.5"},
+			Fields:           map[string]string{"value": "44.1", "warn": "89.2", "warn-min": "0.0", "warn-max": "89.2", "warn-inclusive": "false", "crit": "94.3", "crit-min": "0.0", "crit-max": "94.3", "crit-inclusive": "false", "min": "0.4", "max": "100.5"},
 			Filterable:       collector.AllFilterable,
 		}},
 	}, {
@@ -362,7 +362,7 @@ func comparePerformanceData(p1, p2 PerformanceData) (bool, string) {
 var debug = true
 
 func TestNagiosSpoolfileWorker_PerformanceDataIterator(t *testing.T) {
-	w := NewNagiosSpoolfileWorker(0, nil, nil, nil, 4096, collector.AllFilterable)
+	w := NewNagiosSpoolfileWorker(0, nil, collector.NewResultQueues(), nil, nil, nil, 4096, collector.AllFilterable)
 	for _, data := range TestPerformanceData {
 		splittedPerformanceData := helper.StringToMap(data.input, "\t", "::")
 		for singlePerfdata := range w.PerformanceDataIterator(splittedPerformanceData) {
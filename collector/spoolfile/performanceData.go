@@ -1,10 +1,12 @@
 package spoolfile
 
 import (
-	"fmt"
 	"github.com/griesbacher/nagflux/collector"
 	"github.com/griesbacher/nagflux/config"
 	"github.com/griesbacher/nagflux/helper"
+	"strconv"
+	"strings"
+	"time"
 )
 
 //PerformanceData represents the nagios perfdata
@@ -20,55 +22,41 @@ type PerformanceData struct {
 	Fields           map[string]string
 }
 
-//PrintForInfluxDB prints the data in influxdb lineformat
-func (p PerformanceData) PrintForInfluxDB(version string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("0.9") {
-		tableName := fmt.Sprintf(`metrics,host=%s`, helper.SanitizeInfluxInput(p.Hostname))
-		if p.Service == "" {
-			tableName += fmt.Sprintf(`,service=%s`, helper.SanitizeInfluxInput(config.GetConfig().InfluxDBGlobal.HostcheckAlias))
-		} else {
-			tableName += fmt.Sprintf(`,service=%s`, helper.SanitizeInfluxInput(p.Service))
-		}
-		tableName += fmt.Sprintf(`,command=%s,performanceLabel=%s`,
-			helper.SanitizeInfluxInput(p.Command),
-			helper.SanitizeInfluxInput(p.PerformanceLabel),
-		)
-		if len(p.Tags) > 0 {
-			tableName += fmt.Sprintf(`,%s`, helper.PrintMapAsString(helper.SanitizeMap(p.Tags), ",", "="))
-		}
-		if p.Unit != "" {
-			tableName += fmt.Sprintf(`,unit=%s`, p.Unit)
-		}
-
-		tableName += fmt.Sprintf(` %s`, helper.PrintMapAsString(helper.SanitizeMap(p.Fields), ",", "="))
-		tableName += fmt.Sprintf(" %s\n", p.Time)
-		return tableName
+//Points converts the perfdata into a single metrics point
+func (p PerformanceData) Points() []collector.Point {
+	timestamp, err := strconv.ParseInt(p.Time, 10, 64)
+	if err != nil {
+		return nil
+	}
+	point := collector.Point{
+		Filterable:  p.Filterable,
+		Measurement: "metrics",
+		Tags: map[string]string{
+			"host":             p.Hostname,
+			"service":          p.Service,
+			"command":          p.Command,
+			"performanceLabel": strings.Trim(p.PerformanceLabel, `'`),
+		},
+		Fields:    map[string]interface{}{},
+		Timestamp: timestamp,
+		Precision: time.Millisecond,
+	}
+	if p.Service == "" {
+		point.Tags["service"] = config.GetConfig().InfluxDBGlobal.HostcheckAlias
+	}
+	if p.Unit != "" {
+		point.Tags["unit"] = p.Unit
 	}
-	return ""
+	for k, v := range p.Tags {
+		point.Tags[k] = v
+	}
+	for k, v := range p.Fields {
+		point.Fields[k] = collector.ParseFieldValue(v)
+	}
+	return []collector.Point{point}
 }
 
-//PrintForElasticsearch prints in the elasticsearch json format
-func (p PerformanceData) PrintForElasticsearch(version, index string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("2.0") {
-		if p.Service == "" {
-			p.Service = config.GetConfig().InfluxDBGlobal.HostcheckAlias
-		}
-		head := fmt.Sprintf(`{"index":{"_index":"%s","_type":"metrics"}}`, helper.GenIndex(index, p.Time)) + "\n"
-		data := fmt.Sprintf(
-			`{"timestamp":%s,"host":"%s","service":"%s","command":"%s","performanceLabel":"%s"`,
-			p.Time,
-			helper.SanitizeElasicInput(p.Hostname),
-			helper.SanitizeElasicInput(p.Service),
-			helper.SanitizeElasicInput(p.Command),
-			helper.SanitizeElasicInput(p.PerformanceLabel),
-		)
-		if p.Unit != "" {
-			data += fmt.Sprintf(`,"unit":"%s"`, helper.SanitizeElasicInput(p.Unit))
-		}
-		data += helper.CreateJSONFromStringMap(p.Tags)
-		data += helper.CreateJSONFromStringMap(p.Fields)
-		data += "}\n"
-		return head + data
-	}
-	return ""
+//formatFloat formats a computed value like the values of the perfdata, integers get a trailing .0.
+func formatFloat(value float64) string {
+	return helper.StringIntToStringFloat(strconv.FormatFloat(value, 'f', -1, 64))
 }
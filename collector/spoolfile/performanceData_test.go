@@ -0,0 +1,51 @@
+package spoolfile
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPerformanceData_Points(t *testing.T) {
+	config.InitConfigFromString(`[InfluxDBGlobal]
+    HostcheckAlias = "hostcheck"
+`)
+	perf := PerformanceData{
+		Hostname:         "xxx",
+		Command:          "check_disk",
+		Time:             "1441791000000",
+		PerformanceLabel: `'C:\ used %'`,
+		Unit:             "%",
+		Tags:             map[string]string{"warn-fill": "inner"},
+		Fields:           map[string]string{"value": "44.0", "unknown": "true"},
+		Filterable:       collector.AllFilterable,
+	}
+	expected := []collector.Point{{
+		Filterable:  collector.AllFilterable,
+		Measurement: "metrics",
+		Tags: map[string]string{"host": "xxx", "service": "hostcheck", "command": "check_disk",
+			"performanceLabel": `C:\ used %`, "unit": "%", "warn-fill": "inner"},
+		Fields:    map[string]interface{}{"value": 44.0, "unknown": true},
+		Timestamp: 1441791000000,
+		Precision: time.Millisecond,
+	}}
+	if actual := perf.Points(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	t.Parallel()
+	for input, expected := range map[float64]string{
+		1:       "1.0",
+		0.5:     "0.5",
+		-20:     "-20.0",
+		1024000: "1024000.0",
+	} {
+		if actual := formatFloat(input); actual != expected {
+			t.Errorf("formatFloat(%v): expected: %s, actual: %s", input, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,657 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/kdar/factorlog"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Queue is a write-ahead log for a single target. The collectors write into In, the records are appended to
+//segment files and read back into Out. A record is removed after the target acknowledged it, so everything
+//which has not been sent is replayed after a restart.
+//The targets acknowledge the data after it has been sent or dumped. With a Queue they retry sending it until
+//it succeeds instead of dumping it, and they do not dump it when they are stopped, since it is replayed anyway.
+type Queue struct {
+	dir         string
+	target      data.Target
+	segmentSize int64
+	maxSize     int64
+	in          chan collector.Printable
+	out         chan collector.Printable
+	quit        chan bool
+	done        sync.WaitGroup
+	log         *factorlog.FactorLog
+
+	mutex    *sync.Mutex
+	cond     *sync.Cond
+	segments []*segment
+	writer   *os.File
+	readPos  position
+	//read but not yet acknowledged records, in read order
+	pending   []*pendingRecord
+	commitPos position
+	committed position
+	reader    *segmentReader
+	//acknowledged records after the commit position, which are skipped after a rewind
+	skip map[position]bool
+	//readerQuit stops the reader goroutine, which closes readerDone.
+	readerQuit chan bool
+	readerDone chan bool
+}
+
+type segment struct {
+	id   int64
+	size int64
+}
+
+type position struct {
+	segment int64
+	offset  int64
+}
+
+//segmentReader keeps the segment which is read open, so the records are read one after another without a seek.
+type segmentReader struct {
+	id     int64
+	offset int64
+	file   *os.File
+	reader *bufio.Reader
+}
+
+type pendingRecord struct {
+	pos   position
+	acked bool
+}
+
+//acknowledger confirms a single record of the queue.
+type acknowledger struct {
+	queue  *Queue
+	record *pendingRecord
+}
+
+//record is the on-disk representation of a printable.
+type record struct {
+	Filter   string        `json:"filter"`
+	Points   []recordPoint `json:"points,omitempty"`
+	Text     string        `json:"text,omitempty"`
+	Datatype data.Datatype `json:"datatype,omitempty"`
+}
+
+//recordPoint is the on-disk representation of a point, its fields keep their type.
+type recordPoint struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Fields      map[string]recordField `json:"fields"`
+	Timestamp   int64                  `json:"timestamp"`
+	Precision   time.Duration          `json:"precision"`
+}
+
+//recordField contains exactly one value. Plain JSON would turn int64 into float64, which InfluxDB rejects as a
+//field type conflict.
+type recordField struct {
+	Int    *int64   `json:"i,omitempty"`
+	Float  *float64 `json:"f,omitempty"`
+	String *string  `json:"s,omitempty"`
+	Bool   *bool    `json:"b,omitempty"`
+}
+
+const (
+	segmentSuffix = ".seg"
+	commitFile    = "commit"
+	headerSize    = 8
+	syncInterval  = time.Duration(1) * time.Second
+)
+
+var errorCorrupt = errors.New("Corrupt record")
+
+//NewQueue opens or creates the write-ahead log of the target within folder.
+//segmentSize and maxSize are in bytes, if maxSize is exceeded the oldest segments are removed even if they were not sent.
+func NewQueue(folder string, target data.Target, segmentSize, maxSize int64, bufferSize int) (*Queue, error) {
+	q := &Queue{
+		dir: path.Join(folder, target.String()), target: target, segmentSize: segmentSize, maxSize: maxSize,
+		in: make(chan collector.Printable, bufferSize), out: make(chan collector.Printable, bufferSize), quit: make(chan bool),
+		log: logging.GetLogger(), mutex: &sync.Mutex{},
+	}
+	q.cond = sync.NewCond(q.mutex)
+	if err := os.MkdirAll(q.dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := q.open(); err != nil {
+		return nil, err
+	}
+	q.done.Add(2)
+	go q.runWriter()
+	go q.runSync()
+	q.startReader()
+	return q, nil
+}
+
+//In is the queue the collectors write into.
+func (q *Queue) In() chan collector.Printable {
+	return q.in
+}
+
+//Out is the queue the target reads from.
+func (q *Queue) Out() chan collector.Printable {
+	return q.out
+}
+
+//Rewind passes every record which has not been acknowledged to Out again, it has to be called if the target has been
+//restarted. The records which are still buffered in Out are read again, so they are dropped. Records which have been
+//acknowledged out of order are not passed again.
+func (q *Queue) Rewind() {
+	q.stopReader()
+	for len(q.out) > 0 {
+		<-q.out
+	}
+	q.mutex.Lock()
+	q.skip = map[position]bool{}
+	for _, record := range q.pending {
+		if record.acked {
+			q.skip[record.pos] = true
+		}
+	}
+	q.pending = nil
+	q.readPos = q.commitPos
+	q.mutex.Unlock()
+	q.startReader()
+}
+
+//Stop stops the queue, acknowledged records are persisted.
+func (q *Queue) Stop() {
+	q.stopReader()
+	close(q.quit)
+	q.done.Wait()
+	q.mutex.Lock()
+	q.writeCommit()
+	q.writer.Close()
+	q.closeReader()
+	q.mutex.Unlock()
+	q.log.Debug("WAL(" + q.target.String() + ") stopped")
+}
+
+//Reads the existing segments and the commit position.
+func (q *Queue) open() error {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(file.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, &segment{id: id, size: file.Size()})
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].id < q.segments[j].id })
+
+	if len(q.segments) == 0 {
+		q.segments = append(q.segments, &segment{id: 1})
+	} else if err := q.repairLastSegment(); err != nil {
+		return err
+	}
+	last := q.segments[len(q.segments)-1]
+	if q.writer, err = os.OpenFile(q.segmentFile(last.id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+		return err
+	}
+
+	q.commitPos = position{segment: q.segments[0].id}
+	if raw, err := ioutil.ReadFile(path.Join(q.dir, commitFile)); err == nil {
+		var commit position
+		if _, err := fmt.Sscanf(string(raw), "%d %d", &commit.segment, &commit.offset); err == nil && q.findSegment(commit.segment) != nil {
+			q.commitPos = commit
+		}
+	}
+	q.readPos = q.commitPos
+	q.committed = q.commitPos
+	q.removeCommittedSegments()
+	return nil
+}
+
+//repairLastSegment cuts a record which has been written partially, e.g. due to a crash.
+func (q *Queue) repairLastSegment() error {
+	last := q.segments[len(q.segments)-1]
+	file, err := os.Open(q.segmentFile(last.id))
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	var valid int64
+	for {
+		payload, err := readRecord(reader)
+		if err != nil {
+			break
+		}
+		valid += int64(headerSize + len(payload))
+	}
+	file.Close()
+	if valid != last.size {
+		q.log.Warnf("WAL(%s): cutting segment %d from %d to %d bytes", q.target, last.id, last.size, valid)
+		if err := os.Truncate(q.segmentFile(last.id), valid); err != nil {
+			return err
+		}
+		last.size = valid
+	}
+	return nil
+}
+
+//Appends everything from the collectors, the remaining data is written on a stop.
+func (q *Queue) runWriter() {
+	defer q.done.Done()
+	for {
+		select {
+		case <-q.quit:
+			for {
+				select {
+				case p := <-q.in:
+					q.write(p)
+				default:
+					return
+				}
+			}
+		case p := <-q.in:
+			q.write(p)
+		}
+	}
+}
+
+func (q *Queue) write(p collector.Printable) {
+	if !p.TestTargetFilter(q.target.Name) {
+		return
+	}
+	payload, err := encode(p)
+	if err != nil {
+		q.log.Warn("WAL: could not encode: ", err)
+		return
+	}
+	if err := q.append(payload); err != nil {
+		q.log.Critical("WAL: could not append: ", err)
+	}
+}
+
+func (q *Queue) startReader() {
+	q.readerQuit = make(chan bool)
+	q.readerDone = make(chan bool)
+	go q.runReader(q.readerQuit, q.readerDone)
+}
+
+//stopReader stops the reader goroutine, it is woken up if it is waiting for new records.
+func (q *Queue) stopReader() {
+	close(q.readerQuit)
+	q.mutex.Lock()
+	q.cond.Broadcast()
+	q.mutex.Unlock()
+	<-q.readerDone
+}
+
+//Reads the records and passes them to the target.
+func (q *Queue) runReader(quit, done chan bool) {
+	defer close(done)
+	for {
+		payload, pending, ok := q.next(quit)
+		if !ok {
+			return
+		}
+		p, err := decode(payload)
+		if err != nil {
+			q.log.Warn("WAL: could not decode: ", err)
+			q.acknowledge(pending)
+			continue
+		}
+		select {
+		case <-quit:
+			return
+		case q.out <- p.withAck(acknowledger{queue: q, record: pending}):
+		}
+	}
+}
+
+//Persists the commit position and flushes the segment in an interval.
+func (q *Queue) runSync() {
+	defer q.done.Done()
+	for {
+		select {
+		case <-q.quit:
+			return
+		case <-time.After(syncInterval):
+			q.mutex.Lock()
+			q.writer.Sync()
+			q.writeCommit()
+			q.removeCommittedSegments()
+			q.mutex.Unlock()
+		}
+	}
+}
+
+func (q *Queue) append(payload []byte) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	size := int64(headerSize + len(payload))
+	last := q.segments[len(q.segments)-1]
+	if last.size > 0 && last.size+size > q.segmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+	for q.maxSize > 0 && q.totalSize()+size > q.maxSize && len(q.segments) > 1 {
+		q.evictOldest()
+	}
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header, uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload))
+	if _, err := q.writer.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	last.size += size
+	q.cond.Broadcast()
+	return nil
+}
+
+func (q *Queue) rotate() error {
+	q.writer.Sync()
+	q.writer.Close()
+	next := &segment{id: q.segments[len(q.segments)-1].id + 1}
+	writer, err := os.OpenFile(q.segmentFile(next.id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	q.writer = writer
+	q.segments = append(q.segments, next)
+	return nil
+}
+
+//evictOldest removes the oldest segment, records within are lost.
+func (q *Queue) evictOldest() {
+	oldest := q.segments[0]
+	q.segments = q.segments[1:]
+	os.Remove(q.segmentFile(oldest.id))
+	q.log.Warnf("WAL(%s) is full, removed segment %d with %d bytes", q.target, oldest.id, oldest.size)
+	next := position{segment: q.segments[0].id}
+	for len(q.pending) > 0 && q.pending[0].pos.segment == oldest.id {
+		q.pending[0].acked = true
+		q.pending = q.pending[1:]
+	}
+	for pos := range q.skip {
+		if pos.segment == oldest.id {
+			delete(q.skip, pos)
+		}
+	}
+	if q.readPos.segment == oldest.id {
+		q.readPos = next
+	}
+	if q.commitPos.segment == oldest.id {
+		q.commitPos = next
+		if len(q.pending) > 0 {
+			q.commitPos = q.pending[0].pos
+		}
+	}
+}
+
+//next blocks till a record is available or the reader is stopped.
+func (q *Queue) next(quit chan bool) ([]byte, *pendingRecord, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for {
+		select {
+		case <-quit:
+			return nil, nil, false
+		default:
+		}
+		current := q.findSegment(q.readPos.segment)
+		if current != nil && q.readPos.offset < current.size {
+			payload, err := q.readAt(q.readPos)
+			if err == nil {
+				record := &pendingRecord{pos: q.readPos}
+				q.readPos.offset += int64(headerSize + len(payload))
+				if q.skip[record.pos] {
+					delete(q.skip, record.pos)
+					continue
+				}
+				q.pending = append(q.pending, record)
+				return payload, record, true
+			}
+			q.log.Warnf("WAL(%s): skipping the rest of segment %d: %s", q.target, q.readPos.segment, err)
+			current.size = q.readPos.offset
+		}
+		if nextSegment := q.segmentAfter(q.readPos.segment); nextSegment != nil {
+			q.readPos = position{segment: nextSegment.id}
+			continue
+		}
+		q.cond.Wait()
+	}
+}
+
+//readAt reads the record at the position. The segment stays open, so the following record is read without a seek.
+func (q *Queue) readAt(pos position) ([]byte, error) {
+	if q.reader == nil || q.reader.id != pos.segment {
+		q.closeReader()
+		file, err := os.Open(q.segmentFile(pos.segment))
+		if err != nil {
+			return nil, err
+		}
+		q.reader = &segmentReader{id: pos.segment, file: file, reader: bufio.NewReader(file)}
+	}
+	if q.reader.offset != pos.offset {
+		if _, err := q.reader.file.Seek(pos.offset, io.SeekStart); err != nil {
+			q.closeReader()
+			return nil, err
+		}
+		q.reader.reader.Reset(q.reader.file)
+		q.reader.offset = pos.offset
+	}
+	payload, err := readRecord(q.reader.reader)
+	if err != nil {
+		q.closeReader()
+		return nil, err
+	}
+	q.reader.offset += int64(headerSize + len(payload))
+	return payload, nil
+}
+
+func (q *Queue) closeReader() {
+	if q.reader != nil {
+		q.reader.file.Close()
+		q.reader = nil
+	}
+}
+
+func (q *Queue) acknowledge(record *pendingRecord) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	record.acked = true
+	for len(q.pending) > 0 && q.pending[0].acked {
+		q.pending = q.pending[1:]
+	}
+	if len(q.pending) > 0 {
+		q.commitPos = q.pending[0].pos
+	} else {
+		q.commitPos = q.readPos
+	}
+}
+
+//writeCommit persists the position of the first record which has not been acknowledged.
+func (q *Queue) writeCommit() {
+	if q.commitPos == q.committed {
+		return
+	}
+	tmp := path.Join(q.dir, commitFile+".tmp")
+	content := fmt.Sprintf("%d %d\n", q.commitPos.segment, q.commitPos.offset)
+	if err := ioutil.WriteFile(tmp, []byte(content), 0600); err != nil {
+		q.log.Warn(err)
+		return
+	}
+	if err := os.Rename(tmp, path.Join(q.dir, commitFile)); err != nil {
+		q.log.Warn(err)
+		return
+	}
+	q.committed = q.commitPos
+}
+
+//removeCommittedSegments deletes every segment before the commit position.
+func (q *Queue) removeCommittedSegments() {
+	for len(q.segments) > 1 && q.segments[0].id < q.committed.segment {
+		os.Remove(q.segmentFile(q.segments[0].id))
+		q.segments = q.segments[1:]
+	}
+}
+
+func (q *Queue) totalSize() int64 {
+	var size int64
+	for _, s := range q.segments {
+		size += s.size
+	}
+	return size
+}
+
+func (q *Queue) findSegment(id int64) *segment {
+	for _, s := range q.segments {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (q *Queue) segmentAfter(id int64) *segment {
+	for _, s := range q.segments {
+		if s.id > id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (q *Queue) segmentFile(id int64) string {
+	return path.Join(q.dir, fmt.Sprintf("%016d%s", id, segmentSuffix))
+}
+
+//Acknowledge marks the record as handled by the target.
+func (a acknowledger) Acknowledge() {
+	a.queue.acknowledge(a.record)
+}
+
+func readRecord(reader *bufio.Reader) ([]byte, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errorCorrupt
+	}
+	return payload, nil
+}
+
+func encode(p collector.Printable) ([]byte, error) {
+	var r record
+	if simple, ok := p.(collector.SimplePrintable); ok {
+		r = record{Filter: simple.Filter, Text: simple.Text, Datatype: simple.Datatype}
+	} else {
+		points := p.Points()
+		if len(points) == 0 {
+			return nil, errors.New("Printable has no points")
+		}
+		r = record{Filter: points[0].Filter}
+		for _, point := range points {
+			r.Points = append(r.Points, newRecordPoint(point))
+		}
+	}
+	return json.Marshal(r)
+}
+
+func newRecordPoint(p collector.Point) recordPoint {
+	result := recordPoint{Measurement: p.Measurement, Tags: p.Tags, Fields: map[string]recordField{}, Timestamp: p.Timestamp,
+		Precision: p.Precision}
+	for key, value := range p.Fields {
+		var field recordField
+		switch typed := value.(type) {
+		case int64:
+			field.Int = &typed
+		case float64:
+			field.Float = &typed
+		case bool:
+			field.Bool = &typed
+		case string:
+			field.String = &typed
+		default:
+			text := fmt.Sprint(typed)
+			field.String = &text
+		}
+		result.Fields[key] = field
+	}
+	return result
+}
+
+//point converts the record back, the filter is set by the printable.
+func (r recordPoint) point() collector.Point {
+	result := collector.Point{Measurement: r.Measurement, Tags: r.Tags, Fields: map[string]interface{}{}, Timestamp: r.Timestamp,
+		Precision: r.Precision}
+	for key, field := range r.Fields {
+		switch {
+		case field.Int != nil:
+			result.Fields[key] = *field.Int
+		case field.Float != nil:
+			result.Fields[key] = *field.Float
+		case field.Bool != nil:
+			result.Fields[key] = *field.Bool
+		case field.String != nil:
+			result.Fields[key] = *field.String
+		}
+	}
+	return result
+}
+
+func decode(payload []byte) (record, error) {
+	var r record
+	err := json.Unmarshal(payload, &r)
+	return r, err
+}
+
+//withAck creates the printable which is passed to the target.
+func (r record) withAck(ack collector.Acknowledger) collector.Printable {
+	filter := collector.Filterable{Filter: r.Filter, Ack: ack}
+	if r.Datatype != "" {
+		return collector.SimplePrintable{Filterable: filter, Text: r.Text, Datatype: r.Datatype}
+	}
+	result := points{Filterable: filter}
+	for _, point := range r.Points {
+		result.points = append(result.points, point.point())
+	}
+	return result
+}
+
+//points is a printable which contains the points of one record.
+type points struct {
+	collector.Filterable
+	points []collector.Point
+}
+
+//Points returns the points with the filter of the record.
+func (p points) Points() []collector.Point {
+	for i := range p.points {
+		p.points[i].Filterable = p.Filterable
+	}
+	return p.points
+}
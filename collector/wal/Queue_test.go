@@ -0,0 +1,211 @@
+package wal
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var target = data.Target{Name: "a", Datatype: data.InfluxDB}
+
+func testPoint(value float64) collector.Point {
+	return collector.Point{
+		Filterable:  collector.AllFilterable,
+		Measurement: "metrics",
+		Tags:        map[string]string{"host": "h"},
+		Fields:      map[string]interface{}{"value": value},
+		Timestamp:   1000,
+		Precision:   time.Millisecond,
+	}
+}
+
+func receive(t *testing.T, q *Queue) collector.Printable {
+	select {
+	case p := <-q.Out():
+		return p
+	case <-time.After(time.Duration(2) * time.Second):
+		t.Fatal("Expected data from the WAL")
+	}
+	return nil
+}
+
+func expectEmpty(t *testing.T, q *Queue) {
+	select {
+	case p := <-q.Out():
+		t.Errorf("Expected no data, got: %v", p.Points())
+	case <-time.After(time.Duration(100) * time.Millisecond):
+	}
+}
+
+func TestQueue_ReplayUnacknowledged(t *testing.T) {
+	t.Parallel()
+	folder, _ := ioutil.TempDir("", "wal")
+	defer os.RemoveAll(folder)
+
+	q, err := NewQueue(folder, target, 1024, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		q.In() <- testPoint(float64(i))
+	}
+	q.In() <- collector.SimplePrintable{Filterable: collector.AllFilterable, Text: "line", Datatype: data.InfluxDB}
+	q.In() <- collector.Point{Filterable: collector.Filterable{Filter: "b"}, Measurement: "metrics"}
+
+	first := receive(t, q)
+	if !reflect.DeepEqual(first.Points()[0].Fields, testPoint(0).Fields) {
+		t.Errorf("Wrong point, expected: %v, actual: %v", testPoint(0).Fields, first.Points()[0].Fields)
+	}
+	second := receive(t, q)
+	receive(t, q)
+	simple, ok := receive(t, q).(collector.SimplePrintable)
+	if !ok || simple.Text != "line" || simple.Datatype != data.InfluxDB {
+		t.Errorf("Expected the SimplePrintable, actual: %v", simple)
+	}
+	expectEmpty(t, q)
+	//Only the first record can be committed, the third has not been acknowledged
+	first.Acknowledge()
+	second.Acknowledge()
+	q.Stop()
+
+	q, err = NewQueue(folder, target, 1024, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Stop()
+	replayed := receive(t, q)
+	if !reflect.DeepEqual(replayed.Points()[0].Fields, testPoint(2).Fields) {
+		t.Errorf("Wrong replay, expected: %v, actual: %v", testPoint(2).Fields, replayed.Points()[0].Fields)
+	}
+	receive(t, q)
+	expectEmpty(t, q)
+}
+
+func TestQueue_Rewind(t *testing.T) {
+	t.Parallel()
+	folder, _ := ioutil.TempDir("", "wal")
+	defer os.RemoveAll(folder)
+
+	q, err := NewQueue(folder, target, 1024, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Stop()
+	for i := 0; i < 4; i++ {
+		q.In() <- testPoint(float64(i))
+	}
+	//the stopped target acknowledged 0 and 2, 1 was not sent and 3 is still in Out
+	for i := 0; i < 3; i++ {
+		if p := receive(t, q); i != 1 {
+			p.Acknowledge()
+		}
+	}
+	q.Rewind()
+	for _, expected := range []float64{1, 3} {
+		p := receive(t, q)
+		if value := p.Points()[0].Fields["value"]; value != expected {
+			t.Errorf("Expected: %f, actual: %v", expected, value)
+		}
+	}
+	expectEmpty(t, q)
+}
+
+func TestQueue_Eviction(t *testing.T) {
+	t.Parallel()
+	folder, _ := ioutil.TempDir("", "wal")
+	defer os.RemoveAll(folder)
+
+	//Every record gets its own segment, only the last two fit
+	payload, _ := encode(testPoint(0))
+	recordSize := int64(headerSize + len(payload))
+	q, err := NewQueue(folder, target, recordSize, 2*recordSize, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		q.In() <- testPoint(float64(i))
+	}
+	q.Stop()
+	files, _ := ioutil.ReadDir(path.Join(folder, target.String()))
+	segments := 0
+	for _, file := range files {
+		if path.Ext(file.Name()) == segmentSuffix {
+			segments++
+		}
+	}
+	if segments != 2 {
+		t.Errorf("Expected 2 segments, actual: %d", segments)
+	}
+
+	q, err = NewQueue(folder, target, recordSize, 2*recordSize, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Stop()
+	for _, expected := range []float64{3, 4} {
+		p := receive(t, q)
+		if value := p.Points()[0].Fields["value"]; value != expected {
+			t.Errorf("Expected: %f, actual: %v", expected, value)
+		}
+	}
+}
+
+func TestQueue_TornRecord(t *testing.T) {
+	t.Parallel()
+	folder, _ := ioutil.TempDir("", "wal")
+	defer os.RemoveAll(folder)
+
+	q, err := NewQueue(folder, target, 1024, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.In() <- testPoint(1)
+	q.Stop()
+	//Simulate a crash while writing the second record
+	segmentFile := q.segmentFile(1)
+	f, _ := os.OpenFile(segmentFile, os.O_APPEND|os.O_WRONLY, 0600)
+	f.Write([]byte{0, 0, 0, 42, 1, 2})
+	f.Close()
+
+	q, err = NewQueue(folder, target, 1024, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.In() <- testPoint(2)
+	for _, expected := range []float64{1, 2} {
+		p := receive(t, q)
+		if value := p.Points()[0].Fields["value"]; value != expected {
+			t.Errorf("Expected: %f, actual: %v", expected, value)
+		}
+	}
+	q.Stop()
+}
+
+func TestRecord_RoundTrip(t *testing.T) {
+	point := collector.Point{
+		Filterable:  collector.Filterable{Filter: "a"},
+		Measurement: "states",
+		Tags:        map[string]string{"host": "h", "service": "s"},
+		Fields:      map[string]interface{}{"state": int64(2), "latency": 0.5, "whole": 2.0, "output": "CRITICAL", "flapping": true},
+		Timestamp:   1000,
+		Precision:   time.Second,
+	}
+	payload, err := encode(point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := decode(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := r.withAck(nil).Points()
+	point.Filterable = collector.Filterable{Filter: "a"}
+	if len(actual) != 1 || !reflect.DeepEqual(actual[0], point) {
+		t.Errorf("Expected: %#v\nactual: %#v", point, actual)
+	}
+}
@@ -13,6 +13,9 @@ type Config struct {
 		BufferSize             int
 		FileBufferSize         int
 		DefaultTarget          string
+		WALFolder              string
+		WALSegmentSize         int64
+		WALMaxSize             int64
 	}
 	ModGearman map[string]*struct {
 		Enabled    bool
@@ -29,6 +32,24 @@ type Config struct {
 	Monitoring struct {
 		PrometheusAddress string
 	}
+	API struct {
+		Address string
+	}
+	UnitNormalization struct {
+		Enabled   bool
+		RulesFile string
+	}
+	CounterRate struct {
+		Enabled   bool
+		StateFile string
+	}
+	Relabel map[string]*struct {
+		Source      string
+		Regex       string
+		Action      string
+		Target      string
+		Replacement string
+	}
 	InfluxDBGlobal struct {
 		CreateDatabaseIfNotExists bool
 		NastyString               string
@@ -37,17 +58,50 @@ type Config struct {
 		ClientTimeout		      int
 	}
 	InfluxDB map[string]*struct {
-		Enabled               bool
-		Address               string
-		Arguments             string
-		Version               string
-		StopPullingDataIfDown bool
-	}
-	Livestatus struct {
-		Type          string
-		Address       string
-		MinutesToWait int
-		Version       string
+		Enabled                      bool
+		Address                      string
+		Arguments                    string
+		Version                      string
+		StopPullingDataIfDown        bool
+		Org                          string
+		Bucket                       string
+		Token                        string
+		RetentionPeriod              string
+		HostRegex                    string
+		ServiceRegex                 string
+		CommandRegex                 string
+		PerformanceLabelRegex        string
+		ExcludeHostRegex             string
+		ExcludeServiceRegex          string
+		ExcludeCommandRegex          string
+		ExcludePerformanceLabelRegex string
+	}
+	Icinga2 struct {
+		Enabled            bool
+		Address            string
+		User               string
+		Password           string
+		Queue              string
+		Filter             string
+		InsecureSkipVerify bool
+	}
+	Livestatus map[string]*struct {
+		Type                   string
+		Address                string
+		MinutesToWait          int
+		Version                string
+		DialTimeout            int
+		ReadTimeout            int
+		KeepAlive              bool
+		CAFile                 string
+		CertFile               string
+		KeyFile                string
+		InsecureSkipVerify     bool
+		DeduplicationSize      int
+		DeduplicationStateFile string
+		StatesInterval         int
+		CustomVariables        string
+		Groups                 bool
 	}
 	ElasticsearchGlobal struct {
 		HostcheckAlias   string
@@ -56,14 +110,57 @@ type Config struct {
 		IndexRotation    string
 	}
 	Elasticsearch map[string]*struct {
-		Enabled bool
-		Address string
-		Index   string
-		Version string
+		Enabled                      bool
+		Address                      string
+		Index                        string
+		Version                      string
+		HostRegex                    string
+		ServiceRegex                 string
+		CommandRegex                 string
+		PerformanceLabelRegex        string
+		ExcludeHostRegex             string
+		ExcludeServiceRegex          string
+		ExcludeCommandRegex          string
+		ExcludePerformanceLabelRegex string
+	}
+	PrometheusRemoteWrite map[string]*struct {
+		Enabled       bool
+		Address       string
+		ClientTimeout int
+	}
+	Graphite map[string]*struct {
+		Enabled       bool
+		Address       string
+		Template      string
+		Connections   int
+		ClientTimeout int
+	}
+	Kafka map[string]*struct {
+		Enabled             bool
+		Brokers             string
+		MetricsTopic        string
+		MessagesTopic       string
+		Format              string
+		MaxBufferedMessages int
+		ClientTimeout       int
+	}
+	OTLP map[string]*struct {
+		Enabled       bool
+		Address       string
+		Headers       string
+		ClientTimeout int
 	}
 	JSONFileExport map[string]*struct {
-		Enabled               bool
-		Path                  string
-		AutomaticFileRotation int
+		Enabled                      bool
+		Path                         string
+		AutomaticFileRotation        int
+		HostRegex                    string
+		ServiceRegex                 string
+		CommandRegex                 string
+		PerformanceLabelRegex        string
+		ExcludeHostRegex             string
+		ExcludeServiceRegex          string
+		ExcludeCommandRegex          string
+		ExcludePerformanceLabelRegex string
 	}
 }
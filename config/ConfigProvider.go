@@ -19,6 +19,20 @@ func InitConfig(configPath string) {
 	}
 }
 
+//ReadConfig parses the config file without replacing the current config
+func ReadConfig(configPath string) (Config, error) {
+	var newConfig Config
+	err := gcfg.ReadFileInto(&newConfig, configPath)
+	return newConfig, err
+}
+
+//SetConfig replaces the current config, used to apply a reloaded config
+func SetConfig(newConfig Config) {
+	mutex.Lock()
+	config = newConfig
+	mutex.Unlock()
+}
+
 //GetConfig returns the static config object
 func GetConfig() Config {
 	return config
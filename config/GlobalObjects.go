@@ -27,8 +27,16 @@ func IsAnyTargetOnPause() bool {
 	return result
 }
 
+//StoreValue sets the pause state of the target
 func StoreValue(target data.Target, value bool) {
 	objMutex.Lock()
 	pauseNagflux[target] = value
 	objMutex.Unlock()
 }
+
+//DeleteValue removes the target, used if the target has been stopped
+func DeleteValue(target data.Target) {
+	objMutex.Lock()
+	delete(pauseNagflux, target)
+	objMutex.Unlock()
+}
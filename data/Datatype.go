@@ -10,4 +10,12 @@ const (
 	Elasticsearch Datatype = "elastic"
 	//TemplateFile enum
 	JSONFile Datatype = "json"
+	//Prometheus enum
+	Prometheus Datatype = "prometheus"
+	//Graphite enum
+	Graphite Datatype = "graphite"
+	//Kafka enum
+	Kafka Datatype = "kafka"
+	//OTLP enum
+	OTLP Datatype = "otlp"
 )
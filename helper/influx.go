@@ -1,7 +1,10 @@
 package helper
 
 import (
+	"errors"
+	"fmt"
 	"github.com/griesbacher/nagflux/config"
+	"strconv"
 	"strings"
 )
 
@@ -36,3 +39,98 @@ func SanitizeMap(input map[string]string) map[string]string {
 	}
 	return result
 }
+
+//InfluxLine is a parsed line of the InfluxDB line protocol, field values are kept raw.
+type InfluxLine struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]string
+	Timestamp   string
+}
+
+//ParseInfluxLine splits a line of the InfluxDB line protocol into its parts.
+func ParseInfluxLine(line string) (InfluxLine, error) {
+	result := InfluxLine{Tags: map[string]string{}, Fields: map[string]string{}}
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return result, errors.New("Empty line")
+	}
+	parts := splitUnescaped(line, ' ', true)
+	if len(parts) < 2 || len(parts) > 3 {
+		return result, fmt.Errorf("Line has %d space separated parts: %s", len(parts), line)
+	}
+	series := splitUnescaped(parts[0], ',', false)
+	result.Measurement = unescapeInflux(series[0])
+	for _, tag := range series[1:] {
+		keyValue := splitUnescaped(tag, '=', false)
+		if len(keyValue) != 2 {
+			return result, fmt.Errorf("Tag is not valid: %s", tag)
+		}
+		result.Tags[unescapeInflux(keyValue[0])] = unescapeInflux(keyValue[1])
+	}
+	for _, field := range splitUnescaped(parts[1], ',', true) {
+		keyValue := splitUnescaped(field, '=', true)
+		if len(keyValue) < 2 {
+			return result, fmt.Errorf("Field is not valid: %s", field)
+		}
+		result.Fields[unescapeInflux(keyValue[0])] = strings.Join(keyValue[1:], "=")
+	}
+	if len(result.Fields) == 0 {
+		return result, errors.New("Line has no fields: " + line)
+	}
+	if len(parts) == 3 {
+		if _, err := strconv.ParseInt(parts[2], 10, 64); err != nil {
+			return result, fmt.Errorf("Timestamp is not valid: %s", parts[2])
+		}
+		result.Timestamp = parts[2]
+	}
+	return result, nil
+}
+
+//InfluxFieldValue converts a raw field value to string, float64, int64 or bool.
+func InfluxFieldValue(raw string) interface{} {
+	if len(raw) > 1 && raw[0] == '"' && raw[len(raw)-1] == '"' {
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(raw[1 : len(raw)-1])
+	}
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return true
+	case "f", "F", "false", "False", "FALSE":
+		return false
+	}
+	if strings.HasSuffix(raw, "i") {
+		if i, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64); err == nil {
+			return i
+		}
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f
+	}
+	return raw
+}
+
+//splitUnescaped splits at every separator which is not escaped by a backslash or within double quotes.
+func splitUnescaped(input string, separator byte, respectQuotes bool) []string {
+	var result []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(input); i++ {
+		switch {
+		case input[i] == '\\' && i+1 < len(input) && (inQuotes || strings.IndexByte(` ,="`, input[i+1]) >= 0):
+			i++
+		case input[i] == '"' && respectQuotes:
+			inQuotes = !inQuotes
+		case input[i] == separator && !inQuotes:
+			if i > start || separator != ' ' {
+				result = append(result, input[start:i])
+			}
+			start = i + 1
+		}
+	}
+	return append(result, input[start:])
+}
+
+//unescapeInflux removes the backslashes in front of spaces, commas and equal signs.
+func unescapeInflux(input string) string {
+	return strings.NewReplacer(`\ `, " ", `\,`, ",", `\=`, "=").Replace(input)
+}
@@ -56,3 +56,59 @@ func TestSanitizeMap(t *testing.T) {
 		}
 	}
 }
+
+var ParseInfluxLineData = []struct {
+	input    string
+	expected InfluxLine
+	err      bool
+}{
+	{`metrics,host=h1,service=s1 value=1.0 1000`,
+		InfluxLine{"metrics", map[string]string{"host": "h1", "service": "s1"}, map[string]string{"value": "1.0"}, "1000"}, false},
+	{`metrics,host=h\ 1,performanceLabel=C:\\ use value=1.0,warn=2.0 1000`,
+		InfluxLine{"metrics", map[string]string{"host": "h 1", "performanceLabel": `C:\ use`}, map[string]string{"value": "1.0", "warn": "2.0"}, "1000"}, false},
+	{`messages,host=h1 message="a b, c=d" 1000`,
+		InfluxLine{"messages", map[string]string{"host": "h1"}, map[string]string{"message": `"a b, c=d"`}, "1000"}, false},
+	{`messages message="Hallo \\" 1000`,
+		InfluxLine{"messages", map[string]string{}, map[string]string{"message": `"Hallo \\"`}, "1000"}, false},
+	{`messages message="Hallo \"World\""`,
+		InfluxLine{"messages", map[string]string{}, map[string]string{"message": `"Hallo \"World\""`}, ""}, false},
+	{`metrics,host=h1`, InfluxLine{}, true},
+	{`metrics,host value=1`, InfluxLine{}, true},
+	{`metrics value=1 abc`, InfluxLine{}, true},
+	{``, InfluxLine{}, true},
+}
+
+func TestParseInfluxLine(t *testing.T) {
+	t.Parallel()
+	for _, data := range ParseInfluxLineData {
+		actual, err := ParseInfluxLine(data.input)
+		if (err != nil) != data.err {
+			t.Errorf("ParseInfluxLine(%s): expected error: %t, got: %v", data.input, data.err, err)
+			continue
+		}
+		if !data.err && !reflect.DeepEqual(actual, data.expected) {
+			t.Errorf("ParseInfluxLine(%s): expected: %v, actual: %v", data.input, data.expected, actual)
+		}
+	}
+}
+
+var InfluxFieldValueData = []struct {
+	input    string
+	expected interface{}
+}{
+	{`"a \"b\""`, `a "b"`},
+	{`1.5`, 1.5},
+	{`3i`, int64(3)},
+	{`true`, true},
+	{`F`, false},
+	{`abc`, "abc"},
+}
+
+func TestInfluxFieldValue(t *testing.T) {
+	t.Parallel()
+	for _, data := range InfluxFieldValueData {
+		if actual := InfluxFieldValue(data.input); !reflect.DeepEqual(actual, data.expected) {
+			t.Errorf("InfluxFieldValue(%s): expected: %v, actual: %v", data.input, data.expected, actual)
+		}
+	}
+}
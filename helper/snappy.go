@@ -0,0 +1,35 @@
+package helper
+
+import (
+	"encoding/binary"
+)
+
+//maxSnappyLiteral is the biggest chunk which is written as one literal element.
+const maxSnappyLiteral = 1 << 16
+
+//SnappyEncode returns the input in the snappy block format. It emits literals only,
+//which is valid for every decoder but does not shrink the data.
+func SnappyEncode(input []byte) []byte {
+	header := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(header, uint64(len(input)))
+	result := make([]byte, 0, n+len(input)+len(input)/maxSnappyLiteral*3+3)
+	result = append(result, header[:n]...)
+	for len(input) > 0 {
+		chunk := input
+		if len(chunk) > maxSnappyLiteral {
+			chunk = chunk[:maxSnappyLiteral]
+		}
+		input = input[len(chunk):]
+		length := len(chunk) - 1
+		switch {
+		case length < 60:
+			result = append(result, byte(length<<2))
+		case length < 1<<8:
+			result = append(result, 60<<2, byte(length))
+		default:
+			result = append(result, 61<<2, byte(length), byte(length>>8))
+		}
+		result = append(result, chunk...)
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+)
+
+var SnappyEncodeData = []struct {
+	input    []byte
+	expected []byte
+}{
+	{[]byte{}, []byte{0x00}},
+	{[]byte("a"), []byte{0x01, 0x00, 'a'}},
+	{[]byte("nagflux"), append([]byte{0x07, 6 << 2}, []byte("nagflux")...)},
+	{bytes.Repeat([]byte("x"), 100), append([]byte{100, 60 << 2, 99}, bytes.Repeat([]byte("x"), 100)...)},
+	{bytes.Repeat([]byte("x"), 300), append([]byte{0xac, 0x02, 61 << 2, 0x2b, 0x01}, bytes.Repeat([]byte("x"), 300)...)},
+}
+
+func TestSnappyEncode(t *testing.T) {
+	t.Parallel()
+	for _, data := range SnappyEncodeData {
+		actual := SnappyEncode(data.input)
+		if !bytes.Equal(actual, data.expected) {
+			t.Errorf("SnappyEncode(%d bytes): expected:%v, actual:%v", len(data.input), data.expected, actual)
+		}
+	}
+}
+
+func TestSnappyEncodeSplitsLiterals(t *testing.T) {
+	t.Parallel()
+	input := bytes.Repeat([]byte("y"), maxSnappyLiteral+1)
+	actual := SnappyEncode(input)
+	//3 byte varint, 3 byte tag + 65536 bytes, 1 byte tag + 1 byte
+	if len(actual) != 3+3+maxSnappyLiteral+1+1 {
+		t.Errorf("Unexpected length: %d", len(actual))
+	}
+	if actual[3+3+maxSnappyLiteral] != 0x00 {
+		t.Errorf("Second literal tag is wrong: %x", actual[3+3+maxSnappyLiteral])
+	}
+}
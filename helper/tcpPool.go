@@ -0,0 +1,103 @@
+package helper
+
+import (
+	"net"
+	"time"
+)
+
+//probeTimeout is the time an idle connection is probed before it is reused.
+const probeTimeout = time.Duration(1) * time.Millisecond
+
+//TCPPool keeps a limited amount of connections to one address and dials new ones if needed.
+type TCPPool struct {
+	typ         string
+	address     string
+	dialTimeout time.Duration
+	connections chan net.Conn
+}
+
+//NewTCPPool creates a pool which holds up to size idle connections.
+func NewTCPPool(typ, address string, size int, dialTimeout time.Duration) *TCPPool {
+	if size < 1 {
+		size = 1
+	}
+	return &TCPPool{typ: typ, address: address, dialTimeout: dialTimeout, connections: make(chan net.Conn, size)}
+}
+
+//Get returns an idle connection or dials a new one. Idle connections which were closed by the server are discarded.
+func (pool *TCPPool) Get() (net.Conn, error) {
+	for {
+		select {
+		case conn := <-pool.connections:
+			if isAlive(conn) {
+				return conn, nil
+			}
+			pool.Discard(conn)
+		default:
+			return net.DialTimeout(pool.typ, pool.address, pool.dialTimeout)
+		}
+	}
+}
+
+//isAlive probes an idle connection by a short read. The server never sends anything, so a working connection runs into
+//the deadline, whereas a connection closed by the server, e.g. on a restart, returns EOF. Writing to such a half-closed
+//connection would succeed and the data would be lost.
+func isAlive(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(probeTimeout))
+	_, err := conn.Read(make([]byte, 1))
+	conn.SetReadDeadline(time.Time{})
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+//Put returns a working connection to the pool, it gets closed if the pool is full.
+func (pool *TCPPool) Put(conn net.Conn) {
+	if conn == nil {
+		return
+	}
+	select {
+	case pool.connections <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+//Discard closes a broken connection, the next Get will reconnect.
+func (pool *TCPPool) Discard(conn net.Conn) {
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+//Write sends the data over a pooled connection. If the connection is broken it reconnects once.
+func (pool *TCPPool) Write(data []byte, writeTimeout time.Duration) error {
+	var err error
+	for i := 0; i < 2; i++ {
+		var conn net.Conn
+		if conn, err = pool.Get(); err != nil {
+			continue
+		}
+		if writeTimeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		}
+		if _, err = conn.Write(data); err != nil {
+			pool.Discard(conn)
+			continue
+		}
+		pool.Put(conn)
+		return nil
+	}
+	return err
+}
+
+//Close closes all idle connections.
+func (pool *TCPPool) Close() {
+	for {
+		select {
+		case conn := <-pool.connections:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package helper
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPPoolWrite(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+				conn.Close()
+			}(conn)
+		}
+	}()
+
+	pool := NewTCPPool("tcp", l.Addr().String(), 2, time.Duration(1)*time.Second)
+	defer pool.Close()
+	for _, line := range []string{"a 1 1", "b 2 2"} {
+		if err := pool.Write([]byte(line+"\n"), time.Duration(1)*time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pool.connections) != 1 {
+		t.Errorf("The connection should be reused, idle connections: %d", len(pool.connections))
+	}
+	for _, expected := range []string{"a 1 1", "b 2 2"} {
+		select {
+		case line := <-lines:
+			if line != expected {
+				t.Errorf("Expected: %s, got: %s", expected, line)
+			}
+		case <-time.After(time.Duration(2) * time.Second):
+			t.Fatal("Server did not receive the data")
+		}
+	}
+}
+
+func TestTCPPoolReconnect(t *testing.T) {
+	t.Parallel()
+	pool := NewTCPPool("tcp", "localhost:1", 1, time.Duration(100)*time.Millisecond)
+	if err := pool.Write([]byte("a 1 1\n"), time.Duration(100)*time.Millisecond); err == nil {
+		t.Error("Writing to a closed port should fail")
+	}
+	if len(pool.connections) != 0 {
+		t.Error("Broken connections should not be pooled")
+	}
+}
+
+func TestTCPPoolServerClosed(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	lines := make(chan string, 10)
+	//the server closes every connection after the first line, like a restarted carbon server
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				scanner := bufio.NewScanner(conn)
+				if scanner.Scan() {
+					conn.Close()
+					lines <- scanner.Text()
+				}
+			}(conn)
+		}
+	}()
+
+	pool := NewTCPPool("tcp", l.Addr().String(), 2, time.Duration(1)*time.Second)
+	defer pool.Close()
+	for _, line := range []string{"a 1 1", "b 2 2"} {
+		if err := pool.Write([]byte(line+"\n"), time.Duration(1)*time.Second); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case received := <-lines:
+			if received != line {
+				t.Errorf("Expected: %s, got: %s", line, received)
+			}
+		case <-time.After(time.Duration(2) * time.Second):
+			t.Fatalf("Server did not receive: %s", line)
+		}
+		//give the FIN time to arrive
+		time.Sleep(time.Duration(50) * time.Millisecond)
+	}
+}
+
+func TestTCPPoolIsAlive(t *testing.T) {
+	t.Parallel()
+	client, server := net.Pipe()
+	if !isAlive(client) {
+		t.Error("An open connection should be alive")
+	}
+	server.Close()
+	if isAlive(client) {
+		t.Error("A connection closed by the server should not be alive")
+	}
+	client.Close()
+}
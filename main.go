@@ -4,17 +4,14 @@ import (
 	"flag"
 	"fmt"
 	"github.com/griesbacher/nagflux/collector"
-	"github.com/griesbacher/nagflux/collector/livestatus"
-	"github.com/griesbacher/nagflux/collector/modGearman"
+	"github.com/griesbacher/nagflux/collector/api"
+	"github.com/griesbacher/nagflux/collector/icinga2"
 	"github.com/griesbacher/nagflux/collector/nagflux"
+	"github.com/griesbacher/nagflux/collector/relabel"
 	"github.com/griesbacher/nagflux/collector/spoolfile"
 	"github.com/griesbacher/nagflux/config"
-	"github.com/griesbacher/nagflux/data"
 	"github.com/griesbacher/nagflux/logging"
 	"github.com/griesbacher/nagflux/statistics"
-	"github.com/griesbacher/nagflux/target/elasticsearch"
-	"github.com/griesbacher/nagflux/target/file/json"
-	"github.com/griesbacher/nagflux/target/influx"
 	"github.com/kdar/factorlog"
 	"os"
 	"os/signal"
@@ -73,91 +70,62 @@ For further informations / bugs reportes: https://github.com/Griesbacher/nagflux
 	log = logging.GetLogger()
 	log.Info(`Started Nagflux `, nagfluxVersion)
 	log.Debugf("Using Config: %s", configPath)
-	resultQueues := collector.ResultQueues{}
-	stoppables := []Stoppable{}
+	resultQueues := collector.NewResultQueues()
 	if len(cfg.Main.FieldSeparator) < 1 {
 		panic("FieldSeparator is too short!")
 	}
+	relabeler, err := relabel.FromConfig(cfg)
+	if err != nil {
+		panic(err)
+	}
+	resultQueues.SetRewriter(relabeler)
 	pro := statistics.NewPrometheusServer(cfg.Monitoring.PrometheusAddress)
 	pro.WatchResultQueueLength(resultQueues)
 	fieldSeparator := []rune(cfg.Main.FieldSeparator)[0]
 
-	for name, value := range cfg.InfluxDB {
-		if value == nil || !(*value).Enabled {
-			continue
-		}
-		influxConfig := (*value)
-		target := data.Target{Name: name, Datatype: data.InfluxDB}
-		config.StoreValue(target, false)
-		resultQueues[target] = make(chan collector.Printable, cfg.Main.BufferSize)
-		influx := influx.ConnectorFactory(
-			resultQueues[target],
-			influxConfig.Address, influxConfig.Arguments, cfg.Main.DumpFile, influxConfig.Version,
-			cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, cfg.InfluxDBGlobal.CreateDatabaseIfNotExists,
-			influxConfig.StopPullingDataIfDown, target, cfg.InfluxDBGlobal.ClientTimeout,
-		)
-		stoppables = append(stoppables, influx)
-		influxDumpFileCollector := nagflux.NewDumpfileCollector(resultQueues[target], cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
-		waitForDumpfileCollector(influxDumpFileCollector)
-		stoppables = append(stoppables, influxDumpFileCollector)
-	}
-
-	for name, value := range cfg.Elasticsearch {
-		if value == nil || !(*value).Enabled {
-			continue
-		}
-		elasticConfig := (*value)
-		target := data.Target{Name: name, Datatype: data.Elasticsearch}
-		resultQueues[target] = make(chan collector.Printable, cfg.Main.BufferSize)
-		config.StoreValue(target, false)
-		elasticsearch := elasticsearch.ConnectorFactory(
-			resultQueues[target],
-			elasticConfig.Address, elasticConfig.Index, cfg.Main.DumpFile, elasticConfig.Version,
-			cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, true,
-		)
-		stoppables = append(stoppables, elasticsearch)
-		elasticDumpFileCollector := nagflux.NewDumpfileCollector(resultQueues[target], cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
-		waitForDumpfileCollector(elasticDumpFileCollector)
-		stoppables = append(stoppables, elasticDumpFileCollector)
-	}
-
-	for name, value := range cfg.JSONFileExport {
-		if value == nil || !(*value).Enabled {
-			continue
-		}
-		jsonFileConfig := (*value)
-		target := data.Target{Name: name, Datatype: data.JSONFile}
-		resultQueues[target] = make(chan collector.Printable, cfg.Main.BufferSize)
-		templateFile := json.NewJSONFileWorker(
-			log, jsonFileConfig.AutomaticFileRotation,
-			resultQueues[target], target, jsonFileConfig.Path,
-		)
-		stoppables = append(stoppables, templateFile)
+	starters, err := targetsFromConfig(cfg)
+	if err != nil {
+		panic(err)
 	}
+	targets := startTargets(starters, resultQueues, queueConfigFromConfig(cfg))
 
 	//Some time for the dumpfile to fill the queue
 	time.Sleep(time.Duration(100) * time.Millisecond)
 
-	liveconnector := &livestatus.Connector{log, cfg.Livestatus.Address, cfg.Livestatus.Type}
-	livestatusCollector := livestatus.NewLivestatusCollector(resultQueues, liveconnector, cfg.Livestatus.Version)
-	livestatusCache := livestatus.NewLivestatusCacheBuilder(liveconnector)
+	livestatusStoppables, livestatusCache, err := startLivestatus(cfg, resultQueues)
+	if err != nil {
+		panic(err)
+	}
 
-	for name, data := range cfg.ModGearman {
-		if data == nil || !(*data).Enabled {
-			continue
+	var unitNormalizer *spoolfile.UnitNormalizer
+	if cfg.UnitNormalization.Enabled {
+		log.Info("Unit normalization rules: ", cfg.UnitNormalization.RulesFile)
+		unitNormalizer, err = spoolfile.NewUnitNormalizer(cfg.UnitNormalization.RulesFile)
+		if err != nil {
+			panic(err)
 		}
-		log.Infof("Mod_Gearman: %s - %s [%s]", name, (*data).Address, (*data).Queue)
-		secret := modGearman.GetSecret((*data).Secret, (*data).SecretFile)
-		for i := 0; i < (*data).Worker; i++ {
-			gearmanWorker := modGearman.NewGearmanWorker((*data).Address,
-				(*data).Queue,
-				secret,
-				resultQueues,
-				livestatusCache,
-			)
-			stoppables = append(stoppables, gearmanWorker)
+	}
+	var rateCalculator *spoolfile.RateCalculator
+	if cfg.CounterRate.Enabled {
+		log.Info("Counter rate state file: ", cfg.CounterRate.StateFile)
+		rateCalculator = spoolfile.NewRateCalculator(cfg.CounterRate.StateFile)
+	}
+
+	gearmanFingerprints, err := gearmanFromConfig(cfg)
+	if err != nil {
+		panic(err)
+	}
+	gearman := map[string]*runningGearman{}
+	for _, name := range sortedKeys(gearmanFingerprints) {
+		gearman[name] = &runningGearman{
+			fingerprint: gearmanFingerprints[name],
+			workers:     startGearman(name, cfg, resultQueues, livestatusCache, unitNormalizer, rateCalculator),
 		}
 	}
+	reload := &reloader{
+		configPath: configPath, resultQueues: resultQueues, queues: queueConfigFromConfig(cfg), livestatusCache: livestatusCache,
+		unitNormalizer: unitNormalizer, rateCalculator: rateCalculator, targets: targets, gearman: gearman,
+	}
 
 	log.Info("Nagios Spoolfile Folder: ", cfg.Main.NagiosSpoolfileFolder)
 	nagiosCollector := spoolfile.NagiosSpoolfileCollectorFactory(
@@ -165,6 +133,8 @@ For further informations / bugs reportes: https://github.com/Griesbacher/nagflux
 		cfg.Main.NagiosSpoolfileWorker,
 		resultQueues,
 		livestatusCache,
+		unitNormalizer,
+		rateCalculator,
 		cfg.Main.FileBufferSize,
 		collector.Filterable{Filter: cfg.Main.DefaultTarget},
 	)
@@ -172,6 +142,40 @@ For further informations / bugs reportes: https://github.com/Griesbacher/nagflux
 	log.Info("Nagflux Spoolfile Folder: ", cfg.Main.NagfluxSpoolfileFolder)
 	nagfluxCollector := nagflux.NewNagfluxFileCollector(resultQueues, cfg.Main.NagfluxSpoolfileFolder, fieldSeparator)
 
+	itemsToStop := append([]Stoppable{reload}, livestatusStoppables...)
+	itemsToStop = append(itemsToStop, livestatusCache, nagiosCollector, nagfluxCollector)
+	if rateCalculator != nil {
+		//Stopped last, so the state contains the counters of all collectors
+		itemsToStop = append([]Stoppable{rateCalculator}, itemsToStop...)
+	}
+	if cfg.Icinga2.Enabled {
+		log.Info("Icinga2 API: ", cfg.Icinga2.Address)
+		itemsToStop = append(itemsToStop, icinga2.NewIcinga2Collector(
+			resultQueues, cfg.Icinga2.Address, cfg.Icinga2.User, cfg.Icinga2.Password, cfg.Icinga2.Queue, cfg.Icinga2.Filter,
+			cfg.Icinga2.InsecureSkipVerify, livestatusCache, unitNormalizer, rateCalculator, cfg.Main.FileBufferSize, collector.Filterable{Filter: cfg.Main.DefaultTarget},
+		))
+	}
+	if cfg.API.Address != "" {
+		log.Info("API Address: ", cfg.API.Address)
+		apiCollector, err := api.NewAPICollector(
+			resultQueues, cfg.API.Address, livestatusCache, unitNormalizer, rateCalculator, cfg.Main.FileBufferSize, fieldSeparator,
+			collector.Filterable{Filter: cfg.Main.DefaultTarget},
+		)
+		if err != nil {
+			panic(err)
+		}
+		itemsToStop = append(itemsToStop, apiCollector)
+	}
+
+	//Listen for reloads
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, syscall.SIGHUP)
+	go func() {
+		for range reloadChannel {
+			reload.reload()
+		}
+	}()
+
 	//Listen for Interrupts
 	interruptChannel := make(chan os.Signal, 1)
 	signal.Notify(interruptChannel, syscall.SIGINT)
@@ -179,8 +183,7 @@ For further informations / bugs reportes: https://github.com/Griesbacher/nagflux
 	go func() {
 		<-interruptChannel
 		log.Warn("Got Interrupted")
-		stoppables = append(stoppables, []Stoppable{livestatusCollector, livestatusCache, nagiosCollector, nagfluxCollector}...)
-		cleanUp(stoppables, resultQueues)
+		cleanUp(itemsToStop, resultQueues)
 		quit <- true
 	}()
 	loop:
@@ -222,7 +225,7 @@ func cleanUp(itemsToStop []Stoppable, resultQueues collector.ResultQueues) {
 		itemsToStop[i].Stop()
 		time.Sleep(500 * time.Millisecond)
 	}
-	for _, q := range resultQueues {
+	for _, q := range resultQueues.All() {
 		log.Debugf("Remaining queries %d", len(q))
 	}
 }
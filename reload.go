@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/collector/livestatus"
+	"github.com/griesbacher/nagflux/collector/relabel"
+	"github.com/griesbacher/nagflux/collector/spoolfile"
+	"github.com/griesbacher/nagflux/config"
+	"github.com/griesbacher/nagflux/data"
+	"sort"
+	"sync"
+)
+
+//reloader applies a changed config to the running targets and ModGearman workers.
+//The queues of unchanged and restarted targets are kept, so no data gets lost.
+type reloader struct {
+	configPath      string
+	resultQueues    collector.ResultQueues
+	queues          queueConfig
+	livestatusCache livestatus.CacheBuilders
+	unitNormalizer  *spoolfile.UnitNormalizer
+	rateCalculator  *spoolfile.RateCalculator
+	targets         map[data.Target]*runningTarget
+	gearman         map[string]*runningGearman
+	mutex           sync.Mutex
+}
+
+//reload reads the config file and starts, stops or restarts everything which changed.
+//If the file is not valid the running config is kept.
+func (r *reloader) reload() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	log.Infof("Reloading config: %s", r.configPath)
+	cfg, err := config.ReadConfig(r.configPath)
+	if err != nil {
+		log.Errorf("Could not reload config, keeping the old one: %s", err)
+		return
+	}
+	gearmanFingerprints, err := gearmanFromConfig(cfg)
+	if err != nil {
+		log.Errorf("Could not reload config, keeping the old one: %s", err)
+		return
+	}
+	relabeler, err := relabel.FromConfig(cfg)
+	if err != nil {
+		log.Errorf("Could not reload config, keeping the old one: %s", err)
+		return
+	}
+	starters, err := targetsFromConfig(cfg)
+	if err != nil {
+		log.Errorf("Could not reload config, keeping the old one: %s", err)
+		return
+	}
+	warnAboutStaticChanges(config.GetConfig(), cfg)
+	config.SetConfig(cfg)
+	r.resultQueues.SetRewriter(relabeler)
+
+	for target, running := range r.targets {
+		starter, found := starters[target]
+		if !found {
+			//Remove the queue first, so the collectors stop filling it
+			r.resultQueues.Delete(target)
+			removeTarget(target, running)
+			config.DeleteValue(target)
+			delete(r.targets, target)
+		} else if starter.fingerprint != running.fingerprint {
+			stopTarget(target, running)
+			r.targets[target] = startTarget(target, starter, r.resultQueues, r.queues, running.wal)
+		}
+	}
+	for _, target := range sortedTargets(starters) {
+		if _, found := r.targets[target]; !found {
+			r.targets[target] = startTarget(target, starters[target], r.resultQueues, r.queues, nil)
+		}
+	}
+
+	for name, running := range r.gearman {
+		if fingerprint, found := gearmanFingerprints[name]; !found || fingerprint != running.fingerprint {
+			log.Infof("Stopping Mod_Gearman: %s", name)
+			stopAll(running.workers)
+			delete(r.gearman, name)
+		}
+	}
+	for _, name := range sortedKeys(gearmanFingerprints) {
+		if _, found := r.gearman[name]; !found {
+			r.gearman[name] = &runningGearman{
+				fingerprint: gearmanFingerprints[name],
+				workers:     startGearman(name, cfg, r.resultQueues, r.livestatusCache, r.unitNormalizer, r.rateCalculator),
+			}
+		}
+	}
+	log.Info("Reload finished")
+}
+
+//Stop stops all ModGearman workers and targets.
+func (r *reloader) Stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, running := range r.gearman {
+		stopAll(running.workers)
+	}
+	for target, running := range r.targets {
+		removeTarget(target, running)
+	}
+}
+
+//warnAboutStaticChanges logs the sections which are only applied on a restart.
+func warnAboutStaticChanges(oldCfg, newCfg config.Config) {
+	static := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"Main", oldCfg.Main, newCfg.Main},
+		{"Log", oldCfg.Log, newCfg.Log},
+		{"Monitoring", oldCfg.Monitoring, newCfg.Monitoring},
+		{"Livestatus", livestatusFromConfig(oldCfg), livestatusFromConfig(newCfg)},
+		{"Icinga2", oldCfg.Icinga2, newCfg.Icinga2},
+		{"API", oldCfg.API, newCfg.API},
+		{"UnitNormalization", oldCfg.UnitNormalization, newCfg.UnitNormalization},
+		{"CounterRate", oldCfg.CounterRate, newCfg.CounterRate},
+	}
+	for _, section := range static {
+		if fmt.Sprintf("%+v", section.old) != fmt.Sprintf("%+v", section.new) {
+			log.Warnf("The section [%s] changed, the collectors will use it after a restart", section.name)
+		}
+	}
+}
+
+func sortedKeys(input map[string]string) []string {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
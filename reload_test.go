@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/logging"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+const reloadConfig = `[main]
+    BufferSize = 10
+[JSONFileExport "keep"]
+    Enabled = true
+    Path = "%s"
+[JSONFileExport "change"]
+    Enabled = true
+    Path = "%s"
+[JSONFileExport "remove"]
+    Enabled = %t
+    Path = "%s"
+[JSONFileExport "add"]
+    Enabled = %t
+    Path = "%s"
+`
+
+func TestReloaderReload(t *testing.T) {
+	logging.InitTestLogger()
+	log = logging.GetLogger()
+	dir, err := ioutil.TempDir("", "nagflux-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configPath := path.Join(dir, "config.gcfg")
+	if err := ioutil.WriteFile(configPath, []byte(fmt.Sprintf(reloadConfig, dir, dir, true, dir, false, dir)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.InitConfig(configPath)
+	resultQueues := collector.NewResultQueues()
+	starters, err := targetsFromConfig(config.GetConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reload := &reloader{
+		configPath: configPath, resultQueues: resultQueues, queues: queueConfig{bufferSize: 10},
+		targets: startTargets(starters, resultQueues, queueConfig{bufferSize: 10}), gearman: map[string]*runningGearman{},
+	}
+	defer reload.Stop()
+
+	keep := data.Target{Name: "keep", Datatype: data.JSONFile}
+	change := data.Target{Name: "change", Datatype: data.JSONFile}
+	remove := data.Target{Name: "remove", Datatype: data.JSONFile}
+	add := data.Target{Name: "add", Datatype: data.JSONFile}
+	keepQueue, _ := resultQueues.Get(keep)
+	changeQueue, _ := resultQueues.Get(change)
+	keepRunning := reload.targets[keep]
+	changeRunning := reload.targets[change]
+
+	changedDir := path.Join(dir, "changed")
+	if err := ioutil.WriteFile(configPath, []byte(fmt.Sprintf(reloadConfig, dir, changedDir, false, dir, true, dir)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reload.reload()
+
+	if queue, _ := resultQueues.Get(keep); queue != keepQueue || reload.targets[keep] != keepRunning {
+		t.Error("The unchanged target should not be touched")
+	}
+	if queue, _ := resultQueues.Get(change); queue != changeQueue || reload.targets[change] == changeRunning {
+		t.Error("The changed target should be restarted with the same queue")
+	}
+	if _, found := resultQueues.Get(remove); found || reload.targets[remove] != nil {
+		t.Error("The removed target should be stopped")
+	}
+	if _, found := resultQueues.Get(add); !found || reload.targets[add] == nil {
+		t.Error("The added target should be started")
+	}
+
+	//An invalid file keeps the running config
+	for _, invalid := range []string{"[broken", fmt.Sprintf("[JSONFileExport \"keep\"]\nEnabled = true\nPath = \"%s\"\nHostRegex = \"(\"", dir)} {
+		if err := ioutil.WriteFile(configPath, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		reload.reload()
+		if len(reload.targets) != 3 || len(resultQueues.All()) != 3 {
+			t.Errorf("An invalid config should not change the targets: %v", reload.targets)
+		}
+	}
+}
+
+//downTarget reads from its queue and passes everything to received, it acknowledges only if it is up.
+type downTarget struct {
+	quit chan bool
+}
+
+func (target downTarget) Stop() {
+	target.quit <- true
+	<-target.quit
+}
+
+func startDownTarget(up bool, received chan collector.Printable) func(chan collector.Printable, bool) []Stoppable {
+	return func(queue chan collector.Printable, persistentQueue bool) []Stoppable {
+		target := downTarget{quit: make(chan bool)}
+		go func() {
+			for {
+				select {
+				case <-target.quit:
+					target.quit <- true
+					return
+				case p := <-queue:
+					if up {
+						p.Acknowledge()
+					}
+					received <- p
+				}
+			}
+		}()
+		return []Stoppable{target}
+	}
+}
+
+func TestReloaderRestartWithWAL(t *testing.T) {
+	logging.InitTestLogger()
+	log = logging.GetLogger()
+	dir, err := ioutil.TempDir("", "nagflux-restart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	resultQueues := collector.NewResultQueues()
+	queues := queueConfig{bufferSize: 10, walFolder: dir, walSegmentSize: 1024, walMaxSize: 0}
+	target := data.Target{Name: "down", Datatype: data.InfluxDB}
+	received := make(chan collector.Printable, 10)
+
+	running := startTarget(target, targetStarter{start: startDownTarget(false, received)}, resultQueues, queues, nil)
+	defer running.wal.Stop()
+	in, _ := resultQueues.Get(target)
+	for i := 0; i < 3; i++ {
+		in <- collector.SimplePrintable{Filterable: collector.AllFilterable, Text: fmt.Sprint(i), Datatype: data.InfluxDB}
+	}
+	for i := 0; i < 3; i++ {
+		<-received
+	}
+	stopTarget(target, running)
+
+	running = startTarget(target, targetStarter{start: startDownTarget(true, received)}, resultQueues, queues, running.wal)
+	defer stopTarget(target, running)
+	for i := 0; i < 3; i++ {
+		select {
+		case p := <-received:
+			if text := p.(collector.SimplePrintable).Text; text != fmt.Sprint(i) {
+				t.Errorf("Expected: %d, actual: %s", i, text)
+			}
+		case <-time.After(time.Duration(2) * time.Second):
+			t.Fatal("The unacknowledged records should be passed to the restarted target")
+		}
+	}
+}
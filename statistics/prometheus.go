@@ -21,6 +21,7 @@ type PrometheusServer struct {
 	SpoolFilesLines          prometheus.Counter
 	BytesSend                *prometheus.CounterVec
 	SendDuration             *prometheus.CounterVec
+	LivestatusQueryDuration  *prometheus.HistogramVec
 }
 
 var server PrometheusServer
@@ -92,11 +93,19 @@ func initServerConfig() PrometheusServer {
 			Help:      "Time per package to sent to database",
 		}, []string{"type"})
 	prometheus.MustRegister(SendDuration)
+	LivestatusQueryDuration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "nagflux",
+			Subsystem: "livestatus",
+			Name:      "query_duration_seconds",
+			Help:      "Time per livestatus query",
+		}, []string{"address"})
+	prometheus.MustRegister(LivestatusQueryDuration)
 
 	return PrometheusServer{bufferLength: bufferLength, SpoolFilesOnDisk: spoolFilesOnDisk,
 		SpoolFilesInQueue: SpoolFilesInQueue, SpoolFilesParsedDuration: SpoolFilesParsedDuration,
 		SpoolFilesLines: SpoolFilesParsedSize, SpoolFilesParsed: SpoolFilesParsed,
-		BytesSend: BytesSend, SendDuration: SendDuration}
+		BytesSend: BytesSend, SendDuration: SendDuration, LivestatusQueryDuration: LivestatusQueryDuration}
 }
 
 //NewPrometheusServer creates a new PrometheusServer
@@ -125,7 +134,7 @@ func GetPrometheusServer() PrometheusServer {
 func (s PrometheusServer) WatchResultQueueLength(channels collector.ResultQueues) {
 	go func() {
 		for {
-			for k, c := range channels {
+			for k, c := range channels.All() {
 				s.bufferLength.WithLabelValues(fmt.Sprint(k)).Set(float64(len(c)))
 			}
 			time.Sleep(time.Duration(100 * time.Millisecond))
@@ -0,0 +1,251 @@
+package batch
+
+import (
+	"errors"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/collector/nagflux"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/griesbacher/nagflux/statistics"
+	"github.com/kdar/factorlog"
+	"os"
+	"sync"
+	"time"
+)
+
+//Request is a single request to a target, its Dump is written to the dumpfile if it could not be sent.
+type Request struct {
+	//Path is set by Senders, which send the data to more than one endpoint.
+	Path string
+	Data []byte
+	//Dump returns the lines which are written to a dumpfile, the DumpfileCollector replays them.
+	Dump func() []byte
+	//Split returns a request for every item, to find the bad ones if the target rejected the whole request. Can be nil.
+	Split func() []Request
+}
+
+//Sender encodes and sends the data of a target.
+type Sender interface {
+	//Requests converts the printables into requests, unsupported ones are skipped.
+	Requests(queries []collector.Printable) []Request
+	//Send sends the request and returns ErrorBadRequest if the data will not get better by sending it again.
+	Send(request Request, log bool) error
+}
+
+//Worker reads data from the queue and passes them in batches to the Sender, what could not be sent is dumped.
+//If the jobs come from a wal.Queue, the data is handled as described there.
+type Worker struct {
+	name            string
+	quit            chan bool
+	quitInternal    chan bool
+	jobs            chan collector.Printable
+	dumpFile        string
+	log             *factorlog.FactorLog
+	sender          Sender
+	IsRunning       bool
+	promServer      statistics.PrometheusServer
+	target          data.Target
+	persistentQueue bool
+}
+
+const dataTimeout = time.Duration(5) * time.Second
+
+var errorInterrupted = errors.New("Got interrupted")
+
+//ErrorBadRequest is returned by a Sender if the target rejected the data.
+var ErrorBadRequest = errors.New("400 Bad Request")
+
+//ErrorHTTPClient is returned by a Sender if the request could not be made.
+var ErrorHTTPClient = errors.New("Http Client got an error")
+
+//ErrorFailedToSend is returned by a Sender if the data should be sent again.
+var ErrorFailedToSend = errors.New("Could not send data")
+
+var mutex = &sync.Mutex{}
+
+//NewWorker creates a new Worker and starts it, the name is used within the logs and the statistics.
+func NewWorker(name string, jobs chan collector.Printable, dumpFile string, sender Sender, target data.Target, persistentQueue bool) *Worker {
+	worker := &Worker{
+		name: name, quit: make(chan bool), quitInternal: make(chan bool, 1), jobs: jobs,
+		dumpFile: nagflux.GenDumpfileName(dumpFile, target), log: logging.GetLogger(), sender: sender,
+		IsRunning: true, promServer: statistics.GetPrometheusServer(), target: target, persistentQueue: persistentQueue,
+	}
+	go worker.run()
+	return worker
+}
+
+//Stop stops the worker
+func (worker *Worker) Stop() {
+	worker.quitInternal <- true
+	worker.quit <- true
+	<-worker.quit
+	worker.IsRunning = false
+	worker.log.Debug(worker.name + "Worker(" + worker.target.Name + ") stopped")
+}
+
+//Tries to send data all the time.
+func (worker Worker) run() {
+	var queries []collector.Printable
+	var query collector.Printable
+	for {
+		select {
+		case <-worker.quit:
+			worker.log.Debug(worker.name + "Worker(" + worker.target.Name + ") quitting...")
+			worker.sendBuffer(queries)
+			worker.quit <- true
+			return
+		case query = <-worker.jobs:
+			if query.TestTargetFilter(worker.target.Name) {
+				queries = append(queries, query)
+				if len(queries) == 500 {
+					worker.sendBuffer(queries)
+					queries = queries[:0]
+				}
+			}
+		case <-time.After(dataTimeout):
+			worker.sendBuffer(queries)
+			queries = queries[:0]
+		}
+	}
+}
+
+//Sends the requests of the given queries, which are acknowledged after every request has been sent or dumped.
+func (worker Worker) sendBuffer(queries []collector.Printable) {
+	if len(queries) == 0 {
+		return
+	}
+	done := true
+	requests := worker.sender.Requests(queries)
+	for i, request := range requests {
+		sent, err := worker.sendRequest(request)
+		if err != nil {
+			//It's time to terminate, the queries are done if they could be dumped
+			if !worker.persistentQueue && worker.dumpRemaining(requests[i:]) == nil {
+				collector.AcknowledgeAll(queries)
+			}
+			return
+		}
+		done = sent && done
+	}
+	if done {
+		collector.AcknowledgeAll(queries)
+	}
+}
+
+//Sends a single request, it is dumped if this is not possible. Returns true if it was sent or dumped.
+func (worker Worker) sendRequest(request Request) (bool, error) {
+	done := true
+	startTime := time.Now()
+	sendErr := worker.sender.Send(request, true)
+	for i := 0; sendErr != nil && (i < 2 || worker.persistentQueue); i++ {
+		if sendErr == ErrorBadRequest {
+			done = worker.dumpErrorRequest(request) == nil
+			sendErr = nil
+			break
+		}
+		if err := worker.waitForQuitOrGoOn(); err != nil {
+			return false, err
+		}
+		//Resend Data
+		sendErr = worker.sender.Send(request, false)
+	}
+	if sendErr != nil {
+		//if there is still an error dump the request and go on
+		worker.log.Infof("Dumping data which couldn't be sent to: %s", worker.dumpFile)
+		done = worker.appendToFile(worker.dumpFile, request.Dump()) == nil
+	}
+	worker.promServer.BytesSend.WithLabelValues(worker.name).Add(float64(len(request.Data)))
+	timeDiff := float64(time.Since(startTime).Seconds() * 1000)
+	if timeDiff >= 0 {
+		worker.promServer.SendDuration.WithLabelValues(worker.name).Add(timeDiff)
+	}
+	return done, nil
+}
+
+//Waits on an internal quit signal.
+func (worker Worker) waitForQuitOrGoOn() error {
+	select {
+	//Got stop signal
+	case <-worker.quitInternal:
+		worker.log.Debug("Received quit")
+		worker.quitInternal <- true
+		return errorInterrupted
+	//Timeout and retry
+	case <-time.After(time.Duration(10) * time.Second):
+		return nil
+	}
+}
+
+//Writes the rejected request to a dumpfile. If it can be split, the parts are sent one by one and just the bad ones are dumped.
+func (worker Worker) dumpErrorRequest(request Request) error {
+	badRequests := []Request{request}
+	if request.Split != nil {
+		badRequests = nil
+		for _, part := range request.Split() {
+			if worker.sender.Send(part, false) != nil {
+				badRequests = append(badRequests, part)
+			}
+		}
+		if len(badRequests) == 0 {
+			return nil
+		}
+	}
+	errorFile := worker.dumpFile + "-errors"
+	worker.log.Warnf("Dumping data with errors to: %s", errorFile)
+	content := []byte("\n\nThe target rejected this data..\n")
+	for _, bad := range badRequests {
+		content = append(content, bad.Dump()...)
+	}
+	return worker.appendToFile(errorFile, content)
+}
+
+//Dumps the remaining requests and the global queue if a quit signal arises, the queue is acknowledged after the dump.
+func (worker Worker) dumpRemaining(remainingRequests []Request) error {
+	worker.log.Debugf("Global queue %d own queue %d", len(worker.jobs), len(remainingRequests))
+	var printables, queries []collector.Printable
+	stop := false
+	for !stop {
+		select {
+		case query := <-worker.jobs:
+			printables = append(printables, query)
+			if query.TestTargetFilter(worker.target.Name) {
+				queries = append(queries, query)
+			}
+		case <-time.After(time.Duration(200) * time.Millisecond):
+			stop = true
+		}
+	}
+	if len(queries) > 0 {
+		remainingRequests = append(remainingRequests, worker.sender.Requests(queries)...)
+	}
+	var content []byte
+	for _, request := range remainingRequests {
+		content = append(content, request.Dump()...)
+	}
+	worker.log.Debugf("dumping %d requests", len(remainingRequests))
+	if err := worker.appendToFile(worker.dumpFile, content); err != nil {
+		return err
+	}
+	collector.AcknowledgeAll(printables)
+	return nil
+}
+
+//appendToFile writes the content to the end of the file, the error is logged and returned.
+func (worker Worker) appendToFile(filename string, content []byte) error {
+	if len(content) == 0 {
+		return nil
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		worker.log.Critical(err)
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Write(content); err != nil {
+		worker.log.Critical(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package batch
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/griesbacher/nagflux/statistics"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var target = data.Target{Name: "test", Datatype: data.Graphite}
+
+func init() {
+	//The workers count the sent bytes
+	statistics.NewPrometheusServer("")
+}
+
+//testSender sends every text as a line, the rejected ones cause a bad request.
+type testSender struct {
+	mutex    sync.Mutex
+	down     bool
+	rejected map[string]bool
+	sent     []string
+}
+
+func (s *testSender) Requests(queries []collector.Printable) []Request {
+	var lines []string
+	for _, query := range queries {
+		lines = append(lines, query.(collector.SimplePrintable).Text+"\n")
+	}
+	return []Request{s.request(lines)}
+}
+
+func (s *testSender) request(lines []string) Request {
+	return Request{
+		Data: []byte(strings.Join(lines, "")),
+		Dump: func() []byte { return []byte(strings.Join(lines, "")) },
+		Split: func() []Request {
+			var result []Request
+			for _, line := range lines {
+				result = append(result, s.request([]string{line}))
+			}
+			return result
+		},
+	}
+}
+
+func (s *testSender) Send(request Request, log bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.down {
+		return ErrorFailedToSend
+	}
+	for _, line := range strings.SplitAfter(string(request.Data), "\n") {
+		if s.rejected[line] {
+			return ErrorBadRequest
+		}
+	}
+	s.sent = append(s.sent, string(request.Data))
+	return nil
+}
+
+type counter struct {
+	mutex sync.Mutex
+	count int
+}
+
+func (c *counter) Acknowledge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.count++
+}
+
+func (c *counter) value() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.count
+}
+
+func testPrintable(text string, ack *counter) collector.Printable {
+	return collector.SimplePrintable{Filterable: collector.Filterable{Filter: collector.All, Ack: ack}, Text: text, Datatype: data.Graphite}
+}
+
+func readFile(t *testing.T, filename string) string {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestWorker_BadRequest(t *testing.T) {
+	logging.InitTestLogger()
+	dir, err := ioutil.TempDir("", "nagflux-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sender := &testSender{rejected: map[string]bool{"b\n": true}}
+	jobs := make(chan collector.Printable)
+	worker := NewWorker("Test", jobs, path.Join(dir, "dump"), sender, target, false)
+	ack := &counter{}
+	jobs <- testPrintable("a", ack)
+	jobs <- testPrintable("b", ack)
+	jobs <- testPrintable("c", ack)
+	worker.Stop()
+
+	if expected := []string{"a\n", "c\n"}; !reflect.DeepEqual(sender.sent, expected) {
+		t.Errorf("expected: %q, actual: %q", expected, sender.sent)
+	}
+	if errors := readFile(t, worker.dumpFile+"-errors"); !strings.HasSuffix(errors, "..\nb\n") {
+		t.Errorf("Just the rejected line should be dumped, got: %q", errors)
+	}
+	if ack.value() != 3 {
+		t.Errorf("All queries should be acknowledged, got: %d", ack.value())
+	}
+}
+
+func TestWorker_DumpOnQuit(t *testing.T) {
+	logging.InitTestLogger()
+	dir, err := ioutil.TempDir("", "nagflux-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, persistentQueue := range []bool{false, true} {
+		jobs := make(chan collector.Printable)
+		worker := NewWorker("Test", jobs, path.Join(dir, "dump"), &testSender{down: true}, target, persistentQueue)
+		ack := &counter{}
+		jobs <- testPrintable("a", ack)
+		worker.Stop()
+
+		dump := readFile(t, worker.dumpFile)
+		os.Remove(worker.dumpFile)
+		if persistentQueue {
+			//The WAL replays the data
+			if dump != "" || ack.value() != 0 {
+				t.Errorf("With a WAL nothing should be dumped or acknowledged, got: %q, %d", dump, ack.value())
+			}
+		} else if dump != "a\n" || ack.value() != 1 {
+			t.Errorf("The query should be dumped and acknowledged, got: %q, %d", dump, ack.value())
+		}
+	}
+}
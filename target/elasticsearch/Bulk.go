@@ -0,0 +1,54 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"github.com/griesbacher/nagflux/helper"
+	"strconv"
+)
+
+//PointToBulk serializes a point as index action and document for the bulk API.
+//Tags and fields are flattened into the document, the measurement is used as type.
+func PointToBulk(p collector.Point, index string) (string, error) {
+	timestamp := strconv.FormatInt(p.TimestampMs(), 10)
+	head := fmt.Sprintf(`{"index":{"_index":"%s","_type":"%s"}}`, helper.GenIndex(index, timestamp), p.Measurement) + "\n"
+	document := map[string]interface{}{}
+	for k, v := range p.Tags {
+		document[k] = v
+	}
+	//messages used the Elasticsearch alias for hostchecks before the points existed
+	if alias := config.GetConfig().ElasticsearchGlobal.HostcheckAlias; alias != "" && p.Measurement == "messages" &&
+		p.Tags["service"] == config.GetConfig().InfluxDBGlobal.HostcheckAlias {
+		document["service"] = alias
+	}
+	for k, v := range p.Fields {
+		document[k] = v
+	}
+	document["timestamp"] = p.TimestampMs()
+
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(document); err != nil {
+		return "", err
+	}
+	return head + buffer.String(), nil
+}
+
+//PointsToBulk serializes all points of a printable, points which could not be encoded are skipped.
+func PointsToBulk(points []collector.Point, index string) (string, error) {
+	result := ""
+	var lastErr error
+	for _, p := range points {
+		bulk, err := PointToBulk(p, index)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result += bulk
+	}
+	return result, lastErr
+}
@@ -0,0 +1,34 @@
+package elasticsearch
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"testing"
+	"time"
+)
+
+func TestPointToBulk(t *testing.T) {
+	config.InitConfigFromString(`[InfluxDBGlobal]
+    HostcheckAlias = "hostcheck"
+[ElasticsearchGlobal]
+    HostcheckAlias = "host"
+    IndexRotation = "monthly"
+`)
+	message := collector.Point{Measurement: "messages", Tags: map[string]string{"host": "host 1", "service": "hostcheck", "type": "comment"},
+		Fields: map[string]interface{}{"message": `WARN:<br> "hi"`}, Timestamp: 1458988932, Precision: time.Second}
+	expected := `{"index":{"_index":"index-2016.03","_type":"messages"}}
+{"host":"host 1","message":"WARN:<br> \"hi\"","service":"host","timestamp":1458988932000,"type":"comment"}
+`
+	if actual, err := PointToBulk(message, "index"); err != nil || actual != expected {
+		t.Errorf("expected: %s, actual: %s, err: %v", expected, actual, err)
+	}
+
+	metric := collector.Point{Measurement: "metrics", Tags: map[string]string{"host": "host 1", "service": "hostcheck"},
+		Fields: map[string]interface{}{"value": 1.5, "unknown": true}, Timestamp: 1458988932000, Precision: time.Millisecond}
+	expected = `{"index":{"_index":"index-2016.03","_type":"metrics"}}
+{"host":"host 1","service":"hostcheck","timestamp":1458988932000,"unknown":true,"value":1.5}
+`
+	if actual, err := PointToBulk(metric, "index"); err != nil || actual != expected {
+		t.Errorf("expected: %s, actual: %s, err: %v", expected, actual, err)
+	}
+}
@@ -26,16 +26,18 @@ type Connector struct {
 	isAlive        bool
 	templateExists bool
 	httpClient     http.Client
+	//persistentQueue is true if the jobs come from a WAL, which replays everything that has not been acknowledged.
+	persistentQueue bool
 }
 
 //ConnectorFactory Constructor which will create some workers if the connection is established.
-func ConnectorFactory(jobs chan collector.Printable, connectionHost, index, dumpFile, version string, workerAmount, maxWorkers int, createDatabaseIfNotExists bool) *Connector {
+func ConnectorFactory(jobs chan collector.Printable, connectionHost, index, dumpFile, version string, workerAmount, maxWorkers int, createDatabaseIfNotExists, persistentQueue bool) *Connector {
 	if connectionHost[len(connectionHost)-1] != '/' {
 		connectionHost += "/"
 	}
 	s := &Connector{connectionHost, index, dumpFile, make([]*Worker, workerAmount), maxWorkers,
 		jobs, make(chan bool), logging.GetLogger(), version,
-		false, false, http.Client{Timeout: time.Duration(5 * time.Second)},
+		false, false, http.Client{Timeout: time.Duration(5 * time.Second)}, persistentQueue,
 	}
 
 	gen := WorkerGenerator(jobs, connectionHost+"_bulk", index, dumpFile, version, s)
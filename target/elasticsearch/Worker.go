@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
 	"github.com/griesbacher/nagflux/helper"
 	"github.com/griesbacher/nagflux/logging"
 	"github.com/griesbacher/nagflux/statistics"
@@ -126,6 +127,7 @@ func (worker Worker) sendBuffer(queries []collector.Printable) {
 	if len(queries) == 0 {
 		return
 	}
+	done := true
 
 	var lineQueries []string
 	for _, query := range queries {
@@ -143,7 +145,7 @@ func (worker Worker) sendBuffer(queries []collector.Printable) {
 	startTime := time.Now()
 	sendErr := worker.sendData([]byte(dataToSend), true)
 	if sendErr != nil {
-		for i := 0; i < 3; i++ {
+		for i := 0; i < 3 || (worker.connector.persistentQueue && sendErr != nil); i++ {
 			switch sendErr {
 			case errorBadRequest:
 				//Maybe just a few queries are wrong, so send them one by one and find the bad one
@@ -154,16 +156,18 @@ func (worker Worker) sendBuffer(queries []collector.Printable) {
 						badQueries = append(badQueries, lineQuery)
 					}
 				}
-				worker.dumpErrorQueries("\n\nOne of the values is not clean..\n", badQueries)
+				done = worker.dumpErrorQueries("\n\nOne of the values is not clean..\n", badQueries) == nil
 				sendErr = nil
 			case nil:
 				//Single point of exit
 				break
 			default:
 				if err := worker.waitForQuitOrGoOn(); err != nil {
-					//No error handling, because it's time to terminate
-					worker.dumpRemainingQueries(lineQueries)
-					sendErr = nil
+					//It's time to terminate, the queries are done if they could be dumped
+					if !worker.connector.persistentQueue && worker.dumpRemainingQueries(lineQueries) == nil {
+						collector.AcknowledgeAll(queries)
+					}
+					return
 				}
 				//Resend Data
 				sendErr = worker.sendData([]byte(dataToSend), true)
@@ -171,47 +175,58 @@ func (worker Worker) sendBuffer(queries []collector.Printable) {
 		}
 		if sendErr != nil {
 			//if there is still an error dump the queries and go on
-			worker.dumpErrorQueries("\n\n"+sendErr.Error()+"\n", lineQueries)
+			done = worker.dumpErrorQueries("\n\n"+sendErr.Error()+"\n", lineQueries) == nil
 		}
 
 	}
+	if done {
+		collector.AcknowledgeAll(queries)
+	}
 	worker.promServer.BytesSend.WithLabelValues("Elasticsearch").Add(float64(len(lineQueries)))
 	worker.promServer.SendDuration.WithLabelValues("Elasticsearch").Add(float64(time.Since(startTime).Seconds() * 1000))
 }
 
 //Writes the bad queries to a dumpfile.
-func (worker Worker) dumpErrorQueries(messageForLog string, errorQueries []string) {
+func (worker Worker) dumpErrorQueries(messageForLog string, errorQueries []string) error {
 	errorFile := worker.dumpFile + "-errors"
 	worker.log.Warnf("Dumping queries with errors to: %s", errorFile)
 	errorQueries = append([]string{messageForLog}, errorQueries...)
-	worker.dumpQueries(errorFile, errorQueries)
+	return worker.dumpQueries(errorFile, errorQueries)
 }
 
 var mutex = &sync.Mutex{}
 
-//Dumps the remaining queries if a quit signal arises.
-func (worker Worker) dumpRemainingQueries(remainingQueries []string) {
+//Dumps the remaining queries if a quit signal arises, the ones of the queue are acknowledged after the dump.
+func (worker Worker) dumpRemainingQueries(remainingQueries []string) error {
 	mutex.Lock()
+	defer mutex.Unlock()
 	worker.log.Debugf("Global queue %d own queue %d", len(worker.jobs), len(remainingQueries))
-	if len(worker.jobs) != 0 || len(remainingQueries) != 0 {
-		worker.log.Debug("Saving queries to disk")
+	if len(worker.jobs) == 0 && len(remainingQueries) == 0 {
+		return nil
+	}
+	worker.log.Debug("Saving queries to disk")
 
-		remainingQueries = append(remainingQueries, worker.readQueriesFromQueue()...)
+	queued, printables := worker.readQueriesFromQueue()
+	remainingQueries = append(remainingQueries, queued...)
 
-		worker.log.Debugf("dumping %d queries", len(remainingQueries))
-		worker.dumpQueries(worker.dumpFile, remainingQueries)
+	worker.log.Debugf("dumping %d queries", len(remainingQueries))
+	if err := worker.dumpQueries(worker.dumpFile, remainingQueries); err != nil {
+		return err
 	}
-	mutex.Unlock()
+	collector.AcknowledgeAll(printables)
+	return nil
 }
 
-//Reads the queries from the global queue and returns them as string.
-func (worker Worker) readQueriesFromQueue() []string {
+//Reads the queries from the global queue and returns them as string, the printables are acknowledged after the dump.
+func (worker Worker) readQueriesFromQueue() ([]string, []collector.Printable) {
 	var queries []string
+	var printables []collector.Printable
 	var query collector.Printable
 	stop := false
 	for !stop {
 		select {
 		case query = <-worker.jobs:
+			printables = append(printables, query)
 			cast, err := worker.castJobToString(query)
 			if err == nil {
 				queries = append(queries, cast)
@@ -220,7 +235,7 @@ func (worker Worker) readQueriesFromQueue() []string {
 			stop = true
 		}
 	}
-	return queries
+	return queries, printables
 }
 
 //sends the raw data to influxdb and returns an err if given.
@@ -285,23 +300,21 @@ func (worker Worker) waitForQuitOrGoOn() error {
 	}
 }
 
-//Writes queries to a dumpfile.
-func (worker Worker) dumpQueries(filename string, queries []string) {
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		if _, err := os.Create(filename); err != nil {
-			worker.log.Critical(err)
-		}
-	}
-	if f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0600); err != nil {
+//Writes queries to a dumpfile, the error is logged and returned.
+func (worker Worker) dumpQueries(filename string, queries []string) error {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
 		worker.log.Critical(err)
-	} else {
-		defer f.Close()
-		for _, query := range queries {
-			if _, err = f.WriteString(query); err != nil {
-				worker.log.Critical(err)
-			}
+		return err
+	}
+	defer f.Close()
+	for _, query := range queries {
+		if _, err = f.WriteString(query); err != nil {
+			worker.log.Critical(err)
+			return err
 		}
 	}
+	return nil
 }
 
 //Converts an collector.Printable to a string.
@@ -310,7 +323,16 @@ func (worker Worker) castJobToString(job collector.Printable) (string, error) {
 	var err error
 
 	if helper.VersionOrdinal(worker.version) >= helper.VersionOrdinal("2.0") {
-		result = job.PrintForElasticsearch(worker.version, worker.index)
+		if simple, ok := job.(collector.SimplePrintable); ok {
+			if simple.Datatype == data.Elasticsearch {
+				result = simple.Text
+			}
+		} else {
+			var encodeErr error
+			if result, encodeErr = PointsToBulk(job.Points(), worker.index); encodeErr != nil {
+				worker.log.Warn(encodeErr)
+			}
+		}
 	} else {
 		worker.log.Fatalf("This elasticsearch version [%s] given in the config is not supported", worker.version)
 		err = errors.New("This elasticsearch version given in the config is not supported")
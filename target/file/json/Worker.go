@@ -1,6 +1,7 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/griesbacher/nagflux/collector"
@@ -21,17 +22,20 @@ type JSONFileWorker struct {
 	log              *factorlog.FactorLog
 	IsRunning        bool
 	quit             chan bool
+	persistentQueue  bool
 }
 
 //NewJSONFileWorker creates a new JSONFileWorker
-func NewJSONFileWorker(log *factorlog.FactorLog, rotation int, jobs chan collector.Printable, target data.Target, path string) *JSONFileWorker {
+func NewJSONFileWorker(log *factorlog.FactorLog, rotation int, jobs chan collector.Printable, target data.Target, path string,
+	persistentQueue bool) *JSONFileWorker {
 	w := &JSONFileWorker{
-		jobs:      jobs,
-		target:    target,
-		path:      path,
-		log:       log,
-		IsRunning: true,
-		quit:      make(chan bool, 2),
+		jobs:            jobs,
+		target:          target,
+		path:            path,
+		log:             log,
+		IsRunning:       true,
+		quit:            make(chan bool, 2),
+		persistentQueue: persistentQueue,
 	}
 	if _, err := os.Stat(path); err != nil {
 		err = os.Mkdir(path, os.ModeDir)
@@ -71,8 +75,10 @@ func (t JSONFileWorker) run() {
 		for t.IsRunning {
 			select {
 			case <-time.After(t.rotationDuration):
-				t.writeData(queries)
-				queries = queries[:0]
+				//with a WAL the data is kept until it has been written
+				if t.writeData(queries) || !t.persistentQueue {
+					queries = queries[:0]
+				}
 			}
 		}
 	}()
@@ -91,59 +97,59 @@ func (t JSONFileWorker) run() {
 	}
 }
 
-func (t JSONFileWorker) writeData(data []collector.Printable) {
-	if len(data) == 0 {
-		return
+//writeData writes the points into the file, it returns false if the file could not be written. The printables are
+//acknowledged after they have been written, points which can not be marshalled are skipped.
+func (t JSONFileWorker) writeData(data []collector.Printable) bool {
+	var points [][]byte
+	for _, d := range data {
+		for _, p := range d.Points() {
+			out, err := json.Marshal(p)
+			if err != nil {
+				t.log.Critical("JSON marshal err:", err)
+				continue
+			}
+			points = append(points, out)
+		}
+	}
+	if len(points) == 0 {
+		collector.AcknowledgeAll(data)
+		return true
 	}
 	filePath := t.getFilename()
 	if t.rotation {
 		if _, err := os.Stat(filePath); err == nil {
 			t.log.Debugf("JSON file(%s) already exists, waiting for an second", filePath)
 			time.Sleep(time.Duration(1) * time.Second)
-			t.writeData(data)
+			return t.writeData(data)
 		}
-		out, err := json.Marshal(data)
-		if err != nil {
-			t.log.Critical("JSON rotation marshal err:", err)
-			return
-		}
-		err = ioutil.WriteFile(filePath, out, 0644)
-		if err != nil {
+		out := append(append([]byte("["), bytes.Join(points, []byte(","))...), []byte("]")...)
+		if err := ioutil.WriteFile(filePath, out, 0644); err != nil {
 			t.log.Critical("JSON rotation write err:", err)
+			return false
 		}
 	} else {
-		dataToWrite := []byte("")
-		for _, d := range data {
-			out, err := json.Marshal(d)
-			if err != nil {
-				t.log.Critical("JSON no rotation marshal err:", err)
-				return
-			}
-			dataToWrite = append(dataToWrite, out...)
-			dataToWrite = append(dataToWrite, []byte("\n")...)
-		}
-
+		dataToWrite := append(bytes.Join(points, []byte("\n")), []byte("\n")...)
 		if _, err := os.Stat(filePath); err == nil {
-			if f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0600); err != nil {
+			f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0600)
+			if err != nil {
 				t.log.Critical(err)
-			} else {
-				_, err = f.Write(dataToWrite)
-				if err != nil {
-					t.log.Critical(err)
-				}
-				err = f.Close()
-				if err != nil {
-					t.log.Critical(err)
-
-				}
+				return false
+			}
+			_, err = f.Write(dataToWrite)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
 			}
-		} else {
-			err = ioutil.WriteFile(filePath, dataToWrite, 0644)
 			if err != nil {
-				t.log.Critical("JSON no rotation write err:", err)
+				t.log.Critical(err)
+				return false
 			}
+		} else if err := ioutil.WriteFile(filePath, dataToWrite, 0644); err != nil {
+			t.log.Critical("JSON no rotation write err:", err)
+			return false
 		}
 	}
+	collector.AcknowledgeAll(data)
+	return true
 }
 
 func (t JSONFileWorker) getFilename() string {
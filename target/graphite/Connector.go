@@ -0,0 +1,101 @@
+package graphite
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/helper"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/griesbacher/nagflux/target/batch"
+	"github.com/kdar/factorlog"
+	"time"
+)
+
+//DefaultTemplate is used if no template is configured.
+const DefaultTemplate = "nagios.{host}.{service}.{performanceLabel}.{field}"
+
+//Connector holds the connection pool to a carbon server and its workers.
+type Connector struct {
+	connectionHost string
+	template       string
+	dumpFile       string
+	workers        []*batch.Worker
+	maxWorkers     int
+	jobs           chan collector.Printable
+	quit           chan bool
+	log            *factorlog.FactorLog
+	pool           *helper.TCPPool
+	timeout        time.Duration
+	target         data.Target
+	//persistentQueue is true if the jobs come from a WAL, which replays everything that has not been acknowledged.
+	persistentQueue bool
+}
+
+//ConnectorFactory Constructor which will create some workers.
+func ConnectorFactory(jobs chan collector.Printable, connectionHost, template, dumpFile string,
+	workerAmount, maxWorkers, connections int, target data.Target, clientTimeout int, persistentQueue bool) *Connector {
+	if template == "" {
+		template = DefaultTemplate
+	}
+	timeout := time.Duration(clientTimeout) * time.Second
+	s := &Connector{
+		connectionHost: connectionHost, template: template, dumpFile: dumpFile,
+		workers: make([]*batch.Worker, workerAmount), maxWorkers: maxWorkers, jobs: jobs, quit: make(chan bool),
+		log: logging.GetLogger(), pool: helper.NewTCPPool("tcp", connectionHost, connections, timeout),
+		timeout: timeout, target: target, persistentQueue: persistentQueue,
+	}
+	gen := WorkerGenerator(jobs, dumpFile, s, target)
+	for w := 0; w < workerAmount; w++ {
+		s.workers[w] = gen(w)
+	}
+	go s.run()
+	return s
+}
+
+//AddWorker creates a new worker
+func (connector *Connector) AddWorker() {
+	oldLength := connector.AmountWorkers()
+	if oldLength < connector.maxWorkers {
+		gen := WorkerGenerator(connector.jobs, connector.dumpFile, connector, connector.target)
+		connector.workers = append(connector.workers, gen(oldLength+2))
+		connector.log.Infof("Starting Worker: %d -> %d", oldLength, connector.AmountWorkers())
+	}
+}
+
+//RemoveWorker stops a worker
+func (connector *Connector) RemoveWorker() {
+	oldLength := connector.AmountWorkers()
+	if oldLength > 1 {
+		lastWorkerIndex := oldLength - 1
+		connector.workers[lastWorkerIndex].Stop()
+		connector.workers = connector.workers[:lastWorkerIndex]
+		connector.log.Infof("Stopping Worker: %d -> %d", oldLength, connector.AmountWorkers())
+	}
+}
+
+//AmountWorkers current amount of workers.
+func (connector Connector) AmountWorkers() int {
+	return len(connector.workers)
+}
+
+//Stop the connector and its workers.
+func (connector *Connector) Stop() {
+	connector.quit <- true
+	<-connector.quit
+	connector.log.Debug("GraphiteConnectorFactory stopped")
+}
+
+//Waits just for the end.
+func (connector *Connector) run() {
+	<-connector.quit
+	for _, worker := range connector.workers {
+		go worker.Stop()
+	}
+	for len(connector.workers) > 0 {
+		for connector.workers[0].IsRunning {
+			time.Sleep(time.Duration(100) * time.Millisecond)
+		}
+		connector.workers = connector.workers[1:]
+	}
+	connector.pool.Close()
+	connector.quit <- true
+}
@@ -0,0 +1,104 @@
+package graphite
+
+import (
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/target/batch"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var invalidPathChars = regexp.MustCompile(`[^a-zA-Z0-9_\-]`)
+var placeholder = regexp.MustCompile(`\{[^{}]+\}`)
+
+//WorkerGenerator generates a new Worker and starts it.
+func WorkerGenerator(jobs chan collector.Printable, dumpFile string, connector *Connector, target data.Target) func(workerId int) *batch.Worker {
+	return func(workerId int) *batch.Worker {
+		return batch.NewWorker("Graphite", jobs, dumpFile, connector, target, connector.persistentQueue)
+	}
+}
+
+//Requests converts the queries into plaintext lines, which are sent together.
+func (connector *Connector) Requests(queries []collector.Printable) []batch.Request {
+	var lines []string
+	for _, query := range queries {
+		lines = append(lines, connector.castJobToLines(query)...)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	dataToSend := []byte(strings.Join(lines, ""))
+	return []batch.Request{{Data: dataToSend, Dump: func() []byte { return dataToSend }}}
+}
+
+//Send writes the request to the carbon server, which does not answer. So every error is worth a retry.
+func (connector *Connector) Send(request batch.Request, log bool) error {
+	err := connector.pool.Write(request.Data, connector.timeout)
+	if err != nil {
+		connector.log.Warnf("Graphite(%s): %s", connector.target.Name, err)
+	}
+	return err
+}
+
+//Converts an collector.Printable to plaintext lines, unsupported types are skipped.
+func (connector *Connector) castJobToLines(job collector.Printable) []string {
+	if p, ok := job.(collector.SimplePrintable); ok {
+		//Lines from a dumpfile
+		if p.Datatype == data.Graphite && p.Text != "" {
+			return []string{strings.TrimRight(p.Text, "\n") + "\n"}
+		}
+		return nil
+	}
+	var result []string
+	for _, point := range job.Points() {
+		result = append(result, PointToLines(point, connector.template)...)
+	}
+	return result
+}
+
+//PointToLines creates one plaintext line for every numeric field.
+//The template placeholders {measurement} and {field} are filled from the point, every other placeholder by the tag with this name.
+func PointToLines(p collector.Point, template string) []string {
+	numericFields := p.NumericFields()
+	fields := make([]string, 0, len(numericFields))
+	for field := range numericFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var result []string
+	for _, field := range fields {
+		path := placeholder.ReplaceAllStringFunc(template, func(match string) string {
+			switch name := match[1 : len(match)-1]; name {
+			case "measurement":
+				return SanitizePathElement(p.Measurement)
+			case "field":
+				return SanitizePathElement(field)
+			default:
+				return SanitizePathElement(p.Tags[name])
+			}
+		})
+		result = append(result, fmt.Sprintf("%s %s %d\n", cleanPath(path), strconv.FormatFloat(numericFields[field], 'f', -1, 64), p.TimestampMs()/1000))
+	}
+	return result
+}
+
+//SanitizePathElement replaces every char, which would break the metric path, with an underscore.
+func SanitizePathElement(input string) string {
+	input = strings.Trim(input, `'`)
+	return invalidPathChars.ReplaceAllString(input, "_")
+}
+
+//cleanPath removes empty elements, which are created by empty placeholders.
+func cleanPath(path string) string {
+	var elements []string
+	for _, element := range strings.Split(path, ".") {
+		if element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return strings.Join(elements, ".")
+}
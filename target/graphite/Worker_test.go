@@ -0,0 +1,68 @@
+package graphite
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPointToLines(t *testing.T) {
+	t.Parallel()
+	point := collector.Point{
+		Measurement: "metrics",
+		Tags:        map[string]string{"host": "web.example.com", "service": "Disk C:", "performanceLabel": "'used space'"},
+		Fields:      map[string]interface{}{"value": 44.5, "warn": int64(80), "unit": "%", "crit": 90.0},
+		Timestamp:   1458988932123, Precision: time.Millisecond,
+	}
+	expected := []string{
+		"nagios.web_example_com.Disk_C_.used_space.crit 90 1458988932\n",
+		"nagios.web_example_com.Disk_C_.used_space.value 44.5 1458988932\n",
+		"nagios.web_example_com.Disk_C_.used_space.warn 80 1458988932\n",
+	}
+	if actual := PointToLines(point, DefaultTemplate); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+
+	//hostchecks have no service, the empty element is removed
+	point.Tags["service"] = ""
+	point.Fields = map[string]interface{}{"value": 1.0}
+	expected = []string{"nagios.web_example_com.used_space.value 1 1458988932\n"}
+	if actual := PointToLines(point, DefaultTemplate); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+
+	expected = []string{"perf.metrics.web_example_com.value 1 1458988932\n"}
+	if actual := PointToLines(point, "perf.{measurement}.{host}.{unknown}.{field}"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+func TestSanitizePathElement(t *testing.T) {
+	t.Parallel()
+	for input, expected := range map[string]string{
+		"host-1":       "host-1",
+		"web.example":  "web_example",
+		"'used space'": "used_space",
+		"C:\\ %":       "C____",
+		"":             "",
+	} {
+		if actual := SanitizePathElement(input); actual != expected {
+			t.Errorf("SanitizePathElement(%q): expected: %q, actual: %q", input, expected, actual)
+		}
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	t.Parallel()
+	for input, expected := range map[string]string{
+		"a.b.c":    "a.b.c",
+		"a..c.":    "a.c",
+		".a...b..": "a.b",
+		"...":      "",
+	} {
+		if actual := cleanPath(input); actual != expected {
+			t.Errorf("cleanPath(%q): expected: %q, actual: %q", input, expected, actual)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package influx
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"github.com/kdar/factorlog"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -32,16 +34,36 @@ type Connector struct {
 	httpClient            http.Client
 	target                data.Target
 	stopReadingDataIfDown bool
+	org                   string
+	bucket                string
+	token                 string
+	retentionPeriod       time.Duration
+	//persistentQueue is true if the jobs come from a WAL, which replays everything that has not been acknowledged.
+	persistentQueue bool
 }
 
+//influxV2 is the first version which uses the /api/v2 endpoints.
+const influxV2 = "2.0"
+
 //ConnectorFactory Constructor which will create some workers if the connection is established.
 func ConnectorFactory(jobs chan collector.Printable, connectionHost, connectionArgs, dumpFile, version string,
-	workerAmount, maxWorkers int, createDatabaseIfNotExists, stopReadingDataIfDown bool, target data.Target, clientTimeout int) *Connector {
+	workerAmount, maxWorkers int, createDatabaseIfNotExists, stopReadingDataIfDown bool, target data.Target, clientTimeout int,
+	org, bucket, token, retentionPeriod string, persistentQueue bool) *Connector {
 	parsedArgs := helper.StringToMap(connectionArgs, "&", "=")
 	var databaseName string
 	if db, found_db := parsedArgs["db"]; found_db {
 		databaseName = db
 	}
+	if bucket != "" {
+		databaseName = bucket
+	}
+	var retention time.Duration
+	if retentionPeriod != "" {
+		var err error
+		if retention, err = time.ParseDuration(retentionPeriod); err != nil {
+			logging.GetLogger().Warnf("InfluxDB(%s) could not parse RetentionPeriod '%s', using infinite: %s", target.Name, retentionPeriod, err)
+		}
+	}
 	timeout := time.Duration(time.Duration(clientTimeout) * time.Second)
 	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
 	client := http.Client{Timeout: timeout, Transport: transport}
@@ -50,6 +72,7 @@ func ConnectorFactory(jobs chan collector.Printable, connectionHost, connectionA
 		workers: make([]*Worker, workerAmount), maxWorkers: maxWorkers, jobs: jobs, quit: make(chan bool),
 		log: logging.GetLogger(), version: version, isAlive: false, databaseExists: false, databaseName: databaseName,
 		httpClient: client, target: target, stopReadingDataIfDown: stopReadingDataIfDown,
+		org: org, bucket: bucket, token: token, retentionPeriod: retention, persistentQueue: persistentQueue,
 	}
 
 	loginData := ""
@@ -59,7 +82,7 @@ func ConnectorFactory(jobs chan collector.Printable, connectionHost, connectionA
 		}
 	}
 
-	gen := WorkerGenerator(jobs, connectionHost+"/write?"+connectionArgs, dumpFile, version, s, target, stopReadingDataIfDown)
+	gen := WorkerGenerator(jobs, s.writeURL(), dumpFile, version, s, target, stopReadingDataIfDown)
 	s.TestIfIsAlive(stopReadingDataIfDown)
 	if !s.isAlive && !stopReadingDataIfDown {
 		s.log.Warnf("InfluxDB server(%s) is down but starting anyway due to 'stopReadingDataIfDown' = %t", target.Name, stopReadingDataIfDown)
@@ -100,7 +123,7 @@ func (connector *Connector) AddWorker() {
 	oldLength := connector.AmountWorkers()
 	if oldLength < connector.maxWorkers {
 		gen := WorkerGenerator(
-			connector.jobs, connector.connectionHost+"/write?"+connector.connectionArgs,
+			connector.jobs, connector.writeURL(),
 			connector.dumpFile, connector.version, connector, connector.target, connector.stopReadingDataIfDown,
 		)
 		connector.workers = append(connector.workers, gen(oldLength+2))
@@ -119,6 +142,30 @@ func (connector *Connector) RemoveWorker() {
 	}
 }
 
+//IsV2 returns true if the target speaks the InfluxDB 2.x API.
+func (connector Connector) IsV2() bool {
+	return helper.VersionOrdinal(connector.version) >= helper.VersionOrdinal(influxV2)
+}
+
+//writeURL returns the URL the workers are posting the data to.
+func (connector Connector) writeURL() string {
+	if connector.IsV2() {
+		args := url.Values{}
+		args.Set("org", connector.org)
+		args.Set("bucket", connector.bucket)
+		args.Set("precision", "ms")
+		return connector.connectionHost + "/api/v2/write?" + args.Encode()
+	}
+	return connector.connectionHost + "/write?" + connector.connectionArgs
+}
+
+//setAuthorization adds the token to the request, if one is configured.
+func (connector Connector) setAuthorization(req *http.Request) {
+	if connector.token != "" {
+		req.Header.Set("Authorization", "Token "+connector.token)
+	}
+}
+
 //AmountWorkers current amount of workers.
 func (connector Connector) AmountWorkers() int {
 	return len(connector.workers)
@@ -167,7 +214,11 @@ func (connector *Connector) run() {
 
 //TestIfIsAlive test active if the database system is alive.
 func (connector *Connector) TestIfIsAlive(stopReadingDataIfDown bool) bool {
-	result := helper.RequestedReturnCodeIsOK(connector.httpClient, connector.connectionHost+"/ping", "GET")
+	healthURL := connector.connectionHost + "/ping"
+	if connector.IsV2() {
+		healthURL = connector.connectionHost + "/health"
+	}
+	result := helper.RequestedReturnCodeIsOK(connector.httpClient, healthURL, "GET")
 	connector.isAlive = result
 	connector.log.Infof("Is InfluxDB(%s) running: %t", connector.target.Name, result)
 	if stopReadingDataIfDown {
@@ -178,6 +229,9 @@ func (connector *Connector) TestIfIsAlive(stopReadingDataIfDown bool) bool {
 
 //TestDatabaseExists test active if the database exists.
 func (connector *Connector) TestDatabaseExists() bool {
+	if connector.IsV2() {
+		return connector.testBucketExists()
+	}
 	resp, err := connector.httpClient.Get(connector.connectionHost + "/query?q=show%20databases&" + connector.connectionArgs)
 	if err != nil {
 		return false
@@ -205,6 +259,9 @@ func (connector *Connector) TestDatabaseExists() bool {
 
 //CreateDatabase creates the database.
 func (connector *Connector) CreateDatabase(loginData string) bool {
+	if connector.IsV2() {
+		return connector.createBucket()
+	}
 	host := connector.connectionHost + "/query"
 	if loginData != "" {
 		host += "?" + loginData + "&"
@@ -220,3 +277,89 @@ func (connector *Connector) CreateDatabase(loginData string) bool {
 	return result
 
 }
+
+//testBucketExists test active if the bucket exists within the org.
+func (connector *Connector) testBucketExists() bool {
+	args := url.Values{}
+	args.Set("org", connector.org)
+	args.Set("name", connector.bucket)
+	var jsonResult BucketsResult
+	if err := connector.getV2JSON("/api/v2/buckets?"+args.Encode(), &jsonResult); err != nil {
+		connector.log.Warn(err)
+		connector.databaseExists = false
+		return false
+	}
+	for _, bucket := range jsonResult.Buckets {
+		if bucket.Name == connector.bucket {
+			connector.databaseExists = true
+			return true
+		}
+	}
+	connector.databaseExists = false
+	return false
+}
+
+//createBucket creates the bucket with the configured retention period.
+func (connector *Connector) createBucket() bool {
+	args := url.Values{}
+	args.Set("org", connector.org)
+	var orgs OrgsResult
+	if err := connector.getV2JSON("/api/v2/orgs?"+args.Encode(), &orgs); err != nil || len(orgs.Orgs) == 0 {
+		connector.log.Warnf("Could not find org(%s) to create bucket %s: %v", connector.org, connector.bucket, err)
+		return false
+	}
+	request := CreateBucketRequest{OrgID: orgs.Orgs[0].ID, Name: connector.bucket, RetentionRules: []RetentionRule{}}
+	if connector.retentionPeriod > 0 {
+		request.RetentionRules = append(request.RetentionRules, RetentionRule{
+			Type: "expire", EverySeconds: int64(connector.retentionPeriod.Seconds()),
+		})
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		connector.log.Warn(err)
+		return false
+	}
+	req, err := http.NewRequest("POST", connector.connectionHost+"/api/v2/buckets", bytes.NewBuffer(body))
+	if err != nil {
+		connector.log.Warn(err)
+		return false
+	}
+	req.Header.Set("User-Agent", "Nagflux")
+	req.Header.Set("Content-Type", "application/json")
+	connector.setAuthorization(req)
+	resp, err := connector.httpClient.Do(req)
+	if err != nil {
+		connector.log.Warn(err)
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		answer, _ := ioutil.ReadAll(resp.Body)
+		connector.log.Warnf("Could not create bucket: %s - %s %s", connector.bucket, resp.Status, string(answer))
+		return false
+	}
+	return true
+}
+
+//getV2JSON makes an authorized GET request to the 2.x API and parses the JSON result.
+func (connector Connector) getV2JSON(path string, result interface{}) error {
+	req, err := http.NewRequest("GET", connector.connectionHost+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Nagflux")
+	connector.setAuthorization(req)
+	resp, err := connector.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("InfluxDB(%s) returned %s: %s", connector.target.Name, resp.Status, string(body))
+	}
+	return json.Unmarshal(body, result)
+}
@@ -0,0 +1,173 @@
+package influx
+
+import (
+	"encoding/json"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/logging"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+//mockInfluxV2 answers /health, /api/v2/orgs and /api/v2/buckets like an InfluxDB 2.x, which knows the org "myorg".
+type mockInfluxV2 struct {
+	server  *httptest.Server
+	buckets []string
+	created []CreateBucketRequest
+}
+
+func newMockInfluxV2(t *testing.T, token string) *mockInfluxV2 {
+	mock := &mockInfluxV2{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"influxdb","status":"pass"}`))
+	})
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Token "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/api/v2/orgs", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		var result OrgsResult
+		if r.URL.Query().Get("org") == "myorg" {
+			json.Unmarshal([]byte(`{"orgs":[{"id":"0123","name":"myorg"}]}`), &result)
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+	mux.HandleFunc("/api/v2/buckets", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		switch r.Method {
+		case "GET":
+			var result BucketsResult
+			for _, bucket := range mock.buckets {
+				if bucket == r.URL.Query().Get("name") {
+					json.Unmarshal([]byte(`{"buckets":[{"id":"1","name":"`+bucket+`","orgID":"0123"}]}`), &result)
+				}
+			}
+			json.NewEncoder(w).Encode(result)
+		case "POST":
+			var request CreateBucketRequest
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &request); err != nil {
+				t.Error(err)
+			}
+			mock.created = append(mock.created, request)
+			mock.buckets = append(mock.buckets, request.Name)
+			w.WriteHeader(http.StatusCreated)
+		}
+	})
+	mock.server = httptest.NewServer(mux)
+	return mock
+}
+
+func newTestConnector(host, version, org, bucket, token string) *Connector {
+	return &Connector{connectionHost: host, connectionArgs: "db=nagflux&u=root&p=root", version: version, log: logging.GetLogger(),
+		httpClient: http.Client{Timeout: time.Duration(2) * time.Second}, target: data.Target{Name: "test", Datatype: data.InfluxDB},
+		databaseName: bucket, org: org, bucket: bucket, token: token}
+}
+
+func TestConnector_IsV2(t *testing.T) {
+	t.Parallel()
+	for version, expected := range map[string]bool{"0.13": false, "1.8": false, "2.0": true, "2.7.1": true} {
+		if actual := newTestConnector("", version, "", "", "").IsV2(); actual != expected {
+			t.Errorf("%s: expected: %t, actual: %t", version, expected, actual)
+		}
+	}
+}
+
+func TestConnector_TestIfIsAliveV2(t *testing.T) {
+	logging.InitTestLogger()
+	mock := newMockInfluxV2(t, "secret")
+	defer mock.server.Close()
+	if !newTestConnector(mock.server.URL, "2.0", "myorg", "nagflux", "secret").TestIfIsAlive(false) {
+		t.Error("A 2.x server should be alive if /health answers")
+	}
+	//1.x uses /ping, which the mock does not know
+	if newTestConnector(mock.server.URL, "1.8", "", "", "").TestIfIsAlive(false) {
+		t.Error("A 1.x server should be asked on /ping")
+	}
+}
+
+func TestConnector_WriteURL(t *testing.T) {
+	t.Parallel()
+	v1 := newTestConnector("http://127.0.0.1:8086", "1.8", "", "", "")
+	if expected := "http://127.0.0.1:8086/write?db=nagflux&u=root&p=root"; v1.writeURL() != expected {
+		t.Errorf("expected: %s, actual: %s", expected, v1.writeURL())
+	}
+	v2 := newTestConnector("http://127.0.0.1:8086", "2.0", "my org", "nag&flux", "secret")
+	parsed, err := url.Parse(v2.writeURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Path != "/api/v2/write" || parsed.Query().Get("org") != "my org" || parsed.Query().Get("bucket") != "nag&flux" ||
+		parsed.Query().Get("precision") != "ms" {
+		t.Errorf("Unexpected 2.x write url: %s", v2.writeURL())
+	}
+	req, _ := http.NewRequest("POST", v2.writeURL(), nil)
+	v2.setAuthorization(req)
+	if req.Header.Get("Authorization") != "Token secret" {
+		t.Errorf("Unexpected authorization header: %s", req.Header.Get("Authorization"))
+	}
+}
+
+func TestConnector_Bucket(t *testing.T) {
+	logging.InitTestLogger()
+	mock := newMockInfluxV2(t, "secret")
+	defer mock.server.Close()
+	connector := newTestConnector(mock.server.URL, "2.0", "myorg", "nagflux", "secret")
+	connector.retentionPeriod = time.Duration(48) * time.Hour
+
+	if connector.TestDatabaseExists() || connector.DatabaseExists() {
+		t.Error("The bucket should not exist yet")
+	}
+	if !connector.CreateDatabase("") {
+		t.Fatal("The bucket should be created")
+	}
+	if !connector.TestDatabaseExists() || !connector.DatabaseExists() {
+		t.Error("The bucket should exist")
+	}
+	expected := CreateBucketRequest{OrgID: "0123", Name: "nagflux", RetentionRules: []RetentionRule{{Type: "expire", EverySeconds: 172800}}}
+	if len(mock.created) != 1 || mock.created[0].OrgID != expected.OrgID || mock.created[0].Name != expected.Name ||
+		len(mock.created[0].RetentionRules) != 1 || mock.created[0].RetentionRules[0] != expected.RetentionRules[0] {
+		t.Errorf("expected: %+v, actual: %+v", expected, mock.created)
+	}
+
+	//unknown org
+	connector = newTestConnector(mock.server.URL, "2.0", "otherorg", "other", "secret")
+	if connector.CreateDatabase("") {
+		t.Error("A bucket of an unknown org should not be created")
+	}
+	//wrong token
+	connector = newTestConnector(mock.server.URL, "2.0", "myorg", "nagflux", "wrong")
+	if connector.TestDatabaseExists() {
+		t.Error("The bucket should not be found with a wrong token")
+	}
+}
+
+func TestCreateBucketRequest(t *testing.T) {
+	t.Parallel()
+	body, err := json.Marshal(CreateBucketRequest{OrgID: "0123", Name: "nagflux", RetentionRules: []RetentionRule{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"orgID":"0123","name":"nagflux","retentionRules":[]}`; string(body) != expected {
+		t.Errorf("expected: %s, actual: %s", expected, body)
+	}
+	var buckets BucketsResult
+	if err := json.Unmarshal([]byte(`{"buckets":[{"id":"1","name":"nagflux","orgID":"0123","type":"user"}]}`), &buckets); err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets.Buckets) != 1 || buckets.Buckets[0].Name != "nagflux" || buckets.Buckets[0].OrgID != "0123" {
+		t.Errorf("Unexpected buckets: %+v", buckets)
+	}
+}
@@ -0,0 +1,90 @@
+package influx
+
+import (
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+//PointToLine serializes a point in the influxdb lineformat with a millisecond timestamp, empty tags are omitted.
+//It returns an empty string if the point has no fields.
+func PointToLine(p collector.Point) string {
+	if len(p.Fields) == 0 {
+		return ""
+	}
+	line := measurementEscaper.Replace(replaceNastyString(p.Measurement))
+	for _, k := range sortedKeys(p.Tags) {
+		if p.Tags[k] == "" {
+			continue
+		}
+		line += "," + escapeKey(k) + "=" + escapeKey(p.Tags[k])
+	}
+	fields := []string{}
+	for _, k := range sortedFieldKeys(p.Fields) {
+		fields = append(fields, escapeKey(k)+"="+formatFieldValue(p.Fields[k]))
+	}
+	return fmt.Sprintf("%s %s %d\n", line, strings.Join(fields, ","), p.TimestampMs())
+}
+
+//PointsToLines serializes all points of a printable.
+func PointsToLines(points []collector.Point) string {
+	result := ""
+	for _, p := range points {
+		result += PointToLine(p)
+	}
+	return result
+}
+
+func formatFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10) + "i"
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return `"` + stringEscaper.Replace(v) + `"`
+	}
+	return `"` + stringEscaper.Replace(fmt.Sprint(value)) + `"`
+}
+
+func escapeKey(input string) string {
+	return keyEscaper.Replace(replaceNastyString(input))
+}
+
+//replaceNastyString avoids a bug in InfluxDB, see NastyString in the config.
+func replaceNastyString(input string) string {
+	global := config.GetConfig().InfluxDBGlobal
+	if global.NastyString == "" {
+		return input
+	}
+	return strings.Replace(input, global.NastyString, global.NastyStringToReplace, -1)
+}
+
+func sortedKeys(input map[string]string) []string {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedFieldKeys(input map[string]interface{}) []string {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
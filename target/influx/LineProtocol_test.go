@@ -0,0 +1,36 @@
+package influx
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/config"
+	"testing"
+	"time"
+)
+
+var PointToLineData = []struct {
+	input  collector.Point
+	output string
+}{
+	{collector.Point{Measurement: "messages", Tags: map[string]string{"host": "host 1", "service": "service 1", "type": "comment", "author": "philip"},
+		Fields: map[string]interface{}{"message": "hallo world"}, Timestamp: 1458988932, Precision: time.Second},
+		`messages,author=philip,host=host\ 1,service=service\ 1,type=comment message="hallo world" 1458988932000` + "\n"},
+	{collector.Point{Measurement: "metrics", Tags: map[string]string{"host": "a,b=c", "unit": ""},
+		Fields: map[string]interface{}{"value": 1.5, "warn": float64(10), "count": int64(3), "unknown": true}, Timestamp: 1000, Precision: time.Millisecond},
+		`metrics,host=a\,b\=c count=3i,unknown=true,value=1.5,warn=10 1000` + "\n"},
+	{collector.Point{Measurement: "messages", Tags: map[string]string{"host": "§"},
+		Fields: map[string]interface{}{"message": `say "hi" C:\`}, Timestamp: 1},
+		`messages,host=SS message="say \"hi\" C:\\" 1` + "\n"},
+	{collector.Point{Measurement: "empty", Timestamp: 1}, ""},
+}
+
+func TestPointToLine(t *testing.T) {
+	config.InitConfigFromString(`[InfluxDBGlobal]
+    NastyString = "§"
+    NastyStringToReplace = "SS"
+`)
+	for _, data := range PointToLineData {
+		if actual := PointToLine(data.input); actual != data.output {
+			t.Errorf("PointToLine(%v): expected: %s, actual: %s", data.input, data.output, actual)
+		}
+	}
+}
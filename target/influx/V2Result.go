@@ -0,0 +1,31 @@
+package influx
+
+//BucketsResult represents the JSON result of /api/v2/buckets
+type BucketsResult struct {
+	Buckets []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		OrgID string `json:"orgID"`
+	} `json:"buckets"`
+}
+
+//OrgsResult represents the JSON result of /api/v2/orgs
+type OrgsResult struct {
+	Orgs []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"orgs"`
+}
+
+//CreateBucketRequest is the JSON body to create a bucket
+type CreateBucketRequest struct {
+	OrgID          string          `json:"orgID"`
+	Name           string          `json:"name"`
+	RetentionRules []RetentionRule `json:"retentionRules"`
+}
+
+//RetentionRule defines how long the data is kept within a bucket, 0 means forever
+type RetentionRule struct {
+	Type         string `json:"type"`
+	EverySeconds int64  `json:"everySeconds"`
+}
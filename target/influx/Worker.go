@@ -123,6 +123,7 @@ func (worker Worker) sendBuffer(queries []collector.Printable) {
 	if len(queries) == 0 {
 		return
 	}
+	done := true
 
 	var lineQueries []string
 	for _, query := range queries {
@@ -142,7 +143,7 @@ func (worker Worker) sendBuffer(queries []collector.Printable) {
 	if sendErr != nil {
 		worker.connector.TestIfIsAlive(worker.stopReadingDataIfDown)
 		worker.connector.TestDatabaseExists()
-		for i := 0; i < 2; i++ {
+		for i := 0; i < 2 || (worker.connector.persistentQueue && sendErr != nil); i++ {
 			switch sendErr {
 			case errorBadRequest:
 				//Maybe just a few queries are wrong, so send them one by one and find the bad one
@@ -153,16 +154,18 @@ func (worker Worker) sendBuffer(queries []collector.Printable) {
 						badQueries = append(badQueries, lineQuery)
 					}
 				}
-				worker.dumpErrorQueries("\n\nOne of the values is not clean..\n", badQueries)
+				done = worker.dumpErrorQueries("\n\nOne of the values is not clean..\n", badQueries) == nil
 				sendErr = nil
 			case nil:
 				//Single point of exit
 				break
 			default:
 				if err := worker.waitForQuitOrGoOn(); err != nil {
-					//No error handling, because it's time to terminate
-					worker.dumpRemainingQueries(lineQueries)
-					sendErr = nil
+					//It's time to terminate, the queries are done if they could be dumped
+					if !worker.connector.persistentQueue && worker.dumpRemainingQueries(lineQueries) == nil {
+						collector.AcknowledgeAll(queries)
+					}
+					return
 				}
 				//Resend Data
 				sendErr = worker.sendData([]byte(dataToSend), false)
@@ -171,10 +174,13 @@ func (worker Worker) sendBuffer(queries []collector.Printable) {
 		if sendErr != nil {
 			//if there is still an error dump the queries and go on
 			worker.log.Infof("Dumping queries which couldn't be sent to: %s", worker.dumpFile)
-			worker.dumpQueries(worker.dumpFile, lineQueries)
+			done = worker.dumpQueries(worker.dumpFile, lineQueries) == nil
 		}
 
 	}
+	if done {
+		collector.AcknowledgeAll(queries)
+	}
 	worker.promServer.BytesSend.WithLabelValues("InfluxDB").Add(float64(len(lineQueries)))
 	timeDiff := float64(time.Since(startTime).Seconds() * 1000)
 	if timeDiff >= 0 {
@@ -183,14 +189,16 @@ func (worker Worker) sendBuffer(queries []collector.Printable) {
 
 }
 
-//Reads the queries from the global queue and returns them as string.
-func (worker Worker) readQueriesFromQueue() []string {
+//Reads the queries from the global queue and returns them as string, the printables are acknowledged after the dump.
+func (worker Worker) readQueriesFromQueue() ([]string, []collector.Printable) {
 	var queries []string
+	var printables []collector.Printable
 	var query collector.Printable
 	stop := false
 	for !stop {
 		select {
 		case query = <-worker.jobs:
+			printables = append(printables, query)
 			if query.TestTargetFilter(worker.target.Name) {
 				cast, err := worker.castJobToString(query)
 				if err == nil {
@@ -201,7 +209,7 @@ func (worker Worker) readQueriesFromQueue() []string {
 			stop = true
 		}
 	}
-	return queries
+	return queries, printables
 }
 
 //sends the raw data to influxdb and returns an err if given.
@@ -214,6 +222,7 @@ func (worker Worker) sendData(rawData []byte, log bool) error {
 		worker.log.Warn(err)
 	}
 	req.Header.Set("User-Agent", "Nagflux")
+	worker.connector.setAuthorization(req)
 	resp, err := worker.httpClient.Do(req)
 	if err != nil {
 		worker.log.Warn(err)
@@ -265,43 +274,47 @@ func (worker Worker) waitForQuitOrGoOn() error {
 }
 
 //Writes the bad queries to a dumpfile.
-func (worker Worker) dumpErrorQueries(messageForLog string, errorQueries []string) {
+func (worker Worker) dumpErrorQueries(messageForLog string, errorQueries []string) error {
 	errorFile := worker.dumpFile + "-errors"
 	worker.log.Warnf("Dumping queries with errors to: %s", errorFile)
 	errorQueries = append([]string{messageForLog}, errorQueries...)
-	worker.dumpQueries(errorFile, errorQueries)
+	return worker.dumpQueries(errorFile, errorQueries)
 }
 
-//Dumps the remaining queries if a quit signal arises.
-func (worker Worker) dumpRemainingQueries(remainingQueries []string) {
+//Dumps the remaining queries if a quit signal arises, the ones of the queue are acknowledged after the dump.
+func (worker Worker) dumpRemainingQueries(remainingQueries []string) error {
 	worker.log.Debugf("Global queue %d own queue %d", len(worker.jobs), len(remainingQueries))
-	if len(worker.jobs) != 0 || len(remainingQueries) != 0 {
-		worker.log.Debug("Saving queries to disk")
-		remainingQueries = append(remainingQueries, worker.readQueriesFromQueue()...)
-		worker.log.Debugf("dumping %d queries", len(remainingQueries))
-		worker.dumpQueries(worker.dumpFile, remainingQueries)
+	if len(worker.jobs) == 0 && len(remainingQueries) == 0 {
+		return nil
+	}
+	worker.log.Debug("Saving queries to disk")
+	queued, printables := worker.readQueriesFromQueue()
+	remainingQueries = append(remainingQueries, queued...)
+	worker.log.Debugf("dumping %d queries", len(remainingQueries))
+	if err := worker.dumpQueries(worker.dumpFile, remainingQueries); err != nil {
+		return err
 	}
+	collector.AcknowledgeAll(printables)
+	return nil
 }
 
-//Writes queries to a dumpfile.
-func (worker Worker) dumpQueries(filename string, queries []string) {
+//Writes queries to a dumpfile, the error is logged and returned.
+func (worker Worker) dumpQueries(filename string, queries []string) error {
 	mutex.Lock()
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		if _, err := os.Create(filename); err != nil {
-			worker.log.Critical(err)
-		}
-	}
-	if f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0600); err != nil {
+	defer mutex.Unlock()
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
 		worker.log.Critical(err)
-	} else {
-		defer f.Close()
-		for _, query := range queries {
-			if _, err = f.WriteString(query); err != nil {
-				worker.log.Critical(err)
-			}
+		return err
+	}
+	defer f.Close()
+	for _, query := range queries {
+		if _, err = f.WriteString(query); err != nil {
+			worker.log.Critical(err)
+			return err
 		}
 	}
-	mutex.Unlock()
+	return nil
 }
 
 //Converts an collector.Printable to a string.
@@ -310,7 +323,13 @@ func (worker Worker) castJobToString(job collector.Printable) (string, error) {
 	var err error
 
 	if helper.VersionOrdinal(worker.version) >= helper.VersionOrdinal("0.9") {
-		result = job.PrintForInfluxDB(worker.version)
+		if simple, ok := job.(collector.SimplePrintable); ok {
+			if simple.Datatype == data.InfluxDB {
+				result = simple.Text
+			}
+		} else {
+			result = PointsToLines(job.Points())
+		}
 	} else {
 		worker.log.Fatalf("This influxversion [%s] given in the config is not supported", worker.version)
 		err = errors.New("This influxversion given in the config is not supported")
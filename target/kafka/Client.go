@@ -0,0 +1,204 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Client is a minimal Kafka producer, which speaks Metadata v1 and Produce v3.
+type Client struct {
+	bootstrap     []string
+	clientID      string
+	timeout       time.Duration
+	acks          int16
+	correlationID int32
+	mutex         *sync.Mutex
+	connections   map[string]net.Conn
+	metadata      metadata
+	roundRobin    uint32
+}
+
+//NewClient creates a client, the connections are established on demand.
+func NewClient(bootstrap []string, clientID string, timeout time.Duration, acks int16) *Client {
+	return &Client{
+		bootstrap: bootstrap, clientID: clientID, timeout: timeout, acks: acks, mutex: &sync.Mutex{},
+		connections: map[string]net.Conn{},
+		metadata:    metadata{brokers: map[int32]string{}, topics: map[string][]partitionMetadata{}},
+	}
+}
+
+func joinHostPort(host string, port int32) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+//Produce sends the messages to the partition leaders, the partition is chosen by the key.
+func (client *Client) Produce(messages map[string][]Message) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	var topics []string
+	for topic := range messages {
+		if _, known := client.metadata.topics[topic]; !known {
+			topics = append(topics, topic)
+		}
+	}
+	if len(topics) > 0 {
+		if err := client.refreshMetadata(topics); err != nil {
+			return err
+		}
+	}
+
+	byLeader := map[int32][]produceBatch{}
+	for topic, topicMessages := range messages {
+		partitions := client.metadata.topics[topic]
+		if len(partitions) == 0 {
+			return fmt.Errorf("Kafka topic %s has no partitions, error code: %d", topic, client.metadata.errors[topic])
+		}
+		byPartition := map[int]*produceBatch{}
+		for _, message := range topicMessages {
+			index := client.choosePartition(message.Key, len(partitions))
+			if _, found := byPartition[index]; !found {
+				byPartition[index] = &produceBatch{topic: topic, partition: partitions[index].id}
+			}
+			byPartition[index].messages = append(byPartition[index].messages, message)
+		}
+		for index, batch := range byPartition {
+			leader := partitions[index].leader
+			byLeader[leader] = append(byLeader[leader], *batch)
+		}
+	}
+
+	for leader, batches := range byLeader {
+		address, found := client.metadata.brokers[leader]
+		if !found {
+			client.metadata.topics = map[string][]partitionMetadata{}
+			return fmt.Errorf("Kafka leader %d is unknown", leader)
+		}
+		response, err := client.roundTrip(address, apiKeyProduce, produceVersion,
+			encodeProduceRequest(client.acks, int32(client.timeout/time.Millisecond), batches))
+		if err != nil {
+			client.metadata.topics = map[string][]partitionMetadata{}
+			return err
+		}
+		if client.acks == 0 {
+			continue
+		}
+		errorCodes, err := decodeProduceResponse(response)
+		if err != nil {
+			return err
+		}
+		for topic, partitions := range errorCodes {
+			for partition, code := range partitions {
+				if code != 0 {
+					//e.g. NOT_LEADER_FOR_PARTITION, the next try will use fresh metadata
+					client.metadata.topics = map[string][]partitionMetadata{}
+					return fmt.Errorf("Kafka topic %s partition %d returned error code: %d", topic, partition, code)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//choosePartition hashes the key, messages without key are spread round robin.
+func (client *Client) choosePartition(key []byte, partitions int) int {
+	if key == nil {
+		client.roundRobin++
+		return int(client.roundRobin % uint32(partitions))
+	}
+	hash := fnv.New32a()
+	hash.Write(key)
+	return int(hash.Sum32() % uint32(partitions))
+}
+
+//refreshMetadata asks the known brokers for the leaders of the topics.
+func (client *Client) refreshMetadata(topics []string) error {
+	addresses := append([]string{}, client.bootstrap...)
+	for _, address := range client.metadata.brokers {
+		addresses = append(addresses, address)
+	}
+	err := errors.New("No Kafka broker configured")
+	for _, address := range addresses {
+		var response []byte
+		if response, err = client.roundTrip(address, apiKeyMetadata, metadataVersion, encodeMetadataRequest(topics)); err != nil {
+			continue
+		}
+		var result metadata
+		if result, err = decodeMetadataResponse(response); err != nil {
+			continue
+		}
+		for id, broker := range result.brokers {
+			client.metadata.brokers[id] = broker
+		}
+		for topic, partitions := range result.topics {
+			//Topics with an error or without partitions are not cached, so they are requested again on the next try
+			if result.errors[topic] == 0 && len(partitions) > 0 {
+				client.metadata.topics[topic] = partitions
+			} else {
+				delete(client.metadata.topics, topic)
+			}
+		}
+		client.metadata.errors = result.errors
+		return nil
+	}
+	return err
+}
+
+//roundTrip sends one request and reads the response, broken connections are closed.
+func (client *Client) roundTrip(address string, apiKey, apiVersion int16, body []byte) ([]byte, error) {
+	conn, found := client.connections[address]
+	if !found {
+		var err error
+		if conn, err = net.DialTimeout("tcp", address, client.timeout); err != nil {
+			return nil, err
+		}
+		client.connections[address] = conn
+	}
+	client.correlationID++
+	correlationID := client.correlationID
+	conn.SetDeadline(time.Now().Add(client.timeout * 2))
+	if _, err := conn.Write(encodeRequest(apiKey, apiVersion, correlationID, client.clientID, body)); err != nil {
+		client.closeConnection(address)
+		return nil, err
+	}
+	if apiKey == apiKeyProduce && client.acks == 0 {
+		return nil, nil
+	}
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(conn, size); err != nil {
+		client.closeConnection(address)
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint32(size))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		client.closeConnection(address)
+		return nil, err
+	}
+	if len(response) < 4 || int32(binary.BigEndian.Uint32(response)) != correlationID {
+		client.closeConnection(address)
+		return nil, errors.New("Kafka response has a wrong correlation id")
+	}
+	return response[4:], nil
+}
+
+func (client *Client) closeConnection(address string) {
+	if conn, found := client.connections[address]; found {
+		conn.Close()
+		delete(client.connections, address)
+	}
+}
+
+//Close closes all broker connections.
+func (client *Client) Close() {
+	client.mutex.Lock()
+	for address := range client.connections {
+		client.closeConnection(address)
+	}
+	client.mutex.Unlock()
+}
@@ -0,0 +1,236 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+//MockBroker is a local stand-in for a Kafka broker, which answers Metadata and Produce requests.
+type MockBroker struct {
+	listener   net.Listener
+	partitions int32
+	topicError int16
+	mutex      *sync.Mutex
+	received   map[string]map[int32][]Message
+}
+
+func NewMockBroker(t *testing.T, partitions int32) *MockBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := &MockBroker{listener: listener, partitions: partitions, mutex: &sync.Mutex{}, received: map[string]map[int32][]Message{}}
+	go broker.serve()
+	return broker
+}
+
+func (broker *MockBroker) Close() {
+	broker.listener.Close()
+}
+
+func (broker *MockBroker) serve() {
+	for {
+		conn, err := broker.listener.Accept()
+		if err != nil {
+			return
+		}
+		go broker.handle(conn)
+	}
+}
+
+func (broker *MockBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		size := make([]byte, 4)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return
+		}
+		request := make([]byte, binary.BigEndian.Uint32(size))
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+		d := &decoder{buf: request}
+		apiKey := d.int16()
+		d.int16()
+		correlationID := d.int32()
+		d.string()
+		response := &encoder{}
+		response.int32(0)
+		response.int32(correlationID)
+		switch apiKey {
+		case apiKeyMetadata:
+			host, port, _ := net.SplitHostPort(broker.listener.Addr().String())
+			portNumber, _ := strconv.Atoi(port)
+			response.int32(1)
+			response.int32(0)
+			response.string(host)
+			response.int32(int32(portNumber))
+			response.int16(-1)
+			response.int32(0)
+			topics := d.int32()
+			response.int32(topics)
+			broker.mutex.Lock()
+			topicError, partitions := broker.topicError, broker.partitions
+			broker.mutex.Unlock()
+			if topicError != 0 {
+				partitions = 0
+			}
+			for ; topics > 0; topics-- {
+				response.int16(topicError)
+				response.string(d.string())
+				response.int8(0)
+				response.int32(partitions)
+				for p := int32(0); p < partitions; p++ {
+					response.int16(0)
+					response.int32(p)
+					response.int32(0)
+					response.int32(0)
+					response.int32(0)
+				}
+			}
+		case apiKeyProduce:
+			d.string()
+			d.int16()
+			d.int32()
+			topics := d.int32()
+			response.int32(topics)
+			for ; topics > 0; topics-- {
+				topic := d.string()
+				response.string(topic)
+				partitions := d.int32()
+				response.int32(partitions)
+				for ; partitions > 0; partitions-- {
+					partition := d.int32()
+					messages, err := decodeRecordBatch(d.bytes())
+					errorCode := int16(0)
+					if err != nil {
+						errorCode = 2
+					}
+					broker.mutex.Lock()
+					if _, found := broker.received[topic]; !found {
+						broker.received[topic] = map[int32][]Message{}
+					}
+					broker.received[topic][partition] = append(broker.received[topic][partition], messages...)
+					broker.mutex.Unlock()
+					response.int32(partition)
+					response.int16(errorCode)
+					response.int64(0)
+					response.int64(-1)
+				}
+			}
+			response.int32(0)
+		default:
+			return
+		}
+		binary.BigEndian.PutUint32(response.buf, uint32(len(response.buf)-4))
+		if _, err := conn.Write(response.buf); err != nil {
+			return
+		}
+	}
+}
+
+func TestRecordBatchRoundTrip(t *testing.T) {
+	messages := []Message{
+		{Key: []byte("h1"), Value: []byte(`{"a":1}`), Timestamp: 1000},
+		{Key: nil, Value: []byte("metrics value=1 1500"), Timestamp: 1500},
+	}
+	decoded, err := decodeRecordBatch(encodeRecordBatch(messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(messages) {
+		t.Fatalf("Expected %d messages, got %d", len(messages), len(decoded))
+	}
+	for i := range messages {
+		if string(decoded[i].Key) != string(messages[i].Key) || string(decoded[i].Value) != string(messages[i].Value) ||
+			decoded[i].Timestamp != messages[i].Timestamp {
+			t.Errorf("Message %d differs: %v != %v", i, decoded[i], messages[i])
+		}
+	}
+	if decoded[1].Key != nil {
+		t.Error("A nil key should stay nil")
+	}
+}
+
+func TestClientProduce(t *testing.T) {
+	broker := NewMockBroker(t, 3)
+	defer broker.Close()
+	client := NewClient([]string{broker.listener.Addr().String()}, "nagflux", time.Duration(2)*time.Second, 1)
+	defer client.Close()
+
+	messages := map[string][]Message{
+		"metrics":  {{Key: []byte("h1"), Value: []byte("1")}, {Key: []byte("h2"), Value: []byte("2")}, {Key: []byte("h1"), Value: []byte("3")}},
+		"messages": {{Key: []byte("h1"), Value: []byte("4")}},
+	}
+	if err := client.Produce(messages); err != nil {
+		t.Fatal(err)
+	}
+
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	total := 0
+	for topic, partitions := range broker.received {
+		for partition, received := range partitions {
+			total += len(received)
+			for _, message := range received {
+				if expected := client.choosePartition(message.Key, 3); int32(expected) != partition {
+					t.Errorf("Message with key %s of topic %s should be in partition %d, not %d", message.Key, topic, expected, partition)
+				}
+			}
+		}
+	}
+	if total != 4 {
+		t.Errorf("Expected 4 messages, the broker received %d", total)
+	}
+}
+
+func TestClientProduceBrokerDown(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	address := broker.listener.Addr().String()
+	broker.Close()
+	client := NewClient([]string{address}, "nagflux", time.Duration(200)*time.Millisecond, 1)
+	defer client.Close()
+	if err := client.Produce(map[string][]Message{"metrics": {{Value: []byte("1")}}}); err == nil {
+		t.Error("Producing without a broker should fail")
+	}
+}
+
+func TestClientProduceUnknownTopic(t *testing.T) {
+	broker := NewMockBroker(t, 2)
+	defer broker.Close()
+	client := NewClient([]string{broker.listener.Addr().String()}, "nagflux", time.Duration(2)*time.Second, 1)
+	defer client.Close()
+
+	//UNKNOWN_TOPIC_OR_PARTITION, e.g. while the topic is created
+	broker.mutex.Lock()
+	broker.topicError = 3
+	broker.mutex.Unlock()
+	messages := map[string][]Message{"metrics": {{Value: []byte("1")}}}
+	if err := client.Produce(messages); err == nil {
+		t.Error("Producing to a topic with an error should fail")
+	}
+	if _, known := client.metadata.topics["metrics"]; known {
+		t.Error("A topic with an error should not be cached")
+	}
+
+	broker.mutex.Lock()
+	broker.topicError = 0
+	broker.mutex.Unlock()
+	if err := client.Produce(messages); err != nil {
+		t.Errorf("The topic should be requested again once it exists: %s", err)
+	}
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	total := 0
+	for _, received := range broker.received["metrics"] {
+		total += len(received)
+	}
+	if total != 1 {
+		t.Errorf("Expected 1 message, the broker received %d", total)
+	}
+}
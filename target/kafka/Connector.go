@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/kdar/factorlog"
+	"strings"
+	"time"
+)
+
+//Connector holds the Kafka client and its workers.
+type Connector struct {
+	client        *Client
+	metricsTopic  string
+	messagesTopic string
+	format        string
+	maxBuffered   int
+	dumpFile      string
+	workers       []*Worker
+	maxWorkers    int
+	jobs          chan collector.Printable
+	quit          chan bool
+	log           *factorlog.FactorLog
+	target        data.Target
+	//persistentQueue is true if the jobs come from a WAL, which replays everything that has not been acknowledged.
+	persistentQueue bool
+}
+
+const (
+	//FormatJSON sends every point as JSON object
+	FormatJSON = "json"
+	//FormatInflux sends every point in the InfluxDB line protocol
+	FormatInflux = "influx"
+)
+
+//ConnectorFactory Constructor which will create some workers.
+func ConnectorFactory(jobs chan collector.Printable, brokers, metricsTopic, messagesTopic, format, dumpFile string,
+	workerAmount, maxWorkers, maxBuffered int, target data.Target, clientTimeout int, persistentQueue bool) *Connector {
+	var bootstrap []string
+	for _, broker := range strings.Split(brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			bootstrap = append(bootstrap, broker)
+		}
+	}
+	if format != FormatInflux {
+		format = FormatJSON
+	}
+	if maxBuffered < 1 {
+		maxBuffered = 10000
+	}
+	s := &Connector{
+		client:       NewClient(bootstrap, "nagflux", time.Duration(clientTimeout)*time.Second, 1),
+		metricsTopic: metricsTopic, messagesTopic: messagesTopic, format: format, maxBuffered: maxBuffered,
+		dumpFile: dumpFile, workers: make([]*Worker, workerAmount), maxWorkers: maxWorkers, jobs: jobs,
+		quit: make(chan bool), log: logging.GetLogger(), target: target, persistentQueue: persistentQueue,
+	}
+	gen := WorkerGenerator(jobs, dumpFile, s, target)
+	for w := 0; w < workerAmount; w++ {
+		s.workers[w] = gen(w)
+	}
+	go s.run()
+	return s
+}
+
+//AddWorker creates a new worker
+func (connector *Connector) AddWorker() {
+	oldLength := connector.AmountWorkers()
+	if oldLength < connector.maxWorkers {
+		gen := WorkerGenerator(connector.jobs, connector.dumpFile, connector, connector.target)
+		connector.workers = append(connector.workers, gen(oldLength+2))
+		connector.log.Infof("Starting Worker: %d -> %d", oldLength, connector.AmountWorkers())
+	}
+}
+
+//RemoveWorker stops a worker
+func (connector *Connector) RemoveWorker() {
+	oldLength := connector.AmountWorkers()
+	if oldLength > 1 {
+		lastWorkerIndex := oldLength - 1
+		connector.workers[lastWorkerIndex].Stop()
+		connector.workers = connector.workers[:lastWorkerIndex]
+		connector.log.Infof("Stopping Worker: %d -> %d", oldLength, connector.AmountWorkers())
+	}
+}
+
+//AmountWorkers current amount of workers.
+func (connector Connector) AmountWorkers() int {
+	return len(connector.workers)
+}
+
+//Stop the connector and its workers.
+func (connector *Connector) Stop() {
+	connector.quit <- true
+	<-connector.quit
+	connector.log.Debug("KafkaConnectorFactory stopped")
+}
+
+//Waits just for the end.
+func (connector *Connector) run() {
+	<-connector.quit
+	for _, worker := range connector.workers {
+		go worker.Stop()
+	}
+	for len(connector.workers) > 0 {
+		for connector.workers[0].IsRunning {
+			time.Sleep(time.Duration(100) * time.Millisecond)
+		}
+		connector.workers = connector.workers[1:]
+	}
+	connector.client.Close()
+	connector.quit <- true
+}
@@ -0,0 +1,354 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+//Kafka API keys and versions which are used by the producer.
+const (
+	apiKeyProduce   int16 = 0
+	apiKeyMetadata  int16 = 3
+	produceVersion  int16 = 3
+	metadataVersion int16 = 1
+)
+
+var errorShortBuffer = errors.New("Kafka response is too short")
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+//encoder writes the big endian primitives of the Kafka protocol.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int8(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *encoder) int16(v int16) {
+	e.buf = append(e.buf, byte(v>>8), byte(v))
+}
+
+func (e *encoder) int32(v int32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.int32(int32(v >> 32))
+	e.int32(int32(v))
+}
+
+func (e *encoder) varint(v int64) {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	e.buf = append(e.buf, tmp[:binary.PutVarint(tmp, v)]...)
+}
+
+func (e *encoder) string(v string) {
+	e.int16(int16(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) nullableString(v *string) {
+	if v == nil {
+		e.int16(-1)
+		return
+	}
+	e.string(*v)
+}
+
+func (e *encoder) bytes(v []byte) {
+	e.int32(int32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) varintBytes(v []byte) {
+	if v == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+//decoder reads the big endian primitives of the Kafka protocol and remembers the first error.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || len(d.buf) < n {
+		d.err = errorShortBuffer
+		return make([]byte, n)
+	}
+	result := d.buf[:n]
+	d.buf = d.buf[n:]
+	return result
+}
+
+func (d *decoder) int8() int8 {
+	return int8(d.take(1)[0])
+}
+
+func (d *decoder) int16() int16 {
+	return int16(binary.BigEndian.Uint16(d.take(2)))
+}
+
+func (d *decoder) int32() int32 {
+	return int32(binary.BigEndian.Uint32(d.take(4)))
+}
+
+func (d *decoder) int64() int64 {
+	return int64(binary.BigEndian.Uint64(d.take(8)))
+}
+
+func (d *decoder) string() string {
+	length := d.int16()
+	if length < 0 {
+		return ""
+	}
+	return string(d.take(int(length)))
+}
+
+func (d *decoder) bytes() []byte {
+	length := d.int32()
+	if length < 0 {
+		return nil
+	}
+	return d.take(int(length))
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errorShortBuffer
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varintBytes() []byte {
+	length := d.varint()
+	if length < 0 {
+		return nil
+	}
+	return d.take(int(length))
+}
+
+//Message is a single Kafka record.
+type Message struct {
+	Key       []byte
+	Value     []byte
+	Timestamp int64
+}
+
+//encodeRequest frames a request with the header v1.
+func encodeRequest(apiKey, apiVersion int16, correlationID int32, clientID string, body []byte) []byte {
+	e := &encoder{}
+	e.int32(0)
+	e.int16(apiKey)
+	e.int16(apiVersion)
+	e.int32(correlationID)
+	e.nullableString(&clientID)
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+	return e.buf
+}
+
+//encodeRecordBatch creates a record batch with magic 2.
+func encodeRecordBatch(messages []Message) []byte {
+	if len(messages) == 0 {
+		return nil
+	}
+	baseTimestamp := messages[0].Timestamp
+	maxTimestamp := baseTimestamp
+	records := &encoder{}
+	for i, message := range messages {
+		if message.Timestamp > maxTimestamp {
+			maxTimestamp = message.Timestamp
+		}
+		record := &encoder{}
+		record.int8(0)
+		record.varint(message.Timestamp - baseTimestamp)
+		record.varint(int64(i))
+		record.varintBytes(message.Key)
+		record.varintBytes(message.Value)
+		record.varint(0)
+		records.varint(int64(len(record.buf)))
+		records.buf = append(records.buf, record.buf...)
+	}
+
+	//everything after the crc, which is covered by it
+	crcPart := &encoder{}
+	crcPart.int16(0)
+	crcPart.int32(int32(len(messages) - 1))
+	crcPart.int64(baseTimestamp)
+	crcPart.int64(maxTimestamp)
+	crcPart.int64(-1)
+	crcPart.int16(-1)
+	crcPart.int32(-1)
+	crcPart.int32(int32(len(messages)))
+	crcPart.buf = append(crcPart.buf, records.buf...)
+
+	batch := &encoder{}
+	batch.int64(0)
+	//partitionLeaderEpoch + magic + crc + crcPart
+	batch.int32(int32(4 + 1 + 4 + len(crcPart.buf)))
+	batch.int32(-1)
+	batch.int8(2)
+	batch.int32(int32(crc32.Checksum(crcPart.buf, crc32c)))
+	batch.buf = append(batch.buf, crcPart.buf...)
+	return batch.buf
+}
+
+//decodeRecordBatch is the counterpart of encodeRecordBatch.
+func decodeRecordBatch(raw []byte) ([]Message, error) {
+	d := &decoder{buf: raw}
+	d.int64()
+	length := d.int32()
+	d.int32()
+	if magic := d.int8(); magic != 2 {
+		return nil, errors.New("Unsupported record batch magic")
+	}
+	crc := uint32(d.int32())
+	if d.err != nil || int(length) != len(raw)-12 {
+		return nil, errorShortBuffer
+	}
+	if crc32.Checksum(d.buf, crc32c) != crc {
+		return nil, errors.New("Record batch crc mismatch")
+	}
+	d.int16()
+	d.int32()
+	baseTimestamp := d.int64()
+	d.int64()
+	d.int64()
+	d.int16()
+	d.int32()
+	count := d.int32()
+	var result []Message
+	for i := int32(0); i < count && d.err == nil; i++ {
+		d.varint()
+		d.int8()
+		timestamp := baseTimestamp + d.varint()
+		d.varint()
+		key := d.varintBytes()
+		value := d.varintBytes()
+		for headers := d.varint(); headers > 0; headers-- {
+			d.varintBytes()
+			d.varintBytes()
+		}
+		result = append(result, Message{Key: key, Value: value, Timestamp: timestamp})
+	}
+	return result, d.err
+}
+
+//partitionMetadata contains the leader of a partition.
+type partitionMetadata struct {
+	errorCode int16
+	id        int32
+	leader    int32
+}
+
+//metadata is the decoded Metadata v1 response.
+type metadata struct {
+	brokers map[int32]string
+	topics  map[string][]partitionMetadata
+	errors  map[string]int16
+}
+
+func encodeMetadataRequest(topics []string) []byte {
+	e := &encoder{}
+	e.int32(int32(len(topics)))
+	for _, topic := range topics {
+		e.string(topic)
+	}
+	return e.buf
+}
+
+func decodeMetadataResponse(raw []byte) (metadata, error) {
+	d := &decoder{buf: raw}
+	result := metadata{brokers: map[int32]string{}, topics: map[string][]partitionMetadata{}, errors: map[string]int16{}}
+	for brokers := d.int32(); brokers > 0 && d.err == nil; brokers-- {
+		nodeID := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string()
+		result.brokers[nodeID] = joinHostPort(host, port)
+	}
+	d.int32()
+	for topics := d.int32(); topics > 0 && d.err == nil; topics-- {
+		errorCode := d.int16()
+		name := d.string()
+		d.int8()
+		result.errors[name] = errorCode
+		var partitions []partitionMetadata
+		for count := d.int32(); count > 0 && d.err == nil; count-- {
+			partition := partitionMetadata{errorCode: d.int16(), id: d.int32(), leader: d.int32()}
+			for replicas := d.int32(); replicas > 0 && d.err == nil; replicas-- {
+				d.int32()
+			}
+			for isr := d.int32(); isr > 0 && d.err == nil; isr-- {
+				d.int32()
+			}
+			partitions = append(partitions, partition)
+		}
+		result.topics[name] = partitions
+	}
+	return result, d.err
+}
+
+//produceBatch is the data for one partition of a topic.
+type produceBatch struct {
+	topic     string
+	partition int32
+	messages  []Message
+}
+
+func encodeProduceRequest(acks int16, timeoutMs int32, batches []produceBatch) []byte {
+	byTopic := map[string][]produceBatch{}
+	var order []string
+	for _, batch := range batches {
+		if _, found := byTopic[batch.topic]; !found {
+			order = append(order, batch.topic)
+		}
+		byTopic[batch.topic] = append(byTopic[batch.topic], batch)
+	}
+	e := &encoder{}
+	e.nullableString(nil)
+	e.int16(acks)
+	e.int32(timeoutMs)
+	e.int32(int32(len(order)))
+	for _, topic := range order {
+		e.string(topic)
+		e.int32(int32(len(byTopic[topic])))
+		for _, batch := range byTopic[topic] {
+			e.int32(batch.partition)
+			e.bytes(encodeRecordBatch(batch.messages))
+		}
+	}
+	return e.buf
+}
+
+//decodeProduceResponse returns the error codes by topic and partition.
+func decodeProduceResponse(raw []byte) (map[string]map[int32]int16, error) {
+	d := &decoder{buf: raw}
+	result := map[string]map[int32]int16{}
+	for topics := d.int32(); topics > 0 && d.err == nil; topics-- {
+		name := d.string()
+		result[name] = map[int32]int16{}
+		for partitions := d.int32(); partitions > 0 && d.err == nil; partitions-- {
+			id := d.int32()
+			result[name][id] = d.int16()
+			d.int64()
+			d.int64()
+		}
+	}
+	d.int32()
+	return result, d.err
+}
@@ -0,0 +1,225 @@
+package kafka
+
+import (
+	"encoding/json"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/collector/nagflux"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/griesbacher/nagflux/statistics"
+	"github.com/griesbacher/nagflux/target/influx"
+	"github.com/kdar/factorlog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Worker reads data from the queue and publishes them to Kafka.
+type Worker struct {
+	workerID   int
+	quit       chan bool
+	jobs       chan collector.Printable
+	dumpFile   string
+	log        *factorlog.FactorLog
+	connector  *Connector
+	IsRunning  bool
+	promServer statistics.PrometheusServer
+	target     data.Target
+}
+
+//pendingMessage remembers the line, which is written to the dumpfile if the brokers are not reachable.
+//The last message of a printable carries it, so it is acknowledged after all of its messages are handled.
+type pendingMessage struct {
+	topic   string
+	message Message
+	line    string
+	job     collector.Printable
+}
+
+const dataTimeout = time.Duration(5) * time.Second
+
+var mutex = &sync.Mutex{}
+
+//WorkerGenerator generates a new Worker and starts it.
+func WorkerGenerator(jobs chan collector.Printable, dumpFile string, connector *Connector, target data.Target) func(workerId int) *Worker {
+	return func(workerId int) *Worker {
+		worker := &Worker{
+			workerID: workerId, quit: make(chan bool), jobs: jobs,
+			dumpFile: nagflux.GenDumpfileName(dumpFile, target), log: logging.GetLogger(),
+			connector: connector, IsRunning: true, promServer: statistics.GetPrometheusServer(), target: target,
+		}
+		go worker.run()
+		return worker
+	}
+}
+
+//Stop stops the worker
+func (worker *Worker) Stop() {
+	worker.quit <- true
+	<-worker.quit
+	worker.IsRunning = false
+	worker.log.Debug("KafkaWorker(" + worker.target.Name + ") stopped")
+}
+
+//Collects the data and sends them in batches, unsent messages stay in a bounded buffer.
+//With a WAL nothing is dumped, instead no more jobs are read while the buffer is full.
+func (worker Worker) run() {
+	var pending []pendingMessage
+	var query collector.Printable
+	received := 0
+	for {
+		jobs := worker.jobs
+		if worker.connector.persistentQueue && len(pending) >= worker.connector.maxBuffered {
+			jobs = nil
+		}
+		select {
+		case <-worker.quit:
+			worker.log.Debug("KafkaWorker(" + worker.target.Name + ") quitting...")
+			if len(pending) == 0 || worker.send(pending) || (!worker.connector.persistentQueue && worker.dumpMessages(pending) == nil) {
+				acknowledgeMessages(pending)
+			}
+			worker.quit <- true
+			return
+		case query = <-jobs:
+			if query.TestTargetFilter(worker.target.Name) {
+				pending = append(pending, worker.castJobToMessages(query)...)
+				received++
+				if received >= 500 {
+					pending = worker.flush(pending)
+					received = 0
+				}
+			}
+		case <-time.After(dataTimeout):
+			pending = worker.flush(pending)
+			received = 0
+		}
+	}
+}
+
+//flush tries to send the pending messages and spills the oldest ones to disk if the buffer is full.
+func (worker Worker) flush(pending []pendingMessage) []pendingMessage {
+	if len(pending) == 0 {
+		return pending
+	}
+	if worker.send(pending) {
+		acknowledgeMessages(pending)
+		return pending[:0]
+	}
+	if overflow := len(pending) - worker.connector.maxBuffered; overflow > 0 && !worker.connector.persistentQueue {
+		worker.log.Infof("Kafka(%s) buffer is full, dumping %d messages to: %s", worker.target.Name, overflow, worker.dumpFile)
+		if worker.dumpMessages(pending[:overflow]) == nil {
+			acknowledgeMessages(pending[:overflow])
+		}
+		pending = append(pending[:0], pending[overflow:]...)
+	}
+	return pending
+}
+
+//send publishes the messages and returns true on success.
+func (worker Worker) send(pending []pendingMessage) bool {
+	byTopic := map[string][]Message{}
+	size := 0
+	for _, p := range pending {
+		byTopic[p.topic] = append(byTopic[p.topic], p.message)
+		size += len(p.message.Value)
+	}
+	startTime := time.Now()
+	if err := worker.connector.client.Produce(byTopic); err != nil {
+		worker.log.Warnf("Kafka(%s): %s", worker.target.Name, err)
+		return false
+	}
+	worker.promServer.BytesSend.WithLabelValues("Kafka").Add(float64(size))
+	timeDiff := float64(time.Since(startTime).Seconds() * 1000)
+	if timeDiff >= 0 {
+		worker.promServer.SendDuration.WithLabelValues("Kafka").Add(timeDiff)
+	}
+	return true
+}
+
+//Writes the line protocol of the messages to the dumpfile, the error is logged and returned.
+func (worker Worker) dumpMessages(pending []pendingMessage) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	f, err := os.OpenFile(worker.dumpFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		worker.log.Critical(err)
+		return err
+	}
+	defer f.Close()
+	for _, p := range pending {
+		if _, err = f.WriteString(p.line + "\n"); err != nil {
+			worker.log.Critical(err)
+			return err
+		}
+	}
+	return nil
+}
+
+//Converts an collector.Printable to Kafka messages, keyed by the host.
+func (worker Worker) castJobToMessages(job collector.Printable) []pendingMessage {
+	points := job.Points()
+	if simple, ok := job.(collector.SimplePrintable); ok {
+		//Lines from a dumpfile
+		if simple.Datatype != data.Kafka {
+			return nil
+		}
+		for _, line := range strings.Split(simple.Text, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			point, err := collector.NewPointFromInfluxLine(simple.Filterable, line)
+			if err != nil {
+				worker.log.Warn("Kafka could not parse line: ", err)
+				continue
+			}
+			points = append(points, point)
+		}
+	}
+	var result []pendingMessage
+	for _, point := range points {
+		if message, ok := worker.pointToMessage(point); ok {
+			result = append(result, message)
+		}
+	}
+	if len(result) == 0 {
+		job.Acknowledge()
+	} else {
+		result[len(result)-1].job = job
+	}
+	return result
+}
+
+//acknowledgeMessages confirms the printables of the messages, which have been sent or dumped.
+func acknowledgeMessages(pending []pendingMessage) {
+	for _, p := range pending {
+		if p.job != nil {
+			p.job.Acknowledge()
+		}
+	}
+}
+
+func (worker Worker) pointToMessage(point collector.Point) (pendingMessage, bool) {
+	line := strings.TrimRight(influx.PointToLine(point), "\n")
+	if line == "" {
+		return pendingMessage{}, false
+	}
+	message := Message{Timestamp: point.TimestampMs()}
+	if host, found := point.Tags["host"]; found {
+		message.Key = []byte(host)
+	}
+	if worker.connector.format == FormatInflux {
+		message.Value = []byte(line)
+	} else {
+		var err error
+		if message.Value, err = json.Marshal(point); err != nil {
+			worker.log.Warn(err)
+			return pendingMessage{}, false
+		}
+	}
+	topic := worker.connector.messagesTopic
+	if point.Measurement == "metrics" {
+		topic = worker.connector.metricsTopic
+	}
+	return pendingMessage{topic: topic, message: message, line: line}, true
+}
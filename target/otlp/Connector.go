@@ -0,0 +1,98 @@
+package otlp
+
+import (
+	"crypto/tls"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/helper"
+	"github.com/griesbacher/nagflux/logging"
+	"github.com/griesbacher/nagflux/target/batch"
+	"github.com/kdar/factorlog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Connector starts the workers which are sending the data to an OTLP/HTTP receiver.
+type Connector struct {
+	connectionHost string
+	dumpFile       string
+	workers        []*batch.Worker
+	maxWorkers     int
+	jobs           chan collector.Printable
+	quit           chan bool
+	log            *factorlog.FactorLog
+	httpClient     http.Client
+	headers        map[string]string
+	target         data.Target
+	//persistentQueue is true if the jobs come from a WAL, which replays everything that has not been acknowledged.
+	persistentQueue bool
+}
+
+//ConnectorFactory Constructor which will create some workers.
+//connectionHost is the base URL of the receiver, headers are comma separated key=value pairs which are added to every request.
+func ConnectorFactory(jobs chan collector.Printable, connectionHost, headers, dumpFile string,
+	workerAmount, maxWorkers int, target data.Target, clientTimeout int, persistentQueue bool) *Connector {
+	timeout := time.Duration(clientTimeout) * time.Second
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	s := &Connector{
+		connectionHost: strings.TrimRight(connectionHost, "/"), dumpFile: dumpFile, workers: make([]*batch.Worker, workerAmount),
+		maxWorkers: maxWorkers, jobs: jobs, quit: make(chan bool), log: logging.GetLogger(),
+		httpClient: http.Client{Timeout: timeout, Transport: transport},
+		headers:    helper.StringToMap(headers, ",", "="), target: target, persistentQueue: persistentQueue,
+	}
+	gen := WorkerGenerator(jobs, dumpFile, s, target)
+	for w := 0; w < workerAmount; w++ {
+		s.workers[w] = gen(w)
+	}
+	go s.run()
+	return s
+}
+
+//AddWorker creates a new worker
+func (connector *Connector) AddWorker() {
+	oldLength := connector.AmountWorkers()
+	if oldLength < connector.maxWorkers {
+		gen := WorkerGenerator(connector.jobs, connector.dumpFile, connector, connector.target)
+		connector.workers = append(connector.workers, gen(oldLength+2))
+		connector.log.Infof("Starting Worker: %d -> %d", oldLength, connector.AmountWorkers())
+	}
+}
+
+//RemoveWorker stops a worker
+func (connector *Connector) RemoveWorker() {
+	oldLength := connector.AmountWorkers()
+	if oldLength > 1 {
+		lastWorkerIndex := oldLength - 1
+		connector.workers[lastWorkerIndex].Stop()
+		connector.workers = connector.workers[:lastWorkerIndex]
+		connector.log.Infof("Stopping Worker: %d -> %d", oldLength, connector.AmountWorkers())
+	}
+}
+
+//AmountWorkers current amount of workers.
+func (connector Connector) AmountWorkers() int {
+	return len(connector.workers)
+}
+
+//Stop the connector and its workers.
+func (connector *Connector) Stop() {
+	connector.quit <- true
+	<-connector.quit
+	connector.log.Debug("OTLPConnectorFactory stopped")
+}
+
+//Waits just for the end.
+func (connector *Connector) run() {
+	<-connector.quit
+	for _, worker := range connector.workers {
+		go worker.Stop()
+	}
+	for len(connector.workers) > 0 {
+		for connector.workers[0].IsRunning {
+			time.Sleep(time.Duration(100) * time.Millisecond)
+		}
+		connector.workers = connector.workers[1:]
+	}
+	connector.quit <- true
+}
@@ -0,0 +1,244 @@
+package otlp
+
+import (
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/helper"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//The types below are the JSON mapping of the OTLP protobuf messages, only the parts used by nagflux are defined.
+
+//AnyValue holds an attribute value or a log body.
+type AnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+//KeyValue is a single attribute.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+//Resource describes the monitored object, in nagflux the host and service.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+//Scope names the instrumentation which created the data.
+type Scope struct {
+	Name string `json:"name"`
+}
+
+//NumberDataPoint is a single gauge value.
+type NumberDataPoint struct {
+	Attributes   []KeyValue `json:"attributes,omitempty"`
+	TimeUnixNano string     `json:"timeUnixNano"`
+	AsDouble     float64    `json:"asDouble"`
+}
+
+//Gauge contains the data points of a metric.
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+//Metric is a named gauge.
+type Metric struct {
+	Name  string `json:"name"`
+	Unit  string `json:"unit,omitempty"`
+	Gauge Gauge  `json:"gauge"`
+}
+
+//ScopeMetrics groups metrics by scope.
+type ScopeMetrics struct {
+	Scope   Scope    `json:"scope"`
+	Metrics []Metric `json:"metrics"`
+}
+
+//ResourceMetrics groups metrics by resource.
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+//MetricsRequest is the body of /v1/metrics.
+type MetricsRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+//LogRecord is a single event.
+type LogRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	Body                 AnyValue   `json:"body"`
+	Attributes           []KeyValue `json:"attributes,omitempty"`
+}
+
+//ScopeLogs groups log records by scope.
+type ScopeLogs struct {
+	Scope      Scope       `json:"scope"`
+	LogRecords []LogRecord `json:"logRecords"`
+}
+
+//ResourceLogs groups log records by resource.
+type ResourceLogs struct {
+	Resource  Resource    `json:"resource"`
+	ScopeLogs []ScopeLogs `json:"scopeLogs"`
+}
+
+//LogsRequest is the body of /v1/logs.
+type LogsRequest struct {
+	ResourceLogs []ResourceLogs `json:"resourceLogs"`
+}
+
+//scopeName is used as instrumentation scope for all data.
+const scopeName = "nagflux"
+
+//metricPrefix is added in front of every field name.
+const metricPrefix = "nagflux."
+
+//resourceTags maps the tags, which describe the monitored object, to their resource attribute names.
+var resourceTags = map[string]string{
+	"host":    "host.name",
+	"service": "nagios.service",
+	"command": "nagios.command",
+}
+
+//IsLog returns true if the point is sent as log record instead of a metric.
+func IsLog(p collector.Point) bool {
+	return p.Measurement == "messages"
+}
+
+//PointsToMetricsRequest converts every numeric field into a gauge named nagflux.<field>, thresholds like warn and crit become their own gauges.
+//Host, service and command are resource attributes, all other tags are data point attributes and the unit tag is the metric unit.
+//The data points of a resource, which have the same name and unit, belong to a single metric.
+func PointsToMetricsRequest(points []collector.Point) MetricsRequest {
+	request := MetricsRequest{}
+	index := map[string]int{}
+	metricIndex := map[string]int{}
+	for _, p := range points {
+		resource, attributes := splitAttributes(p.Tags, "unit")
+		key := resourceKey(resource)
+		i, found := index[key]
+		if !found {
+			i = len(request.ResourceMetrics)
+			index[key] = i
+			request.ResourceMetrics = append(request.ResourceMetrics, ResourceMetrics{
+				Resource:     resource,
+				ScopeMetrics: []ScopeMetrics{{Scope: Scope{Name: scopeName}}},
+			})
+		}
+		scope := &request.ResourceMetrics[i].ScopeMetrics[0]
+		numericFields := p.NumericFields()
+		for _, field := range sortedNumberKeys(numericFields) {
+			name := metricPrefix + field
+			if p.Measurement != "metrics" {
+				name = metricPrefix + p.Measurement + "." + field
+			}
+			metricKey := key + "\x01" + name + "\x00" + p.Tags["unit"]
+			m, found := metricIndex[metricKey]
+			if !found {
+				m = len(scope.Metrics)
+				metricIndex[metricKey] = m
+				scope.Metrics = append(scope.Metrics, Metric{Name: name, Unit: p.Tags["unit"]})
+			}
+			scope.Metrics[m].Gauge.DataPoints = append(scope.Metrics[m].Gauge.DataPoints, NumberDataPoint{
+				Attributes:   attributes,
+				TimeUnixNano: unixNano(p),
+				AsDouble:     numericFields[field],
+			})
+		}
+	}
+	return request
+}
+
+//PointsToLogsRequest converts messages into log records, the message field becomes the body and all other tags and fields attributes.
+func PointsToLogsRequest(points []collector.Point) LogsRequest {
+	request := LogsRequest{}
+	index := map[string]int{}
+	observed := strconv.FormatInt(time.Now().UnixNano(), 10)
+	for _, p := range points {
+		resource, attributes := splitAttributes(p.Tags)
+		for _, field := range sortedFieldKeys(p.Fields) {
+			if field != "message" {
+				attributes = append(attributes, KeyValue{Key: field, Value: AnyValue{StringValue: fmt.Sprint(p.Fields[field])}})
+			}
+		}
+		key := resourceKey(resource)
+		i, found := index[key]
+		if !found {
+			i = len(request.ResourceLogs)
+			index[key] = i
+			request.ResourceLogs = append(request.ResourceLogs, ResourceLogs{
+				Resource:  resource,
+				ScopeLogs: []ScopeLogs{{Scope: Scope{Name: scopeName}}},
+			})
+		}
+		scope := &request.ResourceLogs[i].ScopeLogs[0]
+		scope.LogRecords = append(scope.LogRecords, LogRecord{
+			TimeUnixNano:         unixNano(p),
+			ObservedTimeUnixNano: observed,
+			Body:                 AnyValue{StringValue: fmt.Sprint(p.Fields["message"])},
+			Attributes:           attributes,
+		})
+	}
+	return request
+}
+
+//splitAttributes sorts the tags into resource and other attributes, empty and skipped tags are dropped.
+func splitAttributes(tags map[string]string, skip ...string) (Resource, []KeyValue) {
+	resource := Resource{Attributes: []KeyValue{}}
+	var attributes []KeyValue
+	for _, k := range sortedTagKeys(tags) {
+		if tags[k] == "" || helper.Contains(skip, []string{k}) {
+			continue
+		}
+		if name, ok := resourceTags[k]; ok {
+			resource.Attributes = append(resource.Attributes, KeyValue{Key: name, Value: AnyValue{StringValue: tags[k]}})
+		} else {
+			attributes = append(attributes, KeyValue{Key: k, Value: AnyValue{StringValue: tags[k]}})
+		}
+	}
+	return resource, attributes
+}
+
+func resourceKey(resource Resource) string {
+	key := ""
+	for _, attribute := range resource.Attributes {
+		key += attribute.Key + "=" + attribute.Value.StringValue + "\x00"
+	}
+	return key
+}
+
+func unixNano(p collector.Point) string {
+	return strconv.FormatInt(p.TimestampMs()*int64(time.Millisecond), 10)
+}
+
+func sortedTagKeys(input map[string]string) []string {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedNumberKeys(input map[string]float64) []string {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedFieldKeys(input map[string]interface{}) []string {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
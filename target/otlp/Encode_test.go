@@ -0,0 +1,63 @@
+package otlp
+
+import (
+	"encoding/json"
+	"github.com/griesbacher/nagflux/collector"
+	"testing"
+	"time"
+)
+
+func TestPointsToMetricsRequest(t *testing.T) {
+	t.Parallel()
+	points := []collector.Point{
+		{Measurement: "metrics", Tags: map[string]string{"host": "h1", "service": "s1", "command": "check_disk", "performanceLabel": "C:", "unit": "%"},
+			Fields: map[string]interface{}{"value": 44.0, "warn": float64(80), "unknown": true}, Timestamp: 1000, Precision: time.Millisecond},
+		{Measurement: "metrics", Tags: map[string]string{"host": "h1", "service": "s1", "command": "check_disk", "performanceLabel": "D:"},
+			Fields: map[string]interface{}{"value": 1.5}, Timestamp: 2, Precision: time.Second},
+		{Measurement: "metrics", Tags: map[string]string{"host": "h1", "service": "s1", "command": "check_disk", "performanceLabel": "E:", "unit": "%"},
+			Fields: map[string]interface{}{"value": 2.0}, Timestamp: 3, Precision: time.Second},
+		{Measurement: "metrics", Tags: map[string]string{"host": "h2", "performanceLabel": "rta"},
+			Fields: map[string]interface{}{"value": 0.5}, Timestamp: 3, Precision: time.Second},
+	}
+	out, err := json.Marshal(PointsToMetricsRequest(points))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"resourceMetrics":[{"resource":{"attributes":[` +
+		`{"key":"nagios.command","value":{"stringValue":"check_disk"}},{"key":"host.name","value":{"stringValue":"h1"}},{"key":"nagios.service","value":{"stringValue":"s1"}}]},` +
+		`"scopeMetrics":[{"scope":{"name":"nagflux"},"metrics":[` +
+		`{"name":"nagflux.value","unit":"%","gauge":{"dataPoints":[{"attributes":[{"key":"performanceLabel","value":{"stringValue":"C:"}}],"timeUnixNano":"1000000000","asDouble":44},` +
+		`{"attributes":[{"key":"performanceLabel","value":{"stringValue":"E:"}}],"timeUnixNano":"3000000000","asDouble":2}]}},` +
+		`{"name":"nagflux.warn","unit":"%","gauge":{"dataPoints":[{"attributes":[{"key":"performanceLabel","value":{"stringValue":"C:"}}],"timeUnixNano":"1000000000","asDouble":80}]}},` +
+		`{"name":"nagflux.value","gauge":{"dataPoints":[{"attributes":[{"key":"performanceLabel","value":{"stringValue":"D:"}}],"timeUnixNano":"2000000000","asDouble":1.5}]}}]}]},` +
+		`{"resource":{"attributes":[{"key":"host.name","value":{"stringValue":"h2"}}]},"scopeMetrics":[{"scope":{"name":"nagflux"},"metrics":[` +
+		`{"name":"nagflux.value","gauge":{"dataPoints":[{"attributes":[{"key":"performanceLabel","value":{"stringValue":"rta"}}],"timeUnixNano":"3000000000","asDouble":0.5}]}}]}]}]}`
+	if string(out) != expected {
+		t.Errorf("expected:\n%s\nactual:\n%s", expected, out)
+	}
+}
+
+func TestPointsToLogsRequest(t *testing.T) {
+	t.Parallel()
+	points := []collector.Point{
+		{Measurement: "messages", Tags: map[string]string{"host": "h1", "service": "s1", "type": "comment", "author": "philip"},
+			Fields: map[string]interface{}{"message": "hallo world"}, Timestamp: 1458988932, Precision: time.Second},
+	}
+	request := PointsToLogsRequest(points)
+	if len(request.ResourceLogs) != 1 || len(request.ResourceLogs[0].ScopeLogs[0].LogRecords) != 1 {
+		t.Fatalf("expected a single log record, got: %v", request)
+	}
+	record := request.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if record.Body.StringValue != "hallo world" || record.TimeUnixNano != "1458988932000000000" {
+		t.Errorf("log record does not match: %v", record)
+	}
+	expectedAttributes := []KeyValue{{"author", AnyValue{"philip"}}, {"type", AnyValue{"comment"}}}
+	if len(record.Attributes) != len(expectedAttributes) {
+		t.Fatalf("expected attributes: %v, actual: %v", expectedAttributes, record.Attributes)
+	}
+	for i, attribute := range expectedAttributes {
+		if record.Attributes[i] != attribute {
+			t.Errorf("expected attributes: %v, actual: %v", expectedAttributes, record.Attributes)
+		}
+	}
+}
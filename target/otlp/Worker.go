@@ -0,0 +1,132 @@
+package otlp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/target/batch"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	metricsPath = "/v1/metrics"
+	logsPath    = "/v1/logs"
+)
+
+//WorkerGenerator generates a new Worker and starts it.
+func WorkerGenerator(jobs chan collector.Printable, dumpFile string, connector *Connector, target data.Target) func(workerId int) *batch.Worker {
+	return func(workerId int) *batch.Worker {
+		return batch.NewWorker("OTLP", jobs, dumpFile, connector, target, connector.persistentQueue)
+	}
+}
+
+//Requests converts the queries into a metrics and a logs request.
+func (connector *Connector) Requests(queries []collector.Printable) []batch.Request {
+	var metrics, logs []collector.Point
+	for _, query := range queries {
+		for _, point := range connector.castJobToPoints(query) {
+			if IsLog(point) {
+				logs = append(logs, point)
+			} else {
+				metrics = append(metrics, point)
+			}
+		}
+	}
+	var result []batch.Request
+	if len(metrics) > 0 {
+		result = append(result, connector.request(metricsPath, PointsToMetricsRequest(metrics), metrics))
+	}
+	if len(logs) > 0 {
+		result = append(result, connector.request(logsPath, PointsToLogsRequest(logs), logs))
+	}
+	return result
+}
+
+//request encodes the body, OTLP rejects the whole request so it is not split.
+func (connector *Connector) request(path string, body interface{}, points []collector.Point) batch.Request {
+	dataToSend, err := json.Marshal(body)
+	if err != nil {
+		connector.log.Warn(err)
+	}
+	return batch.Request{Path: path, Data: dataToSend, Dump: func() []byte { return connector.dumpPoints(points) }}
+}
+
+//Send sends the request to the receiver and returns an err if given.
+func (connector *Connector) Send(request batch.Request, log bool) error {
+	if request.Data == nil {
+		//The points could not be encoded
+		return batch.ErrorBadRequest
+	}
+	req, err := http.NewRequest("POST", connector.connectionHost+request.Path, bytes.NewBuffer(request.Data))
+	if err != nil {
+		connector.log.Warn(err)
+		return batch.ErrorHTTPClient
+	}
+	req.Header.Set("User-Agent", "Nagflux")
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range connector.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := connector.httpClient.Do(req)
+	if err != nil {
+		connector.log.Warn(err)
+		return batch.ErrorHTTPClient
+	}
+	defer resp.Body.Close()
+	connector.log.Debug(resp.Status)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if log {
+		body, _ := ioutil.ReadAll(resp.Body)
+		connector.log.Warnf("OTLP status: %s - %s", resp.Status, string(body))
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		//The data will not get better by sending it again
+		return batch.ErrorBadRequest
+	}
+	return batch.ErrorFailedToSend
+}
+
+//Writes the points as JSON lines, the DumpfileCollector reads them line by line.
+func (connector *Connector) dumpPoints(points []collector.Point) []byte {
+	var buffer bytes.Buffer
+	for _, point := range points {
+		line, err := json.Marshal(point)
+		if err != nil {
+			connector.log.Critical(err)
+			continue
+		}
+		buffer.Write(line)
+		buffer.WriteString("\n")
+	}
+	return buffer.Bytes()
+}
+
+//Converts an collector.Printable to points, dumped lines are parsed back.
+func (connector *Connector) castJobToPoints(job collector.Printable) []collector.Point {
+	p, ok := job.(collector.SimplePrintable)
+	if !ok {
+		return job.Points()
+	}
+	if p.Datatype != data.OTLP {
+		return nil
+	}
+	//Lines from a dumpfile
+	var result []collector.Point
+	scanner := bufio.NewScanner(strings.NewReader(p.Text))
+	for scanner.Scan() {
+		var point collector.Point
+		if err := json.Unmarshal(scanner.Bytes(), &point); err != nil {
+			connector.log.Warn("Could not parse dumped point: ", err)
+			continue
+		}
+		point.Filterable = p.Filterable
+		result = append(result, point)
+	}
+	return result
+}
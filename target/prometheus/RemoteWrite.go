@@ -0,0 +1,62 @@
+package prometheus
+
+import (
+	"github.com/golang/protobuf/proto"
+	"math"
+	"sort"
+)
+
+//Label is a name value pair of a series
+type Label struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+//Sample is a value at a certain time in ms
+type Sample struct {
+	Value     float64 `json:"value"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+//TimeSeries is a set of labels with its samples
+type TimeSeries struct {
+	Labels  []Label  `json:"labels"`
+	Samples []Sample `json:"samples"`
+}
+
+//sortLabels sorts the labels by name, which is required by the remote-write protocol.
+func (ts *TimeSeries) sortLabels() {
+	sort.Slice(ts.Labels, func(i, j int) bool { return ts.Labels[i].Name < ts.Labels[j].Name })
+}
+
+//EncodeWriteRequest encodes the series as prometheus.WriteRequest protobuf message.
+//WriteRequest{repeated TimeSeries timeseries = 1}, TimeSeries{repeated Label labels = 1; repeated Sample samples = 2}
+//Label{string name = 1; string value = 2}, Sample{double value = 1; int64 timestamp = 2}
+func EncodeWriteRequest(series []TimeSeries) []byte {
+	request := proto.NewBuffer(nil)
+	for _, ts := range series {
+		ts.sortLabels()
+		tsBuffer := proto.NewBuffer(nil)
+		for _, label := range ts.Labels {
+			labelBuffer := proto.NewBuffer(nil)
+			labelBuffer.EncodeVarint(1<<3 | proto.WireBytes)
+			labelBuffer.EncodeStringBytes(label.Name)
+			labelBuffer.EncodeVarint(2<<3 | proto.WireBytes)
+			labelBuffer.EncodeStringBytes(label.Value)
+			tsBuffer.EncodeVarint(1<<3 | proto.WireBytes)
+			tsBuffer.EncodeRawBytes(labelBuffer.Bytes())
+		}
+		for _, sample := range ts.Samples {
+			sampleBuffer := proto.NewBuffer(nil)
+			sampleBuffer.EncodeVarint(1<<3 | proto.WireFixed64)
+			sampleBuffer.EncodeFixed64(math.Float64bits(sample.Value))
+			sampleBuffer.EncodeVarint(2<<3 | proto.WireVarint)
+			sampleBuffer.EncodeVarint(uint64(sample.Timestamp))
+			tsBuffer.EncodeVarint(2<<3 | proto.WireBytes)
+			tsBuffer.EncodeRawBytes(sampleBuffer.Bytes())
+		}
+		request.EncodeVarint(1<<3 | proto.WireBytes)
+		request.EncodeRawBytes(tsBuffer.Bytes())
+	}
+	return request.Bytes()
+}
@@ -0,0 +1,82 @@
+package prometheus
+
+import (
+	"github.com/golang/protobuf/proto"
+	"math"
+	"reflect"
+	"testing"
+)
+
+//decodeWriteRequest is the counterpart of EncodeWriteRequest.
+func decodeWriteRequest(t *testing.T, raw []byte) []TimeSeries {
+	var result []TimeSeries
+	request := proto.NewBuffer(raw)
+	for {
+		key, err := request.DecodeVarint()
+		if err != nil {
+			//end of the buffer
+			return result
+		}
+		if key != 1<<3|proto.WireBytes {
+			t.Fatalf("Unexpected key in the write request: %d", key)
+		}
+		tsRaw, err := request.DecodeRawBytes(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ts TimeSeries
+		tsBuffer := proto.NewBuffer(tsRaw)
+		for {
+			key, err := tsBuffer.DecodeVarint()
+			if err != nil {
+				break
+			}
+			raw, err := tsBuffer.DecodeRawBytes(false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buffer := proto.NewBuffer(raw)
+			switch key {
+			case 1<<3 | proto.WireBytes:
+				var label Label
+				buffer.DecodeVarint()
+				label.Name, _ = buffer.DecodeStringBytes()
+				buffer.DecodeVarint()
+				label.Value, _ = buffer.DecodeStringBytes()
+				ts.Labels = append(ts.Labels, label)
+			case 2<<3 | proto.WireBytes:
+				var sample Sample
+				buffer.DecodeVarint()
+				value, _ := buffer.DecodeFixed64()
+				sample.Value = math.Float64frombits(value)
+				buffer.DecodeVarint()
+				timestamp, _ := buffer.DecodeVarint()
+				sample.Timestamp = int64(timestamp)
+				ts.Samples = append(ts.Samples, sample)
+			default:
+				t.Fatalf("Unexpected key in the time series: %d", key)
+			}
+		}
+		result = append(result, ts)
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	t.Parallel()
+	series := []TimeSeries{
+		{Labels: []Label{{"service", "s1"}, {"__name__", "nagflux_value"}, {"host", "h1"}},
+			Samples: []Sample{{1.5, 1000}, {-2, 2000}}},
+		{Labels: []Label{{"__name__", "nagflux_warn"}}, Samples: []Sample{{80, 1000}}},
+	}
+	expected := []TimeSeries{
+		{Labels: []Label{{"__name__", "nagflux_value"}, {"host", "h1"}, {"service", "s1"}},
+			Samples: []Sample{{1.5, 1000}, {-2, 2000}}},
+		{Labels: []Label{{"__name__", "nagflux_warn"}}, Samples: []Sample{{80, 1000}}},
+	}
+	if actual := decodeWriteRequest(t, EncodeWriteRequest(series)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+	if len(EncodeWriteRequest(nil)) != 0 {
+		t.Error("An empty request should be empty")
+	}
+}
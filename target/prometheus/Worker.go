@@ -0,0 +1,191 @@
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/helper"
+	"github.com/griesbacher/nagflux/target/batch"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//metricPrefix is added in front of every performance data field.
+const metricPrefix = "nagflux_"
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+//WorkerGenerator generates a new Worker and starts it.
+func WorkerGenerator(jobs chan collector.Printable, dumpFile string, connector *Connector, target data.Target) func(workerId int) *batch.Worker {
+	return func(workerId int) *batch.Worker {
+		return batch.NewWorker("Prometheus", jobs, dumpFile, connector, target, connector.persistentQueue)
+	}
+}
+
+//Requests converts the queries into a single remote-write request.
+func (connector *Connector) Requests(queries []collector.Printable) []batch.Request {
+	var series []TimeSeries
+	for _, query := range queries {
+		series = append(series, connector.castJobToSeries(query)...)
+	}
+	if len(series) == 0 {
+		return nil
+	}
+	return []batch.Request{connector.request(series)}
+}
+
+//request encodes the series, a rejected request is split into single series to find the bad ones.
+func (connector *Connector) request(series []TimeSeries) batch.Request {
+	return batch.Request{
+		Data: helper.SnappyEncode(EncodeWriteRequest(series)),
+		Dump: func() []byte { return connector.dumpSeries(series) },
+		Split: func() []batch.Request {
+			result := make([]batch.Request, len(series))
+			for i, ts := range series {
+				result[i] = connector.request([]TimeSeries{ts})
+			}
+			return result
+		},
+	}
+}
+
+//Send sends the request to the remote-write endpoint and returns an err if given.
+func (connector *Connector) Send(request batch.Request, log bool) error {
+	req, err := http.NewRequest("POST", connector.connectionHost, bytes.NewBuffer(request.Data))
+	if err != nil {
+		connector.log.Warn(err)
+		return batch.ErrorHTTPClient
+	}
+	req.Header.Set("User-Agent", "Nagflux")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := connector.httpClient.Do(req)
+	if err != nil {
+		connector.log.Warn(err)
+		return batch.ErrorHTTPClient
+	}
+	defer resp.Body.Close()
+	connector.log.Debug(resp.Status)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if log {
+		body, _ := ioutil.ReadAll(resp.Body)
+		connector.log.Warnf("Prometheus status: %s - %s", resp.Status, string(body))
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		//The data will not get better by sending it again
+		return batch.ErrorBadRequest
+	}
+	return batch.ErrorFailedToSend
+}
+
+//Writes the series as JSON lines, the DumpfileCollector reads them line by line.
+func (connector *Connector) dumpSeries(series []TimeSeries) []byte {
+	var buffer bytes.Buffer
+	for _, ts := range series {
+		line, err := json.Marshal(ts)
+		if err != nil {
+			connector.log.Critical(err)
+			continue
+		}
+		buffer.Write(line)
+		buffer.WriteString("\n")
+	}
+	return buffer.Bytes()
+}
+
+//Converts an collector.Printable to remote-write series, unsupported types are skipped.
+func (connector *Connector) castJobToSeries(job collector.Printable) []TimeSeries {
+	p, ok := job.(collector.SimplePrintable)
+	if !ok {
+		var result []TimeSeries
+		for _, point := range job.Points() {
+			result = append(result, PointToSeries(point)...)
+		}
+		return result
+	}
+	if p.Datatype != data.Prometheus {
+		return nil
+	}
+	//Lines from a dumpfile
+	var result []TimeSeries
+	scanner := bufio.NewScanner(strings.NewReader(p.Text))
+	for scanner.Scan() {
+		var ts TimeSeries
+		if err := json.Unmarshal(scanner.Bytes(), &ts); err != nil {
+			connector.log.Warn("Could not parse dumped series: ", err)
+			continue
+		}
+		result = append(result, ts)
+	}
+	return result
+}
+
+//PointToSeries creates one series for every numeric field of the point, the tags become labels.
+//Fields of the metrics measurement are named nagflux_<field>, all others nagflux_<measurement>_<field>.
+//Tags and fields which are equal after sanitizing are merged, the first one in alphabetical order wins.
+//Tags with a reserved label name, starting with __, are dropped.
+func PointToSeries(p collector.Point) []TimeSeries {
+	var labels []Label
+	labelNames := map[string]bool{}
+	for _, k := range sortedKeys(p.Tags) {
+		name := sanitizeLabelName(k)
+		if p.Tags[k] == "" || name == "" || strings.HasPrefix(name, "__") || labelNames[name] {
+			continue
+		}
+		labelNames[name] = true
+		labels = append(labels, Label{Name: name, Value: p.Tags[k]})
+	}
+	prefix := metricPrefix
+	if p.Measurement != "metrics" {
+		prefix += sanitizeLabelName(p.Measurement) + "_"
+	}
+
+	var result []TimeSeries
+	fields := p.NumericFields()
+	fieldNames := make([]string, 0, len(fields))
+	for field := range fields {
+		fieldNames = append(fieldNames, field)
+	}
+	sort.Strings(fieldNames)
+	metricNames := map[string]bool{}
+	for _, field := range fieldNames {
+		metricName := prefix + sanitizeLabelName(field)
+		if metricNames[metricName] {
+			continue
+		}
+		metricNames[metricName] = true
+		ts := TimeSeries{
+			Labels:  append([]Label{{Name: "__name__", Value: metricName}}, labels...),
+			Samples: []Sample{{Value: fields[field], Timestamp: p.TimestampMs()}},
+		}
+		ts.sortLabels()
+		result = append(result, ts)
+	}
+	return result
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//sanitizeLabelName replaces every char which is not allowed within a label name.
+func sanitizeLabelName(name string) string {
+	name = invalidLabelChars.ReplaceAllString(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
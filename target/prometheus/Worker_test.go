@@ -0,0 +1,51 @@
+package prometheus
+
+import (
+	"github.com/griesbacher/nagflux/collector"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPointToSeries(t *testing.T) {
+	t.Parallel()
+	point := collector.Point{
+		Measurement: "metrics",
+		Tags: map[string]string{"service": "s1", "host": "h1", "unit": "", "warn-fill": "red", "warn_fill": "none",
+			"__name__": "evil", "1a": "x"},
+		Fields:    map[string]interface{}{"value": 1.5, "warn": int64(80), "state": "ok", "crit-x": 1.0, "crit_x": 2.0},
+		Timestamp: 2, Precision: time.Second,
+	}
+	labels := func(name string) []Label {
+		return []Label{{"_1a", "x"}, {"__name__", name}, {"host", "h1"}, {"service", "s1"}, {"warn_fill", "red"}}
+	}
+	expected := []TimeSeries{
+		{Labels: labels("nagflux_crit_x"), Samples: []Sample{{1, 2000}}},
+		{Labels: labels("nagflux_value"), Samples: []Sample{{1.5, 2000}}},
+		{Labels: labels("nagflux_warn"), Samples: []Sample{{80, 2000}}},
+	}
+	if actual := PointToSeries(point); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+
+	point.Measurement = "host-status"
+	if actual := PointToSeries(point)[0].Labels[1].Value; actual != "nagflux_host_status_crit_x" {
+		t.Errorf("expected: nagflux_host_status_crit_x, actual: %s", actual)
+	}
+}
+
+func TestSanitizeLabelName(t *testing.T) {
+	t.Parallel()
+	for input, expected := range map[string]string{
+		"host":      "host",
+		"warn-fill": "warn_fill",
+		"C:\\ %":    "C____",
+		"1st":       "_1st",
+		"":          "",
+		"__name__":  "__name__",
+	} {
+		if actual := sanitizeLabelName(input); actual != expected {
+			t.Errorf("sanitizeLabelName(%q): expected: %q, actual: %q", input, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"fmt"
+	"github.com/griesbacher/nagflux/collector"
+	"github.com/griesbacher/nagflux/collector/livestatus"
+	"github.com/griesbacher/nagflux/collector/modGearman"
+	"github.com/griesbacher/nagflux/collector/nagflux"
+	"github.com/griesbacher/nagflux/collector/spoolfile"
+	"github.com/griesbacher/nagflux/collector/wal"
+	"github.com/griesbacher/nagflux/config"
+	"github.com/griesbacher/nagflux/data"
+	"github.com/griesbacher/nagflux/target/elasticsearch"
+	"github.com/griesbacher/nagflux/target/file/json"
+	"github.com/griesbacher/nagflux/target/graphite"
+	"github.com/griesbacher/nagflux/target/influx"
+	"github.com/griesbacher/nagflux/target/kafka"
+	"github.com/griesbacher/nagflux/target/otlp"
+	"github.com/griesbacher/nagflux/target/prometheus"
+	"sort"
+	"strings"
+	"time"
+)
+
+//megabyte is the unit of the WAL size options.
+const megabyte = 1024 * 1024
+
+//defaultWALSegmentSize is used if WALSegmentSize is not set, in MB.
+const defaultWALSegmentSize = 16
+
+//queueConfig describes the queues between the collectors and the targets, it is only read on startup.
+type queueConfig struct {
+	bufferSize     int
+	walFolder      string
+	walSegmentSize int64
+	walMaxSize     int64
+}
+
+//queueConfigFromConfig reads the queue options from the Main section, the WAL sizes are given in MB.
+func queueConfigFromConfig(cfg config.Config) queueConfig {
+	segmentSize := cfg.Main.WALSegmentSize
+	if segmentSize <= 0 {
+		segmentSize = defaultWALSegmentSize
+	}
+	return queueConfig{
+		bufferSize:     cfg.Main.BufferSize,
+		walFolder:      cfg.Main.WALFolder,
+		walSegmentSize: segmentSize * megabyte,
+		walMaxSize:     cfg.Main.WALMaxSize * megabyte,
+	}
+}
+
+//targetStarter starts a target which reads from the given queue, persistentQueue is true if the queue is a WAL.
+//The fingerprint contains every config value the target depends on, if it changes the target has to be restarted.
+//The collectors only add data to the queue which is accepted by the filter.
+type targetStarter struct {
+	fingerprint string
+	filter      *collector.TargetFilter
+	start       func(queue chan collector.Printable, persistentQueue bool) []Stoppable
+}
+
+//runningTarget holds everything which has been started for a single target.
+type runningTarget struct {
+	fingerprint string
+	stoppables  []Stoppable
+	wal         *wal.Queue
+}
+
+//runningGearman holds the workers of a single ModGearman section.
+type runningGearman struct {
+	fingerprint string
+	workers     []Stoppable
+}
+
+//targetFilter creates the filter of a target section from its include and exclude regexes.
+func targetFilter(target data.Target, host, service, command, performanceLabel,
+	excludeHost, excludeService, excludeCommand, excludePerformanceLabel string) (*collector.TargetFilter, error) {
+	filter, err := collector.NewTargetFilter(
+		map[string]string{"host": host, "service": service, "command": command, "performanceLabel": performanceLabel},
+		map[string]string{"host": excludeHost, "service": excludeService, "command": excludeCommand, "performanceLabel": excludePerformanceLabel},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", target, err)
+	}
+	return filter, nil
+}
+
+//targetsFromConfig creates a starter for every enabled target, it fails if a filter is not valid.
+func targetsFromConfig(cfg config.Config) (map[data.Target]targetStarter, error) {
+	result := map[data.Target]targetStarter{}
+	mainFingerprint := fmt.Sprintf("%s|%d|%d|%d", cfg.Main.DumpFile, cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, cfg.Main.FileBufferSize)
+
+	for name, value := range cfg.InfluxDB {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		influxConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.InfluxDB}
+		filter, err := targetFilter(target, influxConfig.HostRegex, influxConfig.ServiceRegex, influxConfig.CommandRegex,
+			influxConfig.PerformanceLabelRegex, influxConfig.ExcludeHostRegex, influxConfig.ExcludeServiceRegex,
+			influxConfig.ExcludeCommandRegex, influxConfig.ExcludePerformanceLabelRegex)
+		if err != nil {
+			return nil, err
+		}
+		result[target] = targetStarter{
+			fingerprint: fmt.Sprintf("%s|%+v|%+v", mainFingerprint, influxConfig, cfg.InfluxDBGlobal),
+			filter:      filter,
+			start: func(queue chan collector.Printable, persistentQueue bool) []Stoppable {
+				config.StoreValue(target, false)
+				influx := influx.ConnectorFactory(
+					queue,
+					influxConfig.Address, influxConfig.Arguments, cfg.Main.DumpFile, influxConfig.Version,
+					cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, cfg.InfluxDBGlobal.CreateDatabaseIfNotExists,
+					influxConfig.StopPullingDataIfDown, target, cfg.InfluxDBGlobal.ClientTimeout,
+					influxConfig.Org, influxConfig.Bucket, influxConfig.Token, influxConfig.RetentionPeriod, persistentQueue,
+				)
+				return append([]Stoppable{influx}, startDumpfileCollector(queue, persistentQueue, cfg, target)...)
+			},
+		}
+	}
+
+	for name, value := range cfg.Elasticsearch {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		elasticConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.Elasticsearch}
+		filter, err := targetFilter(target, elasticConfig.HostRegex, elasticConfig.ServiceRegex, elasticConfig.CommandRegex,
+			elasticConfig.PerformanceLabelRegex, elasticConfig.ExcludeHostRegex, elasticConfig.ExcludeServiceRegex,
+			elasticConfig.ExcludeCommandRegex, elasticConfig.ExcludePerformanceLabelRegex)
+		if err != nil {
+			return nil, err
+		}
+		result[target] = targetStarter{
+			fingerprint: fmt.Sprintf("%s|%+v|%+v", mainFingerprint, elasticConfig, cfg.ElasticsearchGlobal),
+			filter:      filter,
+			start: func(queue chan collector.Printable, persistentQueue bool) []Stoppable {
+				config.StoreValue(target, false)
+				elasticsearch := elasticsearch.ConnectorFactory(
+					queue,
+					elasticConfig.Address, elasticConfig.Index, cfg.Main.DumpFile, elasticConfig.Version,
+					cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, true, persistentQueue,
+				)
+				return append([]Stoppable{elasticsearch}, startDumpfileCollector(queue, persistentQueue, cfg, target)...)
+			},
+		}
+	}
+
+	for name, value := range cfg.PrometheusRemoteWrite {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		prometheusConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.Prometheus}
+		result[target] = targetStarter{
+			fingerprint: fmt.Sprintf("%s|%+v", mainFingerprint, prometheusConfig),
+			start: func(queue chan collector.Printable, persistentQueue bool) []Stoppable {
+				prometheus := prometheus.ConnectorFactory(
+					queue, prometheusConfig.Address, cfg.Main.DumpFile,
+					cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, target, prometheusConfig.ClientTimeout, persistentQueue,
+				)
+				return append([]Stoppable{prometheus}, startDumpfileCollector(queue, persistentQueue, cfg, target)...)
+			},
+		}
+	}
+
+	for name, value := range cfg.Graphite {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		graphiteConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.Graphite}
+		result[target] = targetStarter{
+			fingerprint: fmt.Sprintf("%s|%+v", mainFingerprint, graphiteConfig),
+			start: func(queue chan collector.Printable, persistentQueue bool) []Stoppable {
+				graphite := graphite.ConnectorFactory(
+					queue, graphiteConfig.Address, graphiteConfig.Template, cfg.Main.DumpFile,
+					cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, graphiteConfig.Connections, target, graphiteConfig.ClientTimeout,
+					persistentQueue,
+				)
+				return append([]Stoppable{graphite}, startDumpfileCollector(queue, persistentQueue, cfg, target)...)
+			},
+		}
+	}
+
+	for name, value := range cfg.Kafka {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		kafkaConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.Kafka}
+		result[target] = targetStarter{
+			fingerprint: fmt.Sprintf("%s|%+v", mainFingerprint, kafkaConfig),
+			start: func(queue chan collector.Printable, persistentQueue bool) []Stoppable {
+				kafka := kafka.ConnectorFactory(
+					queue, kafkaConfig.Brokers, kafkaConfig.MetricsTopic, kafkaConfig.MessagesTopic,
+					kafkaConfig.Format, cfg.Main.DumpFile, cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker,
+					kafkaConfig.MaxBufferedMessages, target, kafkaConfig.ClientTimeout, persistentQueue,
+				)
+				return append([]Stoppable{kafka}, startDumpfileCollector(queue, persistentQueue, cfg, target)...)
+			},
+		}
+	}
+
+	for name, value := range cfg.OTLP {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		otlpConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.OTLP}
+		result[target] = targetStarter{
+			fingerprint: fmt.Sprintf("%s|%+v", mainFingerprint, otlpConfig),
+			start: func(queue chan collector.Printable, persistentQueue bool) []Stoppable {
+				otlp := otlp.ConnectorFactory(
+					queue, otlpConfig.Address, otlpConfig.Headers, cfg.Main.DumpFile,
+					cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, target, otlpConfig.ClientTimeout, persistentQueue,
+				)
+				return append([]Stoppable{otlp}, startDumpfileCollector(queue, persistentQueue, cfg, target)...)
+			},
+		}
+	}
+
+	for name, value := range cfg.JSONFileExport {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		jsonFileConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.JSONFile}
+		filter, err := targetFilter(target, jsonFileConfig.HostRegex, jsonFileConfig.ServiceRegex, jsonFileConfig.CommandRegex,
+			jsonFileConfig.PerformanceLabelRegex, jsonFileConfig.ExcludeHostRegex, jsonFileConfig.ExcludeServiceRegex,
+			jsonFileConfig.ExcludeCommandRegex, jsonFileConfig.ExcludePerformanceLabelRegex)
+		if err != nil {
+			return nil, err
+		}
+		result[target] = targetStarter{
+			fingerprint: fmt.Sprintf("%+v", jsonFileConfig),
+			filter:      filter,
+			start: func(queue chan collector.Printable, persistentQueue bool) []Stoppable {
+				templateFile := json.NewJSONFileWorker(
+					log, jsonFileConfig.AutomaticFileRotation,
+					queue, target, jsonFileConfig.Path, persistentQueue,
+				)
+				return []Stoppable{templateFile}
+			},
+		}
+	}
+	return result, nil
+}
+
+//startDumpfileCollector replays the dumpfile of the target into its queue. A WAL replays the unsent data itself, so
+//the targets write no dumpfile which would have to be replayed.
+func startDumpfileCollector(queue chan collector.Printable, persistentQueue bool, cfg config.Config, target data.Target) []Stoppable {
+	if persistentQueue {
+		return nil
+	}
+	dumpFileCollector := nagflux.NewDumpfileCollector(queue, cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
+	waitForDumpfileCollector(dumpFileCollector)
+	return []Stoppable{dumpFileCollector}
+}
+
+//startTargets starts the targets and creates their queues.
+func startTargets(starters map[data.Target]targetStarter, resultQueues collector.ResultQueues, queues queueConfig) map[data.Target]*runningTarget {
+	running := map[data.Target]*runningTarget{}
+	for _, target := range sortedTargets(starters) {
+		running[target] = startTarget(target, starters[target], resultQueues, queues, nil)
+	}
+	return running
+}
+
+//startTarget starts a single target, an existing queue or write-ahead log is reused so no data gets lost.
+//The records of a reused write-ahead log, which the previous target did not acknowledge, are passed again.
+func startTarget(target data.Target, starter targetStarter, resultQueues collector.ResultQueues, queues queueConfig, queue *wal.Queue) *runningTarget {
+	log.Infof("Starting target: %s", target)
+	resultQueues.SetFilter(target, starter.filter)
+	if queue != nil {
+		queue.Rewind()
+	} else if queues.walFolder != "" {
+		var err error
+		queue, err = wal.NewQueue(queues.walFolder, target, queues.walSegmentSize, queues.walMaxSize, queues.bufferSize)
+		if err != nil {
+			log.Criticalf("Could not open the WAL of %s, falling back to memory: %s", target, err)
+		}
+	}
+	if queue != nil {
+		resultQueues.Set(target, queue.In())
+		return &runningTarget{fingerprint: starter.fingerprint, stoppables: starter.start(queue.Out(), true), wal: queue}
+	}
+	in, found := resultQueues.Get(target)
+	if !found {
+		in = make(chan collector.Printable, queues.bufferSize)
+		resultQueues.Set(target, in)
+	}
+	return &runningTarget{fingerprint: starter.fingerprint, stoppables: starter.start(in, false)}
+}
+
+//stopTarget stops everything which belongs to the target in reverse order, the write-ahead log is kept for a restart.
+func stopTarget(target data.Target, running *runningTarget) {
+	log.Infof("Stopping target: %s", target)
+	stopAll(running.stoppables)
+}
+
+//removeTarget stops the target and its write-ahead log.
+func removeTarget(target data.Target, running *runningTarget) {
+	stopTarget(target, running)
+	if running.wal != nil {
+		running.wal.Stop()
+	}
+}
+
+func stopAll(stoppables []Stoppable) {
+	for i := len(stoppables) - 1; i >= 0; i-- {
+		stoppables[i].Stop()
+	}
+}
+
+//gearmanFromConfig returns the fingerprint of every enabled ModGearman section, it contains the resolved secret.
+func gearmanFromConfig(cfg config.Config) (map[string]string, error) {
+	result := map[string]string{}
+	for name, value := range cfg.ModGearman {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		gearmanConfig := (*value)
+		secret, err := modGearman.ReadSecret(gearmanConfig.Secret, gearmanConfig.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("Mod_Gearman %s: %s", name, err)
+		}
+		gearmanConfig.Secret = secret
+		result[name] = fmt.Sprintf("%+v", gearmanConfig)
+	}
+	return result, nil
+}
+
+//startGearman starts the configured amount of workers of a ModGearman section.
+func startGearman(name string, cfg config.Config, resultQueues collector.ResultQueues, livestatusCache livestatus.CacheBuilders,
+	unitNormalizer *spoolfile.UnitNormalizer, rateCalculator *spoolfile.RateCalculator) []Stoppable {
+	gearmanConfig := *cfg.ModGearman[name]
+	log.Infof("Mod_Gearman: %s - %s [%s]", name, gearmanConfig.Address, gearmanConfig.Queue)
+	secret := modGearman.GetSecret(gearmanConfig.Secret, gearmanConfig.SecretFile)
+	var workers []Stoppable
+	for i := 0; i < gearmanConfig.Worker; i++ {
+		workers = append(workers, modGearman.NewGearmanWorker(gearmanConfig.Address,
+			gearmanConfig.Queue,
+			secret,
+			resultQueues,
+			livestatusCache,
+			unitNormalizer,
+			rateCalculator,
+		))
+	}
+	return workers
+}
+
+//livestatusFromConfig returns the fingerprint of every Livestatus section, the key is the name of the site.
+func livestatusFromConfig(cfg config.Config) map[string]string {
+	result := map[string]string{}
+	for site, value := range cfg.Livestatus {
+		if value != nil {
+			result[site] = fmt.Sprintf("%+v", *value)
+		}
+	}
+	return result
+}
+
+//startLivestatus starts a collector, a downtime cache and optionally a state poller for every Livestatus section.
+//The TLS files of all sections are loaded before anything is started. The connectors and deduplicators come first in
+//the returned stoppables, so they are stopped after the collectors.
+func startLivestatus(cfg config.Config, resultQueues collector.ResultQueues) ([]Stoppable, livestatus.CacheBuilders, error) {
+	sites := sortedKeys(livestatusFromConfig(cfg))
+	connectors := map[string]*livestatus.Connector{}
+	for _, site := range sites {
+		liveConfig := cfg.Livestatus[site]
+		connector := &livestatus.Connector{
+			Log:               log,
+			LivestatusAddress: liveConfig.Address,
+			ConnectionType:    liveConfig.Type,
+			DialTimeout:       time.Duration(liveConfig.DialTimeout) * time.Second,
+			ReadTimeout:       time.Duration(liveConfig.ReadTimeout) * time.Second,
+			KeepAlive:         liveConfig.KeepAlive,
+		}
+		if liveConfig.Type == "tls" {
+			tlsConfig, err := livestatus.NewTLSConfig(liveConfig.CAFile, liveConfig.CertFile, liveConfig.KeyFile, liveConfig.InsecureSkipVerify)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Livestatus %s: %s", site, err)
+			}
+			connector.TLSConfig = tlsConfig
+		}
+		connectors[site] = connector
+	}
+	var stoppables []Stoppable
+	deduplicators := map[string]*livestatus.Deduplicator{}
+	for _, site := range sites {
+		liveConfig := cfg.Livestatus[site]
+		deduplicators[site] = livestatus.NewDeduplicator(liveConfig.DeduplicationSize, liveConfig.DeduplicationStateFile)
+		stoppables = append(stoppables, connectors[site], deduplicators[site])
+	}
+	caches := livestatus.CacheBuilders{}
+	for _, site := range sites {
+		liveConfig := cfg.Livestatus[site]
+		log.Infof("Livestatus: %s - %s [%s]", site, liveConfig.Address, liveConfig.Type)
+		stoppables = append(stoppables, livestatus.NewLivestatusCollector(resultQueues, connectors[site], liveConfig.Version,
+			liveConfig.MinutesToWait, site, deduplicators[site]))
+		caches[site] = livestatus.NewLivestatusCacheBuilder(connectors[site], strings.Split(liveConfig.CustomVariables, ","), liveConfig.Groups)
+		if liveConfig.StatesInterval > 0 {
+			stoppables = append(stoppables, livestatus.NewStatePoller(resultQueues, connectors[site],
+				time.Duration(liveConfig.StatesInterval)*time.Second, site))
+		}
+	}
+	return stoppables, caches, nil
+}
+
+func sortedTargets(starters map[data.Target]targetStarter) []data.Target {
+	targets := make([]data.Target, 0, len(starters))
+	for target := range starters {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].String() < targets[j].String() })
+	return targets
+}